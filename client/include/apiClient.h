@@ -1,5 +1,6 @@
 #pragma once
 
+#include <QByteArray>
 #include <QObject>
 #include <QString>
 #include <QVariant>
@@ -44,7 +45,7 @@ public:
     bool createLocation(const QString& name, const QString& note, QString& outError);
     bool updateLocation(qint64 id, const QString& name, const QString& note, QString& outError);
     bool deleteLocation(qint64 id, QString& outError);
-    bool listDevices(const QString& query, QList<DeviceItem>& outDevices, QString& outError);
+    bool listDevices(const QString& query, const QString& pageToken, DeviceListPage& outPage, QString& outError);
 
     bool getDevice(qint64 id, DeviceDetails& outDevice, QString& outError);
 
@@ -52,10 +53,14 @@ public:
                       const QString& inventoryNumber, const QString& status, const QString& installedAt,
                       const QString& description, QString& outError);
 
-    bool updateDevice(qint64 id, qint64 modelId, const QVariant& locationId, const QString& serialNumber,
-                      const QString& inventoryNumber, const QString& status, const QString& installedAt,
-                      const QString& description, QString& outError);
+    // version — версия из getDevice (ETag). Если устройство успели изменить,
+    // возвращает false, outConflict = true и актуальное состояние в outCurrent.
+    bool updateDevice(qint64 id, qint64 version, qint64 modelId, const QVariant& locationId,
+                      const QString& serialNumber, const QString& inventoryNumber, const QString& status,
+                      const QString& installedAt, const QString& description, bool& outConflict,
+                      DeviceDetails& outCurrent, QString& outError);
     bool deleteDevice(qint64 id, QString& outError);
+    bool getDeviceHistory(qint64 id, QList<DeviceHistoryEntry>& outEntries, QString& outError);
 
     bool listUsers(QList<UserItem>& outUsers, QString& outError);
     bool setUserApproved(qint64 id, bool approved, QString& outError);
@@ -68,6 +73,8 @@ private:
     bool getJsonObject(const QString& path, QJsonObject& outObject, QString& outError);
     bool postJsonObject(const QString& path, const QJsonObject& body, QJsonObject& outObject, QString& outError);
     bool putJsonObject(const QString& path, const QJsonObject& body, QJsonObject& outObject, QString& outError);
+    bool putJsonObject(const QString& path, const QJsonObject& body, const QByteArray& ifMatch, QJsonObject& outObject,
+                       int& outHttpStatus, QString& outError);
     bool deleteRequest(const QString& path, QJsonObject& outObject, QString& outError);
 
     QString baseUrl_;
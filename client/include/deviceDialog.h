@@ -8,6 +8,7 @@
 class ApiClient;
 class QComboBox;
 class QLineEdit;
+class QPushButton;
 class QTextEdit;
 
 class DeviceDialog final : public QDialog {
@@ -31,6 +32,7 @@ public:
 private:
     void buildUi();
     void applyInitialSelection();
+    void showHistory();
 
     ApiClient* apiClient_;
 
@@ -44,7 +46,8 @@ private:
     QComboBox* locationCombo_;
     QLineEdit* serialEdit_;
     QLineEdit* inventoryEdit_;
-    QLineEdit* statusEdit_;
+    QComboBox* statusCombo_;
     QLineEdit* installedAtEdit_;
     QTextEdit* descriptionEdit_;
+    QPushButton* historyButton_;
 };
@@ -22,14 +22,20 @@ signals:
 private:
     void buildUi(const QString& username, const QString& role);
     void loadDevices();
+    void loadMoreDevices();
+    void appendDevices(const DeviceListPage& page);
     qint64 selectedDeviceId() const;
 
     ApiClient* apiClient_;
 
     QList<DeviceItem> devices_;
+    QString nextPageToken_;
+    qint64 totalDevices_;
 
     class QLineEdit* searchEdit_;
     class QTableWidget* table_;
+    class QLabel* countLabel_;
+    class QPushButton* moreButton_;
     class QAction* addAction_;
     class QAction* editAction_;
     class QAction* vendorsAction_;
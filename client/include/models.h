@@ -1,5 +1,6 @@
 #pragma once
 
+#include <QList>
 #include <QString>
 
 struct VendorItem {
@@ -48,6 +49,12 @@ struct DeviceItem {
     QString installedAt;
 };
 
+struct DeviceListPage {
+    QList<DeviceItem> items;
+    qint64 total = 0;
+    QString nextPageToken;
+};
+
 struct DeviceDetails {
     qint64 id = 0;
     qint64 modelId = 0;
@@ -57,6 +64,22 @@ struct DeviceDetails {
     QString status;
     QString installedAt;
     QString description;
+    qint64 version = 0;
+    QString updatedAt;
+};
+
+struct DeviceFieldChange {
+    QString field;
+    QString oldValue;
+    QString newValue;
+};
+
+struct DeviceHistoryEntry {
+    qint64 id = 0;
+    QString action;
+    QString actor;
+    QString createdAt;
+    QList<DeviceFieldChange> changes;
 };
 
 struct UserItem {
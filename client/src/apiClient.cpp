@@ -245,19 +245,25 @@ bool ApiClient::deleteLocation(qint64 id, QString& outError) {
     return deleteRequest(QString("/locations/%1").arg(id), obj, outError);
 }
 
-bool ApiClient::listDevices(const QString& query, QList<DeviceItem>& outDevices, QString& outError) {
+bool ApiClient::listDevices(const QString& query, const QString& pageToken, DeviceListPage& outPage, QString& outError) {
     QUrl url(baseUrl_ + "/devices");
     QUrlQuery q;
     q.addQueryItem("q", query);
+    q.addQueryItem("limit", "200");
+    if (!pageToken.isEmpty()) {
+        q.addQueryItem("page_token", pageToken);
+    }
     url.setQuery(q);
 
-    QJsonArray arr;
-    if (!getJsonArray(url.path() + "?" + url.query(), arr, outError)) {
+    QJsonObject obj;
+    if (!getJsonObject(url.path() + "?" + url.query(QUrl::FullyEncoded), obj, outError)) {
         return false;
     }
 
-    outDevices.clear();
-    for (const auto& v : arr) {
+    outPage = DeviceListPage{};
+    outPage.total = static_cast<qint64>(obj.value("total").toDouble());
+    outPage.nextPageToken = obj.value("nextPageToken").toString();
+    for (const auto& v : obj.value("items").toArray()) {
         if (!v.isObject()) {
             continue;
         }
@@ -271,33 +277,44 @@ bool ApiClient::listDevices(const QString& query, QList<DeviceItem>& outDevices,
         item.inventoryNumber = o.value("inventoryNumber").toString();
         item.status = o.value("status").toString();
         item.installedAt = o.value("installedAt").toString();
-        outDevices.push_back(item);
+        outPage.items.push_back(item);
     }
 
     return true;
 }
 
-bool ApiClient::getDevice(qint64 id, DeviceDetails& outDevice, QString& outError) {
-    QJsonObject obj;
-    if (!getJsonObject(QString("/devices/%1").arg(id), obj, outError)) {
-        return false;
-    }
+namespace {
 
-    outDevice = DeviceDetails{};
-    outDevice.id = static_cast<qint64>(obj.value("id").toDouble());
-    outDevice.modelId = static_cast<qint64>(obj.value("modelId").toDouble());
+DeviceDetails deviceDetailsFromJson(const QJsonObject& obj) {
+    DeviceDetails device;
+    device.id = static_cast<qint64>(obj.value("id").toDouble());
+    device.modelId = static_cast<qint64>(obj.value("modelId").toDouble());
 
     if (obj.contains("locationId") && !obj.value("locationId").isNull()) {
-        outDevice.locationId = obj.value("locationId").toVariant();
+        device.locationId = obj.value("locationId").toVariant();
     } else {
-        outDevice.locationId = QVariant();
+        device.locationId = QVariant();
     }
 
-    outDevice.serialNumber = obj.value("serialNumber").toString();
-    outDevice.inventoryNumber = obj.value("inventoryNumber").toString();
-    outDevice.status = obj.value("status").toString();
-    outDevice.installedAt = obj.value("installedAt").toString();
-    outDevice.description = obj.value("description").toString();
+    device.serialNumber = obj.value("serialNumber").toString();
+    device.inventoryNumber = obj.value("inventoryNumber").toString();
+    device.status = obj.value("status").toString();
+    device.installedAt = obj.value("installedAt").toString();
+    device.description = obj.value("description").toString();
+    device.version = static_cast<qint64>(obj.value("version").toDouble());
+    device.updatedAt = obj.value("updatedAt").toString();
+    return device;
+}
+
+} // namespace
+
+bool ApiClient::getDevice(qint64 id, DeviceDetails& outDevice, QString& outError) {
+    QJsonObject obj;
+    if (!getJsonObject(QString("/devices/%1").arg(id), obj, outError)) {
+        return false;
+    }
+
+    outDevice = deviceDetailsFromJson(obj);
     return true;
 }
 
@@ -319,9 +336,12 @@ bool ApiClient::createDevice(qint64 modelId, const QVariant& locationId, const Q
     return postJsonObject("/devices", body, obj, outError);
 }
 
-bool ApiClient::updateDevice(qint64 id, qint64 modelId, const QVariant& locationId, const QString& serialNumber,
-                             const QString& inventoryNumber, const QString& status, const QString& installedAt,
-                             const QString& description, QString& outError) {
+bool ApiClient::updateDevice(qint64 id, qint64 version, qint64 modelId, const QVariant& locationId,
+                             const QString& serialNumber, const QString& inventoryNumber, const QString& status,
+                             const QString& installedAt, const QString& description, bool& outConflict,
+                             DeviceDetails& outCurrent, QString& outError) {
+    outConflict = false;
+
     QJsonObject body;
     body["modelId"] = static_cast<double>(modelId);
     if (locationId.isValid() && !locationId.isNull()) {
@@ -334,7 +354,16 @@ bool ApiClient::updateDevice(qint64 id, qint64 modelId, const QVariant& location
     body["description"] = description;
 
     QJsonObject obj;
-    return putJsonObject(QString("/devices/%1").arg(id), body, obj, outError);
+    int httpStatus = 0;
+    const QByteArray ifMatch = "\"" + QByteArray::number(version) + "\"";
+    if (putJsonObject(QString("/devices/%1").arg(id), body, ifMatch, obj, httpStatus, outError)) {
+        return true;
+    }
+    if (httpStatus == 412 && obj.value("current").isObject()) {
+        outConflict = true;
+        outCurrent = deviceDetailsFromJson(obj.value("current").toObject());
+    }
+    return false;
 }
 
 bool ApiClient::deleteDevice(qint64 id, QString& outError) {
@@ -342,6 +371,37 @@ bool ApiClient::deleteDevice(qint64 id, QString& outError) {
     return deleteRequest(QString("/devices/%1").arg(id), obj, outError);
 }
 
+bool ApiClient::getDeviceHistory(qint64 id, QList<DeviceHistoryEntry>& outEntries, QString& outError) {
+    QJsonArray arr;
+    if (!getJsonArray(QString("/devices/%1/history").arg(id), arr, outError)) {
+        return false;
+    }
+
+    outEntries.clear();
+    for (const auto& v : arr) {
+        if (!v.isObject()) {
+            continue;
+        }
+        const QJsonObject o = v.toObject();
+        DeviceHistoryEntry entry;
+        entry.id = static_cast<qint64>(o.value("id").toDouble());
+        entry.action = o.value("action").toString();
+        entry.actor = o.value("actor").toString();
+        entry.createdAt = o.value("createdAt").toString();
+        for (const auto& c : o.value("changes").toArray()) {
+            const QJsonObject co = c.toObject();
+            DeviceFieldChange change;
+            change.field = co.value("field").toString();
+            change.oldValue = co.value("old").toString();
+            change.newValue = co.value("new").toString();
+            entry.changes.push_back(change);
+        }
+        outEntries.push_back(entry);
+    }
+
+    return true;
+}
+
 bool ApiClient::listUsers(QList<UserItem>& outUsers, QString& outError) {
     QJsonArray arr;
     if (!getJsonArray("/users", arr, outError)) {
@@ -519,12 +579,21 @@ bool ApiClient::postJsonObject(const QString& path, const QJsonObject& body, QJs
 }
 
 bool ApiClient::putJsonObject(const QString& path, const QJsonObject& body, QJsonObject& outObject, QString& outError) {
+    int httpStatus = 0;
+    return putJsonObject(path, body, QByteArray(), outObject, httpStatus, outError);
+}
+
+bool ApiClient::putJsonObject(const QString& path, const QJsonObject& body, const QByteArray& ifMatch, QJsonObject& outObject,
+                              int& outHttpStatus, QString& outError) {
     QNetworkAccessManager manager;
     QNetworkRequest request(QUrl(baseUrl_ + path));
     request.setHeader(QNetworkRequest::ContentTypeHeader, "application/json");
     if (!token_.isEmpty()) {
         request.setRawHeader("Authorization", ("Bearer " + token_).toUtf8());
     }
+    if (!ifMatch.isEmpty()) {
+        request.setRawHeader("If-Match", ifMatch);
+    }
 
     QNetworkReply* reply = manager.put(request, QJsonDocument(body).toJson(QJsonDocument::Compact));
 
@@ -542,11 +611,14 @@ bool ApiClient::putJsonObject(const QString& path, const QJsonObject& body, QJso
     timeout.start(7000);
     loop.exec();
 
+    outHttpStatus = reply->attribute(QNetworkRequest::HttpStatusCodeAttribute).toInt();
+
     const QByteArray responseBytes = reply->readAll();
     const QJsonDocument doc = QJsonDocument::fromJson(responseBytes);
 
     if (reply->error() != QNetworkReply::NoError) {
         if (doc.isObject()) {
+            outObject = doc.object();
             outError = doc.object().value("error").toString(reply->errorString());
         } else {
             outError = reply->errorString();
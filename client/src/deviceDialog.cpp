@@ -1,12 +1,15 @@
 #include "deviceDialog.h"
 
 #include "apiClient.h"
+#include "messageBoxUtils.h"
 
 #include <QComboBox>
 #include <QDialogButtonBox>
 #include <QFormLayout>
+#include <QHeaderView>
 #include <QLabel>
 #include <QLineEdit>
+#include <QTableWidget>
 #include <QTextEdit>
 #include <QVBoxLayout>
 #include <QPushButton>
@@ -21,9 +24,10 @@ DeviceDialog::DeviceDialog(ApiClient* apiClient, QWidget* parent)
     , locationCombo_(nullptr)
     , serialEdit_(nullptr)
     , inventoryEdit_(nullptr)
-    , statusEdit_(nullptr)
+    , statusCombo_(nullptr)
     , installedAtEdit_(nullptr)
-    , descriptionEdit_(nullptr) {
+    , descriptionEdit_(nullptr)
+    , historyButton_(nullptr) {
     setWindowTitle("Устройство");
     buildUi();
 }
@@ -53,9 +57,15 @@ void DeviceDialog::buildUi() {
     inventoryEdit_ = new QLineEdit(this);
     form->addRow("Инвентарный:", inventoryEdit_);
 
-    statusEdit_ = new QLineEdit(this);
-    statusEdit_->setText("active");
-    form->addRow("Статус:", statusEdit_);
+    statusCombo_ = new QComboBox(this);
+    statusCombo_->addItem("На складе", QString("in_stock"));
+    statusCombo_->addItem("В эксплуатации", QString("active"));
+    statusCombo_->addItem("На обслуживании", QString("maintenance"));
+    statusCombo_->addItem("Неисправно", QString("faulty"));
+    statusCombo_->addItem("Выведено из эксплуатации", QString("decommissioned"));
+    statusCombo_->addItem("Списано", QString("written_off"));
+    statusCombo_->setCurrentIndex(statusCombo_->findData(QString("active")));
+    form->addRow("Статус:", statusCombo_);
 
     installedAtEdit_ = new QLineEdit(this);
     installedAtEdit_->setPlaceholderText("YYYY-MM-DD");
@@ -75,6 +85,10 @@ void DeviceDialog::buildUi() {
     if (auto* cancel = buttons->button(QDialogButtonBox::Cancel)) {
         cancel->setIcon(QIcon());
     }
+    // История доступна только для уже существующего устройства (см. setInitialDevice).
+    historyButton_ = buttons->addButton("История", QDialogButtonBox::ActionRole);
+    historyButton_->setVisible(false);
+    connect(historyButton_, &QPushButton::clicked, this, &DeviceDialog::showHistory);
     connect(buttons, &QDialogButtonBox::accepted, this, &QDialog::accept);
     connect(buttons, &QDialogButtonBox::rejected, this, &QDialog::reject);
     root->addWidget(buttons);
@@ -119,6 +133,9 @@ bool DeviceDialog::loadReferenceData(QString& outError) {
 void DeviceDialog::setInitialDevice(const DeviceDetails& device) {
     initialDevice_ = device;
     hasInitialDevice_ = true;
+    if (historyButton_) {
+        historyButton_->setVisible(device.id > 0);
+    }
     applyInitialSelection();
 }
 
@@ -153,8 +170,11 @@ void DeviceDialog::applyInitialSelection() {
     if (inventoryEdit_) {
         inventoryEdit_->setText(initialDevice_.inventoryNumber);
     }
-    if (statusEdit_) {
-        statusEdit_->setText(initialDevice_.status.isEmpty() ? "active" : initialDevice_.status);
+    if (statusCombo_) {
+        const int idx = statusCombo_->findData(initialDevice_.status.isEmpty() ? QString("active") : initialDevice_.status);
+        if (idx >= 0) {
+            statusCombo_->setCurrentIndex(idx);
+        }
     }
     if (installedAtEdit_) {
         installedAtEdit_->setText(initialDevice_.installedAt);
@@ -187,7 +207,7 @@ QString DeviceDialog::inventoryNumber() const {
 }
 
 QString DeviceDialog::status() const {
-    return statusEdit_ ? statusEdit_->text().trimmed() : QString();
+    return statusCombo_ ? statusCombo_->currentData().toString() : QString();
 }
 
 QString DeviceDialog::installedAt() const {
@@ -197,3 +217,60 @@ QString DeviceDialog::installedAt() const {
 QString DeviceDialog::description() const {
     return descriptionEdit_ ? descriptionEdit_->toPlainText().trimmed() : QString();
 }
+
+void DeviceDialog::showHistory() {
+    if (!apiClient_ || !hasInitialDevice_) {
+        return;
+    }
+
+    QList<DeviceHistoryEntry> entries;
+    QString err;
+    if (!apiClient_->getDeviceHistory(initialDevice_.id, entries, err)) {
+        UiUtils::warning(this, "Ошибка", err.isEmpty() ? "Не удалось загрузить историю" : err);
+        return;
+    }
+
+    QDialog dlg(this);
+    dlg.setWindowTitle("История изменений");
+    QVBoxLayout* layout = new QVBoxLayout(&dlg);
+    layout->setContentsMargins(16, 16, 16, 16);
+
+    QTableWidget* table = new QTableWidget(0, 6, &dlg);
+    table->setHorizontalHeaderLabels({"Дата", "Пользователь", "Действие", "Поле", "Было", "Стало"});
+    table->setEditTriggers(QAbstractItemView::NoEditTriggers);
+    table->setSelectionBehavior(QAbstractItemView::SelectRows);
+    table->setAlternatingRowColors(true);
+    table->setShowGrid(false);
+    table->verticalHeader()->setVisible(false);
+    table->horizontalHeader()->setStretchLastSection(true);
+
+    for (const auto& entry : entries) {
+        // Одна строка на каждое изменённое поле; пустая запись тоже видна.
+        const int count = entry.changes.isEmpty() ? 1 : static_cast<int>(entry.changes.size());
+        for (int i = 0; i < count; ++i) {
+            const int row = table->rowCount();
+            table->insertRow(row);
+            table->setItem(row, 0, new QTableWidgetItem(entry.createdAt));
+            table->setItem(row, 1, new QTableWidgetItem(entry.actor));
+            table->setItem(row, 2, new QTableWidgetItem(entry.action));
+            if (!entry.changes.isEmpty()) {
+                const auto& change = entry.changes[i];
+                table->setItem(row, 3, new QTableWidgetItem(change.field));
+                table->setItem(row, 4, new QTableWidgetItem(change.oldValue));
+                table->setItem(row, 5, new QTableWidgetItem(change.newValue));
+            }
+        }
+    }
+    table->resizeColumnsToContents();
+    layout->addWidget(table);
+
+    QDialogButtonBox* buttons = new QDialogButtonBox(QDialogButtonBox::Close, &dlg);
+    if (auto* close = buttons->button(QDialogButtonBox::Close)) {
+        close->setIcon(QIcon());
+    }
+    connect(buttons, &QDialogButtonBox::rejected, &dlg, &QDialog::reject);
+    layout->addWidget(buttons);
+
+    dlg.resize(800, 420);
+    dlg.exec();
+}
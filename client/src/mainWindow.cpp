@@ -12,18 +12,60 @@
 #include <QLabel>
 #include <QLineEdit>
 #include <QMessageBox>
+#include <QPushButton>
+#include <QStringList>
 #include <QTableWidget>
+#include <QHBoxLayout>
 #include <QHeaderView>
 #include <QToolBar>
 #include <QVBoxLayout>
 #include <QWidget>
 
+namespace {
+
+// deviceConflictSummary перечисляет поля, в которых значения из диалога
+// расходятся с актуальным состоянием устройства на сервере.
+QString deviceConflictSummary(const DeviceDialog& dlg, const DeviceDetails& current) {
+    QStringList lines;
+    const auto compare = [&lines](const QString& title, const QString& mine, const QString& theirs) {
+        if (mine != theirs) {
+            lines << QString("%1: у вас «%2», на сервере «%3»").arg(title, mine, theirs);
+        }
+    };
+
+    if (dlg.selectedModelId() != current.modelId) {
+        lines << "Модель изменена";
+    }
+    const QVariant location = dlg.selectedLocationId();
+    const qint64 myLocation = location.isValid() && !location.isNull() ? location.toLongLong() : 0;
+    const qint64 serverLocation =
+        current.locationId.isValid() && !current.locationId.isNull() ? current.locationId.toLongLong() : 0;
+    if (myLocation != serverLocation) {
+        lines << "Локация изменена";
+    }
+    compare("Серийный номер", dlg.serialNumber(), current.serialNumber);
+    compare("Инвентарный номер", dlg.inventoryNumber(), current.inventoryNumber);
+    compare("Статус", dlg.status(), current.status);
+    compare("Дата установки", dlg.installedAt(), current.installedAt);
+    compare("Описание", dlg.description(), current.description);
+
+    if (lines.isEmpty()) {
+        return "Значения полей совпадают с вашими.\n";
+    }
+    return lines.join("\n") + "\n";
+}
+
+} // namespace
+
 MainWindow::MainWindow(ApiClient* apiClient, const QString& username, const QString& role, QWidget* parent)
     : QMainWindow(parent)
     , apiClient_(apiClient)
     , devices_()
+    , totalDevices_(0)
     , searchEdit_(nullptr)
     , table_(nullptr)
+    , countLabel_(nullptr)
+    , moreButton_(nullptr)
     , addAction_(nullptr)
     , editAction_(nullptr)
     , vendorsAction_(nullptr)
@@ -51,8 +93,12 @@ void MainWindow::setSession(const QString& username, const QString& role) {
     }
 
     devices_.clear();
+    nextPageToken_.clear();
+    totalDevices_ = 0;
     searchEdit_ = nullptr;
     table_ = nullptr;
+    countLabel_ = nullptr;
+    moreButton_ = nullptr;
     addAction_ = nullptr;
     editAction_ = nullptr;
     vendorsAction_ = nullptr;
@@ -108,7 +154,7 @@ void MainWindow::buildUi(const QString& username, const QString& role) {
     layout->addWidget(userInfo);
 
     searchEdit_ = new QLineEdit(central);
-    searchEdit_->setPlaceholderText("Поиск (серийный/инвентарный, модель, производитель, статус)");
+    searchEdit_->setPlaceholderText("Поиск (серийный/инвентарный, модель, производитель, локация, описание)");
     layout->addWidget(searchEdit_);
 
     table_ = new QTableWidget(0, 8, central);
@@ -126,11 +172,21 @@ void MainWindow::buildUi(const QString& username, const QString& role) {
     table_->setColumnWidth(1, 240);
     layout->addWidget(table_);
 
+    QHBoxLayout* pager = new QHBoxLayout();
+    countLabel_ = new QLabel(central);
+    pager->addWidget(countLabel_);
+    pager->addStretch(1);
+    moreButton_ = new QPushButton("Показать ещё", central);
+    moreButton_->setEnabled(false);
+    pager->addWidget(moreButton_);
+    layout->addLayout(pager);
+
     setCentralWidget(central);
     resize(900, 600);
 
     connect(refreshAction_, &QAction::triggered, this, &MainWindow::loadDevices);
     connect(searchEdit_, &QLineEdit::returnPressed, this, &MainWindow::loadDevices);
+    connect(moreButton_, &QPushButton::clicked, this, &MainWindow::loadMoreDevices);
     connect(logoutAction_, &QAction::triggered, this, &MainWindow::logoutRequested);
 
     if (themeAction_) {
@@ -232,10 +288,33 @@ void MainWindow::buildUi(const QString& username, const QString& role) {
             return;
         }
 
-        if (!apiClient_->updateDevice(id, modelId, dlg.selectedLocationId(), dlg.serialNumber(), dlg.inventoryNumber(),
-                                      dlg.status(), dlg.installedAt(), dlg.description(), err)) {
-            UiUtils::warning(this, "Не удалось сохранить", err.isEmpty() ? "Ошибка" : err);
-            return;
+        qint64 version = details.version;
+        while (true) {
+            bool conflict = false;
+            DeviceDetails current;
+            if (apiClient_->updateDevice(id, version, modelId, dlg.selectedLocationId(), dlg.serialNumber(),
+                                         dlg.inventoryNumber(), dlg.status(), dlg.installedAt(), dlg.description(),
+                                         conflict, current, err)) {
+                break;
+            }
+            if (!conflict) {
+                UiUtils::warning(this, "Не удалось сохранить", err.isEmpty() ? "Ошибка" : err);
+                return;
+            }
+
+            // Пока диалог был открыт, устройство изменил кто-то другой.
+            QMessageBox box(QMessageBox::Warning, "Конфликт изменений",
+                            "Устройство уже изменено другим пользователем.\n\n" + deviceConflictSummary(dlg, current) +
+                                "\nПерезаписать своими значениями или отменить свои изменения?",
+                            QMessageBox::NoButton, this);
+            QPushButton* overwriteButton = box.addButton("Перезаписать", QMessageBox::AcceptRole);
+            box.addButton("Отменить мои изменения", QMessageBox::RejectRole);
+            UiUtils::clearButtonIcons(box);
+            box.exec();
+            if (box.clickedButton() != overwriteButton) {
+                break;
+            }
+            version = current.version;
         }
 
         loadDevices();
@@ -248,7 +327,7 @@ void MainWindow::buildUi(const QString& username, const QString& role) {
             return;
         }
 
-        const auto answer = UiUtils::question(this, "Удаление", "Удалить выбранное устройство?");
+        const auto answer = UiUtils::question(this, "Удаление", "Переместить выбранное устройство в корзину?");
         if (answer != QMessageBox::Yes) {
             return;
         }
@@ -272,34 +351,65 @@ void MainWindow::loadDevices() {
         return;
     }
 
-    QList<DeviceItem> devices;
+    DeviceListPage page;
+    QString err;
+    if (!apiClient_->listDevices(searchEdit_ ? searchEdit_->text().trimmed() : QString(), QString(), page, err)) {
+        UiUtils::warning(this, "Ошибка", err.isEmpty() ? "Не удалось загрузить устройства" : err);
+        return;
+    }
+
+    devices_.clear();
+    table_->setRowCount(0);
+    appendDevices(page);
+}
+
+void MainWindow::loadMoreDevices() {
+    if (!apiClient_ || nextPageToken_.isEmpty()) {
+        return;
+    }
+
+    DeviceListPage page;
     QString err;
-    if (!apiClient_->listDevices(searchEdit_ ? searchEdit_->text().trimmed() : QString(), devices, err)) {
+    if (!apiClient_->listDevices(searchEdit_ ? searchEdit_->text().trimmed() : QString(), nextPageToken_, page, err)) {
         UiUtils::warning(this, "Ошибка", err.isEmpty() ? "Не удалось загрузить устройства" : err);
         return;
     }
 
-    devices_ = devices;
+    appendDevices(page);
+}
+
+void MainWindow::appendDevices(const DeviceListPage& page) {
+    devices_.append(page.items);
+    nextPageToken_ = page.nextPageToken;
+    totalDevices_ = page.total;
 
     const bool wasSorting = table_->isSortingEnabled();
     table_->setSortingEnabled(false);
 
-    table_->setRowCount(0);
-    table_->setRowCount(devices.size());
-
-    for (int i = 0; i < devices.size(); ++i) {
-        const auto& d = devices[i];
-        table_->setItem(i, 0, new QTableWidgetItem(QString::number(d.id)));
-        table_->setItem(i, 1, new QTableWidgetItem(d.vendorName));
-        table_->setItem(i, 2, new QTableWidgetItem(d.modelName));
-        table_->setItem(i, 3, new QTableWidgetItem(d.locationName));
-        table_->setItem(i, 4, new QTableWidgetItem(d.serialNumber));
-        table_->setItem(i, 5, new QTableWidgetItem(d.inventoryNumber));
-        table_->setItem(i, 6, new QTableWidgetItem(d.status));
-        table_->setItem(i, 7, new QTableWidgetItem(d.installedAt));
+    const int offset = table_->rowCount();
+    table_->setRowCount(offset + page.items.size());
+
+    for (int i = 0; i < page.items.size(); ++i) {
+        const auto& d = page.items[i];
+        const int row = offset + i;
+        table_->setItem(row, 0, new QTableWidgetItem(QString::number(d.id)));
+        table_->setItem(row, 1, new QTableWidgetItem(d.vendorName));
+        table_->setItem(row, 2, new QTableWidgetItem(d.modelName));
+        table_->setItem(row, 3, new QTableWidgetItem(d.locationName));
+        table_->setItem(row, 4, new QTableWidgetItem(d.serialNumber));
+        table_->setItem(row, 5, new QTableWidgetItem(d.inventoryNumber));
+        table_->setItem(row, 6, new QTableWidgetItem(d.status));
+        table_->setItem(row, 7, new QTableWidgetItem(d.installedAt));
     }
 
     table_->setSortingEnabled(wasSorting);
+
+    if (countLabel_) {
+        countLabel_->setText(QString("Показано %1 из %2").arg(devices_.size()).arg(totalDevices_));
+    }
+    if (moreButton_) {
+        moreButton_->setEnabled(!nextPageToken_.isEmpty());
+    }
 }
 
 qint64 MainWindow::selectedDeviceId() const {
@@ -0,0 +1,65 @@
+-- Жизненный цикл устройства: фиксированный набор статусов и журнал переходов.
+-- Существующие свободные значения приводятся к каноническим статусам.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS device_status_transitions (
+    id          BIGSERIAL PRIMARY KEY,
+    device_id   BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    from_status TEXT,
+    to_status   TEXT NOT NULL,
+    reason      TEXT,
+    actor       TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_status_transitions_device ON device_status_transitions(device_id, created_at);
+
+-- Каждое переписанное значение попадает в журнал переходов с исходным
+-- текстом: нераспознанные статусы становятся 'active' не молча, а с записью
+-- "migrated from '<старое значение>'", по которой их можно найти и поправить.
+-- При повторном запуске неканонических статусов уже нет и журнал не растёт.
+WITH legacy AS (
+    SELECT d.id, d.status AS old_status,
+           CASE lower(btrim(d.status))
+               WHEN 'active' THEN 'active'
+               WHEN 'installed' THEN 'active'
+               WHEN 'в эксплуатации' THEN 'active'
+               WHEN 'установлено' THEN 'active'
+               WHEN 'in_stock' THEN 'in_stock'
+               WHEN 'stock' THEN 'in_stock'
+               WHEN 'на складе' THEN 'in_stock'
+               WHEN 'maintenance' THEN 'maintenance'
+               WHEN 'repair' THEN 'maintenance'
+               WHEN 'в ремонте' THEN 'maintenance'
+               WHEN 'faulty' THEN 'faulty'
+               WHEN 'broken' THEN 'faulty'
+               WHEN 'неисправно' THEN 'faulty'
+               WHEN 'decommissioned' THEN 'decommissioned'
+               WHEN 'выведено из эксплуатации' THEN 'decommissioned'
+               WHEN 'written_off' THEN 'written_off'
+               WHEN 'списано' THEN 'written_off'
+               ELSE 'active'
+           END AS new_status
+    FROM devices d
+    WHERE d.status NOT IN ('in_stock', 'active', 'maintenance', 'faulty', 'decommissioned', 'written_off')
+    FOR UPDATE
+), migrated AS (
+    UPDATE devices d
+    SET status = legacy.new_status
+    FROM legacy
+    WHERE d.id = legacy.id
+    RETURNING d.id, legacy.old_status, legacy.new_status
+)
+INSERT INTO device_status_transitions(device_id, from_status, to_status, reason, actor)
+SELECT id, old_status, new_status, format('migrated from %L', old_status), 'system'
+FROM migrated;
+
+ALTER TABLE devices
+    DROP CONSTRAINT IF EXISTS devices_status_check;
+
+ALTER TABLE devices
+    ADD CONSTRAINT devices_status_check
+    CHECK (status IN ('in_stock', 'active', 'maintenance', 'faulty', 'decommissioned', 'written_off'));
+
+COMMIT;
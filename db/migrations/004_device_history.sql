@@ -0,0 +1,17 @@
+-- История изменений устройств: кто, когда и какие поля поменял.
+-- device_id намеренно без внешнего ключа: история удалённого устройства сохраняется.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS device_history (
+    id         BIGSERIAL PRIMARY KEY,
+    device_id  BIGINT NOT NULL,
+    action     TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    actor      TEXT NOT NULL,
+    changes    JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_history_device ON device_history(device_id, created_at);
+
+COMMIT;
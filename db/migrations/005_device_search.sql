@@ -0,0 +1,79 @@
+-- Поиск устройств: денормализованный поисковый документ + trigram/full-text индексы.
+-- search_text собирает серийный и инвентарный номера, модель, производителя,
+-- локацию и описание; поддерживается триггерами на devices и справочниках.
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE devices
+    ADD COLUMN IF NOT EXISTS search_text TEXT,
+    ADD COLUMN IF NOT EXISTS search_tsv TSVECTOR;
+
+CREATE OR REPLACE FUNCTION devices_search_refresh() RETURNS trigger AS $$
+BEGIN
+    SELECT concat_ws(' ', NEW.serial_number, NEW.inventory_number, m.name, v.name, l.name, NEW.description)
+    INTO NEW.search_text
+    FROM models m
+    JOIN vendors v ON v.id = m.vendor_id
+    LEFT JOIN locations l ON l.id = NEW.location_id
+    WHERE m.id = NEW.model_id;
+
+    NEW.search_tsv := to_tsvector('simple', COALESCE(NEW.search_text, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS devices_search_refresh ON devices;
+CREATE TRIGGER devices_search_refresh
+    BEFORE INSERT OR UPDATE ON devices
+    FOR EACH ROW EXECUTE FUNCTION devices_search_refresh();
+
+-- Переименование в справочниках пересчитывает документы связанных устройств
+-- (пустой UPDATE запускает devices_search_refresh).
+CREATE OR REPLACE FUNCTION devices_search_touch_model() RETURNS trigger AS $$
+BEGIN
+    UPDATE devices SET search_text = NULL WHERE model_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION devices_search_touch_vendor() RETURNS trigger AS $$
+BEGIN
+    UPDATE devices SET search_text = NULL
+    WHERE model_id IN (SELECT id FROM models WHERE vendor_id = NEW.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION devices_search_touch_location() RETURNS trigger AS $$
+BEGIN
+    UPDATE devices SET search_text = NULL WHERE location_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS devices_search_touch ON models;
+CREATE TRIGGER devices_search_touch
+    AFTER UPDATE OF name, vendor_id ON models
+    FOR EACH ROW EXECUTE FUNCTION devices_search_touch_model();
+
+DROP TRIGGER IF EXISTS devices_search_touch ON vendors;
+CREATE TRIGGER devices_search_touch
+    AFTER UPDATE OF name ON vendors
+    FOR EACH ROW EXECUTE FUNCTION devices_search_touch_vendor();
+
+DROP TRIGGER IF EXISTS devices_search_touch ON locations;
+CREATE TRIGGER devices_search_touch
+    AFTER UPDATE OF name ON locations
+    FOR EACH ROW EXECUTE FUNCTION devices_search_touch_location();
+
+-- Разовое заполнение существующих строк. Миграции применяются при каждом
+-- запуске, а у заполненных строк search_tsv уже есть, поэтому повторно
+-- таблица не переписывается.
+UPDATE devices SET search_text = NULL WHERE search_tsv IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_devices_search_trgm ON devices USING GIN (search_text gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_devices_search_tsv ON devices USING GIN (search_tsv);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Версия строки устройства для оптимистичной блокировки (ETag/If-Match).
+-- version увеличивается запросами UPDATE из server/db/queries, а не триггером:
+-- служебные обновления search_text из 005_device_search.sql не должны менять ETag.
+
+BEGIN;
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+COMMIT;
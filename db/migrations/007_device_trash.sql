@@ -0,0 +1,16 @@
+-- Корзина устройств: DELETE /devices/{id} только помечает строку удалённой.
+-- Окончательно строка удаляется вручную (админ) или автоматически по истечении
+-- срока хранения (DEVICE_TRASH_RETENTION_DAYS).
+
+BEGIN;
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_devices_deleted_at ON devices(deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE device_history DROP CONSTRAINT IF EXISTS device_history_action_check;
+ALTER TABLE device_history ADD CONSTRAINT device_history_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'purge'));
+
+COMMIT;
@@ -0,0 +1,41 @@
+-- Нормализованные ключи серийного и инвентарного номеров.
+-- serial_key: без пробельных символов и в верхнем регистре, так что
+-- "ab 12-34" и "AB12-34" считаются одним серийником. Уникальность серийника
+-- теперь проверяется по serial_key вместо devices_serial_unique.
+-- inventory_key: обрезанный и в нижнем регистре; уникальность инвентарного
+-- номера включается на стороне API (INVENTORY_NUMBER_UNIQUE), поэтому здесь
+-- только обычный индекс для поиска.
+
+BEGIN;
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS serial_key TEXT
+    GENERATED ALWAYS AS (NULLIF(upper(regexp_replace(serial_number, '\s+', '', 'g')), '')) STORED;
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS inventory_key TEXT
+    GENERATED ALWAYS AS (NULLIF(lower(btrim(inventory_number)), '')) STORED;
+
+-- Серийники, совпадающие только после нормализации, миграция не объединяет:
+-- их нужно развести вручную, иначе уникальный индекс не создастся.
+DO $$
+DECLARE
+    dup TEXT;
+BEGIN
+    SELECT string_agg(serial_key || ' (id ' || ids || ')', '; ')
+    INTO dup
+    FROM (
+        SELECT serial_key, string_agg(id::text, ', ' ORDER BY id) AS ids
+        FROM devices
+        WHERE serial_key IS NOT NULL
+        GROUP BY serial_key
+        HAVING COUNT(*) > 1
+    ) d;
+    IF dup IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate serial numbers after normalization: %', dup;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS devices_serial_key_unique ON devices(serial_key);
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_serial_unique;
+
+CREATE INDEX IF NOT EXISTS idx_devices_inventory_key ON devices(inventory_key);
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- Пользовательские поля: администратор описывает типизированные поля для
+-- устройств, моделей, вендоров и локаций; значения хранятся в JSONB-колонке
+-- custom_fields соответствующей таблицы как {"<key>": <value>}.
+-- device_type ограничивает поле устройствами/моделями с этим типом модели.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS custom_field_definitions (
+    id          BIGSERIAL PRIMARY KEY,
+    scope       TEXT NOT NULL CHECK (scope IN ('device', 'model', 'vendor', 'location')),
+    key         TEXT NOT NULL CHECK (key ~ '^[a-z][a-z0-9_]{0,62}$'),
+    label       TEXT NOT NULL,
+    field_type  TEXT NOT NULL CHECK (field_type IN ('string', 'int', 'date', 'enum', 'bool')),
+    required    BOOLEAN NOT NULL DEFAULT false,
+    enum_values TEXT[] NOT NULL DEFAULT '{}',
+    device_type TEXT,
+    sort_order  INTEGER NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT custom_field_definitions_scope_key_unique UNIQUE (scope, key),
+    CONSTRAINT custom_field_definitions_device_type_scope
+        CHECK (device_type IS NULL OR scope IN ('device', 'model'))
+);
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE models ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE vendors ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS custom_fields JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_devices_custom_fields ON devices USING GIN (custom_fields jsonb_path_ops);
+
+COMMIT;
@@ -0,0 +1,49 @@
+-- Сетевая идентификация устройств: имя хоста в devices, IP- и MAC-адреса —
+-- в отдельных таблицах (у устройства может быть несколько адресов).
+-- Не больше одного IP на устройство помечается как адрес управления.
+
+BEGIN;
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS hostname TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_devices_hostname ON devices (hostname);
+
+CREATE TABLE IF NOT EXISTS device_ip_addresses (
+    id         BIGSERIAL PRIMARY KEY,
+    device_id  BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    address    INET NOT NULL,
+    management BOOLEAN NOT NULL DEFAULT false,
+    CONSTRAINT device_ip_addresses_device_address_unique UNIQUE (device_id, address)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS device_ip_addresses_management_unique
+    ON device_ip_addresses (device_id) WHERE management;
+-- Точный поиск сравнивает адрес без маски, поиск по подсети — оператором <<=.
+CREATE INDEX IF NOT EXISTS idx_device_ip_addresses_host ON device_ip_addresses (host(address));
+CREATE INDEX IF NOT EXISTS idx_device_ip_addresses_inet ON device_ip_addresses USING GIST (address inet_ops);
+
+CREATE TABLE IF NOT EXISTS device_mac_addresses (
+    id        BIGSERIAL PRIMARY KEY,
+    device_id BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    address   MACADDR NOT NULL,
+    CONSTRAINT device_mac_addresses_device_address_unique UNIQUE (device_id, address)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_mac_addresses_address ON device_mac_addresses (address);
+
+-- Имя хоста попадает в поисковый документ устройства.
+CREATE OR REPLACE FUNCTION devices_search_refresh() RETURNS trigger AS $$
+BEGIN
+    SELECT concat_ws(' ', NEW.serial_number, NEW.inventory_number, NEW.hostname, m.name, v.name, l.name, NEW.description)
+    INTO NEW.search_text
+    FROM models m
+    JOIN vendors v ON v.id = m.vendor_id
+    LEFT JOIN locations l ON l.id = NEW.location_id
+    WHERE m.id = NEW.model_id;
+
+    NEW.search_tsv := to_tsvector('simple', COALESCE(NEW.search_text, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
@@ -0,0 +1,51 @@
+-- IPAM: подсети (префиксы) с VRF, VLAN и локацией и учёт выделенных адресов.
+-- Адрес относится к префиксу по вхождению (address <<= prefix) в том же VRF,
+-- ссылки на префикс не хранятся: вложенные префиксы и их удаление не требуют
+-- переноса адресов. Пустой vrf — глобальная таблица маршрутизации.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS prefixes (
+    id          BIGSERIAL PRIMARY KEY,
+    prefix      CIDR NOT NULL,
+    vrf         TEXT NOT NULL DEFAULT '',
+    vlan_id     INTEGER CHECK (vlan_id BETWEEN 1 AND 4094),
+    location_id BIGINT REFERENCES locations(id),
+    description TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT prefixes_vrf_prefix_unique UNIQUE (vrf, prefix)
+);
+
+CREATE INDEX IF NOT EXISTS idx_prefixes_prefix ON prefixes USING GIST (prefix inet_ops);
+
+-- address хранится с маской префикса, в котором выделен (10.0.0.5/24);
+-- уникальность в пределах VRF — по самому адресу без маски.
+CREATE TABLE IF NOT EXISTS ip_addresses (
+    id          BIGSERIAL PRIMARY KEY,
+    address     INET NOT NULL,
+    vrf         TEXT NOT NULL DEFAULT '',
+    device_id   BIGINT REFERENCES devices(id) ON DELETE SET NULL,
+    status      TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'reserved', 'deprecated')),
+    description TEXT,
+    created_by  TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ip_addresses_vrf_host_unique ON ip_addresses (vrf, host(address));
+CREATE INDEX IF NOT EXISTS idx_ip_addresses_address ON ip_addresses USING GIST (address inet_ops);
+CREATE INDEX IF NOT EXISTS idx_ip_addresses_device ON ip_addresses (device_id);
+
+-- Занятые адреса подсети p в VRF v (без маски): выделенные в IPAM и, для
+-- глобального VRF, настроенные на устройствах (device_ip_addresses).
+-- Оператор && отбирает кандидатов по GiST-индексу, <<= проверяет сам адрес.
+CREATE OR REPLACE FUNCTION ipam_used_addresses(p CIDR, v TEXT) RETURNS SETOF INET AS $$
+    SELECT host(a.address)::inet
+    FROM ip_addresses a
+    WHERE a.vrf = v AND a.address && p AND host(a.address)::inet <<= p
+    UNION
+    SELECT host(d.address)::inet
+    FROM device_ip_addresses d
+    WHERE v = '' AND d.address && p AND host(d.address)::inet <<= p
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
@@ -0,0 +1,36 @@
+-- Интерфейсы (порты) устройств и физические линки между ними.
+-- Линк соединяет ровно два интерфейса; каждый интерфейс участвует не более
+-- чем в одном линке. Уникальные индексы закрывают повтор в одной колонке,
+-- пересечение a/b проверяет API под блокировкой строк интерфейсов.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS device_interfaces (
+    id          BIGSERIAL PRIMARY KEY,
+    device_id   BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    if_type     TEXT NOT NULL DEFAULT 'ethernet'
+                CHECK (if_type IN ('ethernet', 'optical', 'serial', 'wireless', 'virtual', 'lag', 'other')),
+    speed_mbps  BIGINT CHECK (speed_mbps > 0),
+    admin_state TEXT NOT NULL DEFAULT 'up' CHECK (admin_state IN ('up', 'down')),
+    mac_address MACADDR,
+    description TEXT,
+    CONSTRAINT device_interfaces_device_name_unique UNIQUE (device_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_interfaces_mac ON device_interfaces (mac_address);
+
+CREATE TABLE IF NOT EXISTS links (
+    id             BIGSERIAL PRIMARY KEY,
+    a_interface_id BIGINT NOT NULL REFERENCES device_interfaces(id) ON DELETE CASCADE,
+    b_interface_id BIGINT NOT NULL REFERENCES device_interfaces(id) ON DELETE CASCADE,
+    cable_type     TEXT,
+    description    TEXT,
+    created_by     TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT links_distinct_ends CHECK (a_interface_id <> b_interface_id),
+    CONSTRAINT links_a_interface_unique UNIQUE (a_interface_id),
+    CONSTRAINT links_b_interface_unique UNIQUE (b_interface_id)
+);
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- Иерархия локаций: площадка → здание → этаж → помещение → стойка.
+-- Тип вложенной локации всегда «глубже» типа родителя; циклы и порядок типов
+-- проверяет API под блокировкой дерева. Существующие локации становятся
+-- корневыми площадками.
+
+BEGIN;
+
+ALTER TABLE locations
+    ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES locations(id) ON DELETE RESTRICT,
+    ADD COLUMN IF NOT EXISTS location_type TEXT NOT NULL DEFAULT 'site';
+
+ALTER TABLE locations DROP CONSTRAINT IF EXISTS locations_type_check;
+ALTER TABLE locations ADD CONSTRAINT locations_type_check
+    CHECK (location_type IN ('site', 'building', 'floor', 'room', 'rack'));
+
+ALTER TABLE locations DROP CONSTRAINT IF EXISTS locations_parent_not_self;
+ALTER TABLE locations ADD CONSTRAINT locations_parent_not_self CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_locations_parent ON locations (parent_id);
+
+-- id локации root и всех вложенных в неё.
+CREATE OR REPLACE FUNCTION location_subtree(root BIGINT) RETURNS SETOF BIGINT AS $$
+    WITH RECURSIVE t(id) AS (
+        SELECT root
+        UNION
+        SELECT l.id FROM locations l JOIN t ON l.parent_id = t.id
+    )
+    SELECT id FROM t
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
@@ -0,0 +1,36 @@
+-- Размещение устройств в стойках по юнитам.
+-- Стойка — локация типа rack с высотой в U; модель задаёт высоту устройства и
+-- глубину (полноразмерное занимает обе стороны стойки). Устройство в стойке
+-- хранит нижний занятый юнит и сторону монтажа. Пересечения проверяет API под
+-- блокировкой строки стойки.
+
+BEGIN;
+
+ALTER TABLE locations
+    ADD COLUMN IF NOT EXISTS rack_height_u INTEGER;
+
+ALTER TABLE locations DROP CONSTRAINT IF EXISTS locations_rack_height_check;
+ALTER TABLE locations ADD CONSTRAINT locations_rack_height_check
+    CHECK (rack_height_u IS NULL OR (location_type = 'rack' AND rack_height_u BETWEEN 1 AND 100));
+
+ALTER TABLE models
+    ADD COLUMN IF NOT EXISTS height_u INTEGER NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS full_depth BOOLEAN NOT NULL DEFAULT true;
+
+ALTER TABLE models DROP CONSTRAINT IF EXISTS models_height_u_check;
+ALTER TABLE models ADD CONSTRAINT models_height_u_check CHECK (height_u BETWEEN 1 AND 100);
+
+ALTER TABLE devices
+    ADD COLUMN IF NOT EXISTS rack_position_u INTEGER,
+    ADD COLUMN IF NOT EXISTS rack_face TEXT;
+
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_rack_placement_check;
+ALTER TABLE devices ADD CONSTRAINT devices_rack_placement_check
+    CHECK ((rack_position_u IS NULL AND rack_face IS NULL)
+        OR (rack_position_u >= 1 AND rack_face IN ('front', 'rear') AND location_id IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_devices_rack_position
+    ON devices (location_id, rack_position_u)
+    WHERE rack_position_u IS NOT NULL;
+
+COMMIT;
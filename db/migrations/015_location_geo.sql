@@ -0,0 +1,20 @@
+-- Координаты (WGS 84) и почтовый адрес локаций для карты площадок.
+-- Широта и долгота задаются только парой.
+
+BEGIN;
+
+ALTER TABLE locations
+    ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS address TEXT;
+
+ALTER TABLE locations DROP CONSTRAINT IF EXISTS locations_coordinates_check;
+ALTER TABLE locations ADD CONSTRAINT locations_coordinates_check
+    CHECK ((latitude IS NULL AND longitude IS NULL)
+        OR (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180));
+
+CREATE INDEX IF NOT EXISTS idx_locations_coordinates
+    ON locations (latitude, longitude)
+    WHERE latitude IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,34 @@
+-- Гарантийные и сервисные контракты. Контракт покрывает набор устройств
+-- (многие-ко-многим); действующим считается контракт, в период которого
+-- попадает текущая дата. Номер контракта уникален в пределах поставщика.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS contracts (
+    id              BIGSERIAL PRIMARY KEY,
+    contract_number TEXT NOT NULL,
+    supplier        TEXT NOT NULL,
+    kind            TEXT NOT NULL DEFAULT 'support' CHECK (kind IN ('warranty', 'support')),
+    coverage_level  TEXT,
+    starts_on       DATE NOT NULL,
+    ends_on         DATE NOT NULL,
+    note            TEXT,
+    created_by      TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT contracts_period_check CHECK (ends_on >= starts_on)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS contracts_supplier_number_unique
+    ON contracts (lower(supplier), lower(contract_number));
+
+CREATE INDEX IF NOT EXISTS idx_contracts_ends_on ON contracts (ends_on);
+
+CREATE TABLE IF NOT EXISTS contract_devices (
+    contract_id BIGINT NOT NULL REFERENCES contracts(id) ON DELETE CASCADE,
+    device_id   BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    PRIMARY KEY (contract_id, device_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_contract_devices_device ON contract_devices (device_id);
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- Заявки на обслуживание устройств: ремонт, плановое обслуживание, апгрейд.
+-- return_status — статус, из которого заявка перевела устройство в
+-- maintenance при открытии; при закрытии устройство можно вернуть в него.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS work_orders (
+    id            BIGSERIAL PRIMARY KEY,
+    device_id     BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    order_type    TEXT NOT NULL CHECK (order_type IN ('repair', 'planned_maintenance', 'upgrade')),
+    state         TEXT NOT NULL DEFAULT 'open'
+                  CHECK (state IN ('open', 'in_progress', 'on_hold', 'done', 'cancelled')),
+    title         TEXT NOT NULL,
+    notes         TEXT,
+    resolution    TEXT,
+    assignee_id   BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    scheduled_at  TIMESTAMPTZ,
+    return_status TEXT,
+    created_by    TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at    TIMESTAMPTZ,
+    closed_at     TIMESTAMPTZ,
+    CONSTRAINT work_orders_closed_check CHECK ((state IN ('done', 'cancelled')) = (closed_at IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_orders_device ON work_orders (device_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_work_orders_state ON work_orders (state);
+CREATE INDEX IF NOT EXISTS idx_work_orders_assignee ON work_orders (assignee_id);
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- Жизненный цикл моделей по данным вендора: окончание продаж (end of sale),
+-- окончание поддержки (end of support) и снятие с обслуживания (end of life),
+-- а также рекомендуемая модель на замену. По этим датам строится отчёт о
+-- парке, который пора менять.
+
+BEGIN;
+
+ALTER TABLE models
+    ADD COLUMN IF NOT EXISTS end_of_sale DATE,
+    ADD COLUMN IF NOT EXISTS end_of_support DATE,
+    ADD COLUMN IF NOT EXISTS end_of_life DATE,
+    ADD COLUMN IF NOT EXISTS replacement_model_id BIGINT;
+
+ALTER TABLE models DROP CONSTRAINT IF EXISTS models_replacement_model_fkey;
+ALTER TABLE models ADD CONSTRAINT models_replacement_model_fkey
+    FOREIGN KEY (replacement_model_id) REFERENCES models(id) ON DELETE SET NULL;
+
+ALTER TABLE models DROP CONSTRAINT IF EXISTS models_lifecycle_check;
+ALTER TABLE models ADD CONSTRAINT models_lifecycle_check
+    CHECK ((end_of_sale IS NULL OR end_of_life IS NULL OR end_of_sale <= end_of_life)
+       AND (end_of_support IS NULL OR end_of_life IS NULL OR end_of_support <= end_of_life)
+       AND (end_of_sale IS NULL OR end_of_support IS NULL OR end_of_sale <= end_of_support)
+       AND (replacement_model_id IS NULL OR replacement_model_id <> id));
+
+CREATE INDEX IF NOT EXISTS idx_models_end_of_life ON models (end_of_life) WHERE end_of_life IS NOT NULL;
+
+COMMIT;
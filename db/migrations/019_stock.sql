@@ -0,0 +1,48 @@
+-- Складской учёт несерийных позиций: SFP-модули, патч-корды, блоки питания.
+-- Остаток хранится по паре «модель + локация» с минимальным порогом; каждое
+-- поступление, выдача и перемещение записывается в журнал движений. Модели и
+-- локации, по которым есть остатки или движения, удалить нельзя.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS stock_items (
+    id           BIGSERIAL PRIMARY KEY,
+    model_id     BIGINT NOT NULL,
+    location_id  BIGINT NOT NULL,
+    quantity     INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    min_quantity INTEGER NOT NULL DEFAULT 0 CHECK (min_quantity >= 0),
+    note         TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT stock_items_model_fkey FOREIGN KEY (model_id) REFERENCES models(id),
+    CONSTRAINT stock_items_location_fkey FOREIGN KEY (location_id) REFERENCES locations(id),
+    CONSTRAINT stock_items_model_location_unique UNIQUE (model_id, location_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_items_location ON stock_items (location_id);
+
+-- Поступление приходит только в to_location_id, выдача — только из
+-- from_location_id, перемещение — между двумя разными локациями.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id               BIGSERIAL PRIMARY KEY,
+    kind             TEXT NOT NULL CHECK (kind IN ('receive', 'issue', 'transfer')),
+    model_id         BIGINT NOT NULL REFERENCES models(id),
+    from_location_id BIGINT REFERENCES locations(id),
+    to_location_id   BIGINT REFERENCES locations(id),
+    quantity         INTEGER NOT NULL CHECK (quantity > 0),
+    reason           TEXT,
+    document_number  TEXT,
+    actor            TEXT NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT stock_movements_locations_check CHECK (
+        (kind = 'receive' AND from_location_id IS NULL AND to_location_id IS NOT NULL)
+     OR (kind = 'issue' AND from_location_id IS NOT NULL AND to_location_id IS NULL)
+     OR (kind = 'transfer' AND from_location_id IS NOT NULL AND to_location_id IS NOT NULL
+         AND from_location_id <> to_location_id))
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_model ON stock_movements (model_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_from ON stock_movements (from_location_id) WHERE from_location_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_movements_to ON stock_movements (to_location_id) WHERE to_location_id IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,43 @@
+-- Журнал перемещений устройств между локациями. Перемещение — документ:
+-- кто, когда, куда, по какой причине и с каким номером акта; строки —
+-- перемещённые устройства с исходной локацией. Названия локаций копируются
+-- в журнал, чтобы акт не менялся при переименовании или удалении локаций.
+
+BEGIN;
+
+-- Полный путь локации от корня через « / »; NULL для несуществующей.
+CREATE OR REPLACE FUNCTION location_path(loc BIGINT) RETURNS TEXT AS $$
+    WITH RECURSIVE up(id, parent_id, name, depth) AS (
+        SELECT id, parent_id, name, 0 FROM locations WHERE id = loc
+        UNION ALL
+        SELECT l.id, l.parent_id, l.name, up.depth + 1
+        FROM locations l JOIN up ON l.id = up.parent_id
+    )
+    SELECT string_agg(name, ' / ' ORDER BY depth DESC) FROM up
+$$ LANGUAGE sql STABLE;
+
+CREATE TABLE IF NOT EXISTS device_transfers (
+    id               BIGSERIAL PRIMARY KEY,
+    to_location_id   BIGINT REFERENCES locations(id) ON DELETE SET NULL,
+    to_location_name TEXT NOT NULL DEFAULT '',
+    reason           TEXT,
+    document_number  TEXT,
+    actor            TEXT NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_transfers_created ON device_transfers (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_device_transfers_to ON device_transfers (to_location_id);
+
+CREATE TABLE IF NOT EXISTS device_transfer_items (
+    transfer_id        BIGINT NOT NULL REFERENCES device_transfers(id) ON DELETE CASCADE,
+    device_id          BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    from_location_id   BIGINT REFERENCES locations(id) ON DELETE SET NULL,
+    from_location_name TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (transfer_id, device_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_transfer_items_device ON device_transfer_items (device_id);
+CREATE INDEX IF NOT EXISTS idx_device_transfer_items_from ON device_transfer_items (from_location_id);
+
+COMMIT;
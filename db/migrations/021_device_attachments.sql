@@ -0,0 +1,30 @@
+-- Файлы, приложенные к устройствам: фото смонтированного оборудования,
+-- сканы накладных, документация. Содержимое хранится один раз на SHA-256
+-- (attachment_blobs), вложения устройств ссылаются на него. Сам файл лежит в
+-- файловом хранилище API под storage_key; ключ уникален для каждой записи
+-- attachment_blobs, поэтому удаление осиротевшего файла не задевает тот же
+-- файл, загруженный заново.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS attachment_blobs (
+    sha256      TEXT PRIMARY KEY,
+    size_bytes  BIGINT NOT NULL CHECK (size_bytes >= 0),
+    mime_type   TEXT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS device_attachments (
+    id          BIGSERIAL PRIMARY KEY,
+    device_id   BIGINT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    sha256      TEXT NOT NULL REFERENCES attachment_blobs(sha256),
+    filename    TEXT NOT NULL,
+    uploaded_by TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT device_attachments_device_sha_unique UNIQUE (device_id, sha256)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_attachments_sha ON device_attachments (sha256);
+
+COMMIT;
@@ -0,0 +1,68 @@
+-- Строка акта перемещения хранит снимок устройства на момент перемещения:
+-- имя, вендора, модель, серийный и инвентарный номер. Подписанный акт не
+-- меняется при правке устройства и не теряет строк при его окончательном
+-- удалении — ссылка на устройство обнуляется. Поскольку device_id может быть
+-- пустым, первичным ключом строки становится собственный id; он же задаёт
+-- порядок строк в акте.
+
+BEGIN;
+
+ALTER TABLE device_transfer_items
+    ADD COLUMN IF NOT EXISTS id BIGSERIAL,
+    ADD COLUMN IF NOT EXISTS hostname TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS vendor_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS model_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS serial_number TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS inventory_number TEXT NOT NULL DEFAULT '';
+
+-- Строки, записанные до снимков, заполняются один раз текущими данными
+-- устройства: у заполненных модель уже не пустая.
+UPDATE device_transfer_items i
+SET hostname = COALESCE(d.hostname, ''),
+    vendor_name = v.name,
+    model_name = m.name,
+    serial_number = COALESCE(d.serial_number, ''),
+    inventory_number = COALESCE(d.inventory_number, '')
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+WHERE d.id = i.device_id AND i.model_name = '';
+
+-- Ключи пересоздаются только один раз: миграции применяются при каждом
+-- запуске, а пересборка первичного ключа и проверка внешнего держат
+-- исключительную блокировку таблицы.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_constraint c
+        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY(c.conkey)
+        WHERE c.conrelid = 'device_transfer_items'::regclass
+          AND c.conname = 'device_transfer_items_pkey'
+          AND c.contype = 'p'
+          AND array_length(c.conkey, 1) = 1
+          AND a.attname = 'id'
+    ) THEN
+        ALTER TABLE device_transfer_items DROP CONSTRAINT IF EXISTS device_transfer_items_pkey;
+        ALTER TABLE device_transfer_items ADD CONSTRAINT device_transfer_items_pkey PRIMARY KEY (id);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'device_transfer_items'::regclass
+          AND conname = 'device_transfer_items_device_id_fkey'
+          AND confdeltype = 'n'
+    ) THEN
+        ALTER TABLE device_transfer_items DROP CONSTRAINT IF EXISTS device_transfer_items_device_id_fkey;
+        ALTER TABLE device_transfer_items ADD CONSTRAINT device_transfer_items_device_id_fkey
+            FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE SET NULL;
+    END IF;
+END $$;
+
+ALTER TABLE device_transfer_items ALTER COLUMN device_id DROP NOT NULL;
+
+-- Устройство входит в документ один раз; строки удалённых устройств не
+-- мешают друг другу (NULL не равен NULL).
+CREATE UNIQUE INDEX IF NOT EXISTS uq_device_transfer_items_device ON device_transfer_items (transfer_id, device_id);
+
+COMMIT;
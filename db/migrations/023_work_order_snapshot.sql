@@ -0,0 +1,46 @@
+-- Заявка хранит снимок устройства на момент открытия: имя, вендора, модель,
+-- серийный и инвентарный номер. История обслуживания не пропадает при
+-- окончательном удалении устройства из корзины — ссылка на устройство
+-- обнуляется, как у строк актов перемещения.
+
+BEGIN;
+
+ALTER TABLE work_orders
+    ADD COLUMN IF NOT EXISTS hostname TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS vendor_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS model_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS serial_number TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS inventory_number TEXT NOT NULL DEFAULT '';
+
+-- Заявки, открытые до снимков, заполняются один раз текущими данными
+-- устройства: у заполненных модель уже не пустая.
+UPDATE work_orders wo
+SET hostname = COALESCE(d.hostname, ''),
+    vendor_name = v.name,
+    model_name = m.name,
+    serial_number = COALESCE(d.serial_number, ''),
+    inventory_number = COALESCE(d.inventory_number, '')
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+WHERE d.id = wo.device_id AND wo.model_name = '';
+
+ALTER TABLE work_orders ALTER COLUMN device_id DROP NOT NULL;
+
+-- Внешний ключ пересоздаётся только пока он каскадный: миграции применяются
+-- при каждом запуске, а повторная проверка ключа читает всю таблицу.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'work_orders'::regclass
+          AND conname = 'work_orders_device_id_fkey'
+          AND confdeltype = 'c'
+    ) THEN
+        ALTER TABLE work_orders DROP CONSTRAINT work_orders_device_id_fkey;
+        ALTER TABLE work_orders ADD CONSTRAINT work_orders_device_id_fkey
+            FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE SET NULL;
+    END IF;
+END $$;
+
+COMMIT;
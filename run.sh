@@ -83,10 +83,11 @@ for i in {1..40}; do
   fi
 done
 
-# Apply migrations (idempotent)
-if [[ -f db/migrations/002_user_approval.sql ]]; then
-  compose exec -T db psql -U telecombase -d telecombase < db/migrations/002_user_approval.sql >/dev/null
-fi
+# Apply migrations (idempotent, in lexical order)
+for migration in db/migrations/*.sql; do
+  [[ -f "$migration" ]] || continue
+  compose exec -T db psql -v ON_ERROR_STOP=1 -U telecombase -d telecombase < "$migration" >/dev/null
+done
 
 # Health check
 echo "Checking API health..."
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/filestore"
+	"telecombase/server/internal/store"
+)
+
+const (
+	// attachmentFormOverhead — запас сверх размера файла на заголовки и
+	// остальные поля multipart-формы.
+	attachmentFormOverhead = 1 << 20
+	attachmentFilenameMax  = 255
+	attachmentSniffLen     = 512
+)
+
+type deviceAttachmentItem struct {
+	Id         int64  `json:"id"`
+	DeviceId   int64  `json:"deviceId"`
+	Filename   string `json:"filename"`
+	MimeType   string `json:"mimeType"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	Sha256     string `json:"sha256"`
+	UploadedBy string `json:"uploadedBy"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// deviceAttachmentCreateResponse — загруженное вложение. Duplicate — тот же
+// файл уже был приложен к устройству, новое вложение не создано.
+type deviceAttachmentCreateResponse struct {
+	deviceAttachmentItem
+	Duplicate bool `json:"duplicate"`
+}
+
+func toDeviceAttachmentItem(row store.DeviceAttachmentRow) deviceAttachmentItem {
+	return deviceAttachmentItem{
+		Id:         row.ID,
+		DeviceId:   row.DeviceID,
+		Filename:   row.Filename,
+		MimeType:   row.MimeType,
+		SizeBytes:  row.SizeBytes,
+		Sha256:     row.SHA256,
+		UploadedBy: row.UploadedBy,
+		CreatedAt:  row.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func parseDeviceAttachmentPath(r *http.Request) (deviceID, attachmentID int64, ok bool) {
+	deviceID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || deviceID <= 0 {
+		return 0, 0, false
+	}
+	attachmentID, err = strconv.ParseInt(r.PathValue("attachmentId"), 10, 64)
+	if err != nil || attachmentID <= 0 {
+		return 0, 0, false
+	}
+	return deviceID, attachmentID, true
+}
+
+// parseAttachmentTypes разбирает список разрешённых MIME-типов через запятую.
+func parseAttachmentTypes(s string) map[string]bool {
+	types := map[string]bool{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types[t] = true
+		}
+	}
+	return types
+}
+
+// sanitizeAttachmentFilename оставляет от присланного имени только последний
+// элемент пути без управляющих символов.
+func sanitizeAttachmentFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > attachmentFilenameMax {
+		name = string(runes[:attachmentFilenameMax])
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// attachmentStorageKey — ключ нового файла в хранилище. Случайный суффикс
+// делает ключ уникальным даже для того же содержимого, загруженного после
+// очистки.
+func attachmentStorageKey(sha string) (string, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", err
+	}
+	return sha[:2] + "/" + sha + "-" + hex.EncodeToString(suffix[:]), nil
+}
+
+// removeAttachmentFiles удаляет из хранилища файлы, записи о которых уже
+// удалены из базы. Ошибки только пишутся в лог: запись в базе удалена, и
+// файл больше никем не используется.
+func (a *app) removeAttachmentFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := a.files.Delete(ctx, key); err != nil {
+			log.Printf("attachment file %s: %v", key, err)
+		}
+	}
+}
+
+func (a *app) handleDeviceAttachmentsList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	exists, err := a.st.DeviceExists(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	rows, err := a.st.ListDeviceAttachments(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]deviceAttachmentItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, toDeviceAttachmentItem(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleDeviceAttachmentsCreate принимает файл из поля file формы
+// multipart/form-data. Файл читается потоком во временный файл с подсчётом
+// SHA-256; тип определяется по содержимому, а не по заголовку клиента.
+// Одинаковое содержимое хранится один раз.
+func (a *app) handleDeviceAttachmentsCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	ctx := r.Context()
+	exists, err := a.st.DeviceExists(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	maxBytes := a.cfg.attachmentsMaxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+attachmentFormOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_multipart"})
+		return
+	}
+	var part io.ReadCloser
+	var filename string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "file_required"})
+			return
+		}
+		if err != nil {
+			writeAttachmentReadError(w, err)
+			return
+		}
+		if p.FormName() == "file" {
+			part, filename = p, sanitizeAttachmentFilename(p.FileName())
+			break
+		}
+		p.Close()
+	}
+	defer part.Close()
+
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "storage_error"})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(part, maxBytes+1))
+	if err != nil {
+		writeAttachmentReadError(w, err)
+		return
+	}
+	if size > maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "file_too_large"})
+		return
+	}
+	if size == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "empty_file"})
+		return
+	}
+
+	head := make([]byte, attachmentSniffLen)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "storage_error"})
+		return
+	}
+	mimeType := http.DetectContentType(head[:n])
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || !a.cfg.attachmentsAllowedTypes[mediaType] {
+		writeJSON(w, http.StatusUnsupportedMediaType, apiError{Error: "unsupported_media_type"})
+		return
+	}
+
+	sha := hex.EncodeToString(hash.Sum(nil))
+	newKey, err := attachmentStorageKey(sha)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "storage_error"})
+		return
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	key, inserted, err := qtx.UpsertAttachmentBlob(ctx, store.AttachmentBlob{
+		SHA256:     sha,
+		SizeBytes:  size,
+		MimeType:   mimeType,
+		StorageKey: newKey,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if inserted {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "storage_error"})
+			return
+		}
+		if err := a.files.Put(ctx, key, tmp); err != nil {
+			log.Printf("attachment file %s: %v", key, err)
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "storage_error"})
+			return
+		}
+	}
+	// Файл уже в хранилище: если транзакция не зафиксируется, он никому не
+	// будет нужен.
+	committed := false
+	defer func() {
+		if inserted && !committed {
+			a.removeAttachmentFiles(context.WithoutCancel(ctx), []string{key})
+		}
+	}()
+
+	attachmentID, created, err := qtx.CreateDeviceAttachment(ctx, id, sha, filename, authUsername(ctx))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	row, err := qtx.GetDeviceAttachment(ctx, id, attachmentID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	committed = true
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, deviceAttachmentCreateResponse{deviceAttachmentItem: toDeviceAttachmentItem(row), Duplicate: !created})
+}
+
+func writeAttachmentReadError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "file_too_large"})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_multipart"})
+}
+
+// handleDeviceAttachmentsDownload отдаёт файл вложения потоком под исходным
+// именем.
+func (a *app) handleDeviceAttachmentsDownload(w http.ResponseWriter, r *http.Request) {
+	deviceID, attachmentID, ok := parseDeviceAttachmentPath(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	ctx := r.Context()
+	row, err := a.st.GetDeviceAttachment(ctx, deviceID, attachmentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	f, err := a.files.Open(ctx, row.StorageKey)
+	if errors.Is(err, filestore.ErrNotFound) {
+		log.Printf("attachment file %s: missing for attachment %d", row.StorageKey, row.ID)
+		writeJSON(w, http.StatusNotFound, apiError{Error: "file_not_found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "storage_error"})
+		return
+	}
+	defer f.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", row.MimeType)
+	h.Set("Content-Length", strconv.FormatInt(row.SizeBytes, 10))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": row.Filename}))
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("attachment %d download: %v", row.ID, err)
+	}
+}
+
+func (a *app) handleDeviceAttachmentsDelete(w http.ResponseWriter, r *http.Request) {
+	deviceID, attachmentID, ok := parseDeviceAttachmentPath(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	affected, err := qtx.DeleteDeviceAttachment(ctx, deviceID, attachmentID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+	keys, err := qtx.DeleteOrphanAttachmentBlobs(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	a.removeAttachmentFiles(ctx, keys)
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
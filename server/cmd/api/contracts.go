@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	contractKindWarranty = "warranty"
+	contractKindSupport  = "support"
+
+	contractStateFuture  = "future"
+	contractStateActive  = "active"
+	contractStateExpired = "expired"
+
+	contractsUniqueConstraint = "contracts_supplier_number_unique"
+	contractDevicesMax        = 10000
+
+	// coverageExpiringDays — за сколько дней до окончания покрытие в карточке
+	// устройства помечается как истекающее.
+	coverageExpiringDays = 30
+	// warrantyReportMaxDays ограничивает горизонт отчёта об истекающих контрактах.
+	warrantyReportMaxDays = 3650
+)
+
+// Состояние покрытия устройства в карточке.
+const (
+	coverageCovered  = "covered"
+	coverageExpiring = "expiring"
+	coverageExpired  = "expired"
+	coverageNone     = "none"
+)
+
+type contractUpsertRequest struct {
+	ContractNumber string `json:"contractNumber"`
+	Supplier       string `json:"supplier"`
+	Kind           string `json:"kind"`
+	CoverageLevel  string `json:"coverageLevel"`
+	StartsOn       string `json:"startsOn"`
+	EndsOn         string `json:"endsOn"`
+	Note           string `json:"note"`
+	// DeviceIds заменяет набор покрываемых устройств; при обновлении
+	// отсутствие ключа оставляет набор как есть.
+	DeviceIds []int64 `json:"deviceIds"`
+}
+
+type contractItem struct {
+	Id             int64  `json:"id"`
+	ContractNumber string `json:"contractNumber"`
+	Supplier       string `json:"supplier"`
+	Kind           string `json:"kind"`
+	CoverageLevel  string `json:"coverageLevel"`
+	StartsOn       string `json:"startsOn"`
+	EndsOn         string `json:"endsOn"`
+	State          string `json:"state"`
+	Note           string `json:"note"`
+	DeviceCount    int64  `json:"deviceCount"`
+	CreatedBy      string `json:"createdBy"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+type contractDeviceItem struct {
+	Id              int64  `json:"id"`
+	Label           string `json:"label"`
+	Hostname        string `json:"hostname"`
+	VendorName      string `json:"vendorName"`
+	ModelName       string `json:"modelName"`
+	SerialNumber    string `json:"serialNumber"`
+	InventoryNumber string `json:"inventoryNumber"`
+	LocationName    string `json:"locationName"`
+	Status          string `json:"status"`
+}
+
+type contractDetailsResponse struct {
+	contractItem
+	Devices []contractDeviceItem `json:"devices"`
+}
+
+// deviceCoverageResponse — текущее покрытие в карточке устройства. Поля
+// контракта заполнены только для covered и expiring.
+type deviceCoverageResponse struct {
+	Status         string `json:"status"`
+	ContractId     *int64 `json:"contractId,omitempty"`
+	ContractNumber string `json:"contractNumber,omitempty"`
+	Supplier       string `json:"supplier,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+	CoverageLevel  string `json:"coverageLevel,omitempty"`
+	EndsOn         string `json:"endsOn,omitempty"`
+	DaysLeft       *int32 `json:"daysLeft,omitempty"`
+}
+
+type warrantyExpiringItem struct {
+	ContractId     int64              `json:"contractId"`
+	ContractNumber string             `json:"contractNumber"`
+	Supplier       string             `json:"supplier"`
+	Kind           string             `json:"kind"`
+	CoverageLevel  string             `json:"coverageLevel"`
+	EndsOn         string             `json:"endsOn"`
+	DaysLeft       int32              `json:"daysLeft"`
+	Device         contractDeviceItem `json:"device"`
+}
+
+type warrantyExpiringResponse struct {
+	Days  int32                  `json:"days"`
+	Items []warrantyExpiringItem `json:"items"`
+}
+
+type contractDeviceNotFound struct {
+	Error    string `json:"error"`
+	DeviceId int64  `json:"deviceId"`
+}
+
+func contractItemFromRow(row store.ContractRow) contractItem {
+	return contractItem{
+		Id:             row.ID,
+		ContractNumber: row.ContractNumber,
+		Supplier:       row.Supplier,
+		Kind:           row.Kind,
+		CoverageLevel:  row.CoverageLevel,
+		StartsOn:       row.StartsOn,
+		EndsOn:         row.EndsOn,
+		State:          row.State,
+		Note:           row.Note,
+		DeviceCount:    row.DeviceCount,
+		CreatedBy:      row.CreatedBy,
+		CreatedAt:      row.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func contractDeviceItemFromRow(row store.ContractDeviceRow) contractDeviceItem {
+	return contractDeviceItem{
+		Id:              row.ID,
+		Label:           deviceLabel(row.Hostname, row.VendorName, row.ModelName, row.SerialNumber),
+		Hostname:        row.Hostname,
+		VendorName:      row.VendorName,
+		ModelName:       row.ModelName,
+		SerialNumber:    row.SerialNumber,
+		InventoryNumber: row.InventoryNumber,
+		LocationName:    row.LocationName,
+		Status:          row.Status,
+	}
+}
+
+func deviceCoverageFromRow(row store.GetDeviceByIDRow) deviceCoverageResponse {
+	cov := row.Coverage
+	if cov == nil {
+		if row.HasExpiredContracts {
+			return deviceCoverageResponse{Status: coverageExpired}
+		}
+		return deviceCoverageResponse{Status: coverageNone}
+	}
+	status := coverageCovered
+	if cov.DaysLeft <= coverageExpiringDays {
+		status = coverageExpiring
+	}
+	return deviceCoverageResponse{
+		Status:         status,
+		ContractId:     &cov.ContractID,
+		ContractNumber: cov.ContractNumber,
+		Supplier:       cov.Supplier,
+		Kind:           cov.Kind,
+		CoverageLevel:  cov.CoverageLevel,
+		EndsOn:         cov.EndsOn,
+		DaysLeft:       &cov.DaysLeft,
+	}
+}
+
+func normalizeContractKind(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", contractKindSupport:
+		return contractKindSupport, true
+	case contractKindWarranty:
+		return contractKindWarranty, true
+	}
+	return "", false
+}
+
+func validateContractUpsert(req contractUpsertRequest) (store.ContractParams, string) {
+	p := store.ContractParams{
+		ContractNumber: strings.TrimSpace(req.ContractNumber),
+		Supplier:       strings.TrimSpace(req.Supplier),
+		CoverageLevel:  nullIfEmpty(strings.TrimSpace(req.CoverageLevel)),
+		Note:           nullIfEmpty(strings.TrimSpace(req.Note)),
+	}
+	if p.ContractNumber == "" {
+		return p, "contract_number_required"
+	}
+	if p.Supplier == "" {
+		return p, "supplier_required"
+	}
+	kind, ok := normalizeContractKind(req.Kind)
+	if !ok {
+		return p, "invalid_kind"
+	}
+	p.Kind = kind
+
+	startsOn, err := parseDateYYYYMMDD(req.StartsOn)
+	if err != nil || startsOn == nil {
+		return p, "invalid_starts_on"
+	}
+	endsOn, err := parseDateYYYYMMDD(req.EndsOn)
+	if err != nil || endsOn == nil {
+		return p, "invalid_ends_on"
+	}
+	if endsOn.Before(*startsOn) {
+		return p, "invalid_period"
+	}
+	p.StartsOn, p.EndsOn = *startsOn, *endsOn
+
+	if len(req.DeviceIds) > contractDevicesMax {
+		return p, "too_many_devices"
+	}
+	seen := make(map[int64]bool, len(req.DeviceIds))
+	for _, id := range req.DeviceIds {
+		if id <= 0 {
+			return p, "invalid_device_id"
+		}
+		if seen[id] {
+			return p, "duplicate_device_id"
+		}
+		seen[id] = true
+	}
+	return p, ""
+}
+
+// saveContractDevices заменяет устройства контракта, если список передан.
+// Возвращает первый несуществующий id, если такой есть.
+func saveContractDevices(ctx context.Context, q *store.Queries, contractID int64, deviceIDs []int64) (*int64, error) {
+	if deviceIDs == nil {
+		return nil, nil
+	}
+	if len(deviceIDs) > 0 {
+		missing, err := q.ListMissingDeviceIDs(ctx, deviceIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return &missing[0], nil
+		}
+	}
+	return nil, q.ReplaceContractDevices(ctx, contractID, deviceIDs)
+}
+
+func (a *app) handleContractsList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.ContractFilter{Query: strings.TrimSpace(q.Get("q"))}
+	var err error
+	if f.DeviceID, err = parseOptionalID(q.Get("device_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_device_id"})
+		return
+	}
+	if v := strings.ToLower(strings.TrimSpace(q.Get("state"))); v != "" {
+		if v != contractStateFuture && v != contractStateActive && v != contractStateExpired {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_state"})
+			return
+		}
+		f.State = &v
+	}
+
+	rows, err := a.st.ListContracts(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]contractItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, contractItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleContractsGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	row, err := a.st.GetContract(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	devices, err := a.st.ListContractDevices(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := contractDetailsResponse{contractItem: contractItemFromRow(row), Devices: make([]contractDeviceItem, 0, len(devices))}
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, contractDeviceItemFromRow(d))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *app) handleContractsCreate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	var req contractUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	params, errCode := validateContractUpsert(req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	id, err := qtx.CreateContract(ctx, params, authUsername(ctx))
+	if err != nil {
+		writeContractWriteError(w, err)
+		return
+	}
+	missing, err := saveContractDevices(ctx, qtx, id, req.DeviceIds)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if missing != nil {
+		writeJSON(w, http.StatusBadRequest, contractDeviceNotFound{Error: "device_not_found", DeviceId: *missing})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (a *app) handleContractsUpdate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req contractUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	params, errCode := validateContractUpsert(req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	affected, err := qtx.UpdateContract(ctx, id, params)
+	if err != nil {
+		writeContractWriteError(w, err)
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+	missing, err := saveContractDevices(ctx, qtx, id, req.DeviceIds)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if missing != nil {
+		writeJSON(w, http.StatusBadRequest, contractDeviceNotFound{Error: "device_not_found", DeviceId: *missing})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: id})
+}
+
+// writeContractWriteError переводит ошибки записи контракта в ответы API.
+func writeContractWriteError(w http.ResponseWriter, err error) {
+	if isUniqueViolation(err, contractsUniqueConstraint) {
+		writeJSON(w, http.StatusConflict, apiError{Error: "contract_taken"})
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23514" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_period"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+}
+
+func (a *app) handleContractsDelete(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	affected, err := a.st.DeleteContract(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handleDeviceContractsList отдаёт все контракты устройства, включая истёкшие
+// и будущие.
+func (a *app) handleDeviceContractsList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	exists, err := a.st.DeviceExists(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+	rows, err := a.st.ListDeviceContracts(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]contractItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, contractItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleReportsWarrantyExpiring перечисляет устройства, у которых контракт
+// заканчивается в ближайшие days дней (по умолчанию 30) и не продлён другим.
+func (a *app) handleReportsWarrantyExpiring(w http.ResponseWriter, r *http.Request) {
+	days := int64(coverageExpiringDays)
+	if v := strings.TrimSpace(r.URL.Query().Get("days")); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 0 || n > warrantyReportMaxDays {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_days"})
+			return
+		}
+		days = n
+	}
+
+	rows, err := a.st.ListExpiringCoverage(r.Context(), int32(days))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := warrantyExpiringResponse{Days: int32(days), Items: make([]warrantyExpiringItem, 0, len(rows))}
+	for _, row := range rows {
+		resp.Items = append(resp.Items, warrantyExpiringItem{
+			ContractId:     row.ContractID,
+			ContractNumber: row.ContractNumber,
+			Supplier:       row.Supplier,
+			Kind:           row.Kind,
+			CoverageLevel:  row.CoverageLevel,
+			EndsOn:         row.EndsOn,
+			DaysLeft:       row.DaysLeft,
+			Device:         contractDeviceItemFromRow(row.Device),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
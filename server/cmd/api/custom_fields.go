@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+// Области и типы пользовательских полей. Наборы совпадают с CHECK-ограничениями
+// custom_field_definitions (db/migrations/009_custom_fields.sql).
+const (
+	customFieldScopeDevice   = "device"
+	customFieldScopeModel    = "model"
+	customFieldScopeVendor   = "vendor"
+	customFieldScopeLocation = "location"
+
+	customFieldTypeString = "string"
+	customFieldTypeInt    = "int"
+	customFieldTypeDate   = "date"
+	customFieldTypeEnum   = "enum"
+	customFieldTypeBool   = "bool"
+)
+
+// customFieldFilterPrefix — префикс query-параметров GET /devices для фильтра по
+// пользовательскому полю: cf.firmware=1.2.3.
+const customFieldFilterPrefix = "cf."
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+var customFieldScopes = map[string]bool{
+	customFieldScopeDevice:   true,
+	customFieldScopeModel:    true,
+	customFieldScopeVendor:   true,
+	customFieldScopeLocation: true,
+}
+
+var customFieldTypes = map[string]bool{
+	customFieldTypeString: true,
+	customFieldTypeInt:    true,
+	customFieldTypeDate:   true,
+	customFieldTypeEnum:   true,
+	customFieldTypeBool:   true,
+}
+
+type customFieldDefinitionItem struct {
+	Id         int64    `json:"id"`
+	Scope      string   `json:"scope"`
+	Key        string   `json:"key"`
+	Label      string   `json:"label"`
+	Type       string   `json:"type"`
+	Required   bool     `json:"required"`
+	EnumValues []string `json:"enumValues"`
+	DeviceType string   `json:"deviceType"`
+	SortOrder  int32    `json:"sortOrder"`
+}
+
+type customFieldUpsertRequest struct {
+	Scope      string   `json:"scope"`
+	Key        string   `json:"key"`
+	Label      string   `json:"label"`
+	Type       string   `json:"type"`
+	Required   bool     `json:"required"`
+	EnumValues []string `json:"enumValues"`
+	DeviceType string   `json:"deviceType"`
+	SortOrder  int32    `json:"sortOrder"`
+}
+
+// customFieldError — значение пользовательского поля не прошло проверку.
+// Отдаётся клиенту как 400.
+type customFieldError struct {
+	Code   string `json:"error"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func newCustomFieldError(field, reason string) *customFieldError {
+	return &customFieldError{Code: "invalid_custom_field", Field: field, Reason: reason}
+}
+
+func (e *customFieldError) Error() string {
+	return "custom field " + e.Field + ": " + e.Reason
+}
+
+func customFieldDefinitionFromRow(d store.CustomFieldDefinition) customFieldDefinitionItem {
+	enumValues := d.EnumValues
+	if enumValues == nil {
+		enumValues = []string{}
+	}
+	return customFieldDefinitionItem{
+		Id:         d.ID,
+		Scope:      d.Scope,
+		Key:        d.Key,
+		Label:      d.Label,
+		Type:       d.FieldType,
+		Required:   d.Required,
+		EnumValues: enumValues,
+		DeviceType: d.DeviceType,
+		SortOrder:  d.SortOrder,
+	}
+}
+
+// customFieldApplies сообщает, относится ли поле к сущности с типом модели deviceType.
+func customFieldApplies(d store.CustomFieldDefinition, deviceType string) bool {
+	return d.DeviceType == "" || strings.EqualFold(strings.TrimSpace(d.DeviceType), strings.TrimSpace(deviceType))
+}
+
+// parseCustomFieldValue проверяет значение по типу поля и приводит его к
+// хранимому виду: строка, int64, дата YYYY-MM-DD или bool.
+func parseCustomFieldValue(d store.CustomFieldDefinition, raw json.RawMessage) (any, string) {
+	switch d.FieldType {
+	case customFieldTypeInt:
+		var n json.Number
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&n); err != nil {
+			return nil, "invalid_type"
+		}
+		v, err := strconv.ParseInt(n.String(), 10, 64)
+		if err != nil {
+			return nil, "invalid_type"
+		}
+		return v, ""
+	case customFieldTypeBool:
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, "invalid_type"
+		}
+		return v, ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, "invalid_type"
+	}
+	s = strings.TrimSpace(s)
+	switch d.FieldType {
+	case customFieldTypeDate:
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, "invalid_date"
+		}
+		return t.Format("2006-01-02"), ""
+	case customFieldTypeEnum:
+		for _, allowed := range d.EnumValues {
+			if s == allowed {
+				return s, ""
+			}
+		}
+		return nil, "invalid_enum_value"
+	}
+	return s, ""
+}
+
+// applyCustomFields сливает сохранённые значения current с input и проверяет
+// результат по определениям области scope. Ключ со значением null удаляет
+// значение; значения полей, которые больше не относятся к сущности (например,
+// после смены модели), отбрасываются. Ошибка проверки — *customFieldError.
+func applyCustomFields(ctx context.Context, q *store.Queries, scope, deviceType string, current []byte, input map[string]json.RawMessage) ([]byte, error) {
+	defs, err := q.ListCustomFieldDefinitions(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	return mergeCustomFields(defs, deviceType, current, input)
+}
+
+// mergeCustomFields — applyCustomFields с уже загруженными определениями
+// области: импорт проверяет так тысячи строк без запроса на каждую.
+func mergeCustomFields(defs []store.CustomFieldDefinition, deviceType string, current []byte, input map[string]json.RawMessage) ([]byte, error) {
+	applicable := make(map[string]store.CustomFieldDefinition, len(defs))
+	for _, d := range defs {
+		if customFieldApplies(d, deviceType) {
+			applicable[d.Key] = d
+		}
+	}
+
+	values := map[string]any{}
+	if len(current) > 0 {
+		stored, err := decodeCustomFields(current)
+		if err != nil {
+			return nil, err
+		}
+		for key, v := range stored {
+			if _, ok := applicable[key]; ok {
+				values[key] = v
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		d, ok := applicable[key]
+		if !ok {
+			return nil, newCustomFieldError(key, "unknown_field")
+		}
+		raw := input[key]
+		if len(raw) == 0 || string(raw) == "null" {
+			delete(values, key)
+			continue
+		}
+		v, reason := parseCustomFieldValue(d, raw)
+		if reason != "" {
+			return nil, newCustomFieldError(key, reason)
+		}
+		if s, ok := v.(string); ok && s == "" {
+			delete(values, key)
+			continue
+		}
+		values[key] = v
+	}
+
+	for _, d := range defs {
+		if _, ok := applicable[d.Key]; !ok || !d.Required {
+			continue
+		}
+		if _, ok := values[d.Key]; !ok {
+			return nil, newCustomFieldError(d.Key, "required")
+		}
+	}
+
+	return json.Marshal(values)
+}
+
+// decodeCustomFields разбирает хранимый JSONB. Числа остаются json.Number,
+// чтобы большие int не теряли точность при повторной записи.
+func decodeCustomFields(raw []byte) (map[string]any, error) {
+	values := map[string]any{}
+	if len(raw) == 0 {
+		return values, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// customFieldsResponse разворачивает хранимый JSONB для ответа API.
+func customFieldsResponse(raw []byte) map[string]any {
+	values, err := decodeCustomFields(raw)
+	if err != nil {
+		return map[string]any{}
+	}
+	return values
+}
+
+// writeCustomFieldError отвечает 400, если err — ошибка проверки пользовательского
+// поля, и сообщает, обработана ли ошибка.
+func writeCustomFieldError(w http.ResponseWriter, err error) bool {
+	var cfErr *customFieldError
+	if !errors.As(err, &cfErr) {
+		return false
+	}
+	writeJSON(w, http.StatusBadRequest, cfErr)
+	return true
+}
+
+// saveEntityCustomFields проверяет и записывает пользовательские поля
+// справочника в транзакции q. created — сущность только что создана: тогда
+// обязательные поля проверяются даже без input. При обновлении отсутствующий
+// input (nil) оставляет значения как есть.
+func saveEntityCustomFields(ctx context.Context, q *store.Queries, scope string, id int64, deviceType string, created bool, input map[string]json.RawMessage) error {
+	if input == nil && !created {
+		return nil
+	}
+	var current []byte
+	if !created {
+		var err error
+		current, err = q.GetCustomFields(ctx, scope, id)
+		if err != nil {
+			return err
+		}
+	}
+	values, err := applyCustomFields(ctx, q, scope, deviceType, current, input)
+	if err != nil {
+		return err
+	}
+	return q.SetCustomFields(ctx, scope, id, values)
+}
+
+// parseCustomFieldFilters извлекает из query-параметров фильтры cf.<key>=<value>.
+// Значения остаются строками; типы полей проверяет resolveCustomFieldFilter.
+func parseCustomFieldFilters(q map[string][]string) map[string]any {
+	var out map[string]any
+	for name, values := range q {
+		if !strings.HasPrefix(name, customFieldFilterPrefix) || len(values) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]any)
+		}
+		out[strings.TrimPrefix(name, customFieldFilterPrefix)] = strings.TrimSpace(values[len(values)-1])
+	}
+	return out
+}
+
+// resolveCustomFieldFilter проверяет фильтры по пользовательским полям устройств
+// и приводит значения к хранимому виду: фильтр становится jsonb-объектом,
+// с которым custom_fields сравнивается через @>.
+func (a *app) resolveCustomFieldFilter(ctx context.Context, f *store.DeviceFilter) (string, error) {
+	if len(f.CustomFields) == 0 {
+		return "", nil
+	}
+	defs, err := a.st.ListCustomFieldDefinitions(ctx, customFieldScopeDevice)
+	if err != nil {
+		return "", err
+	}
+	byKey := make(map[string]store.CustomFieldDefinition, len(defs))
+	for _, d := range defs {
+		byKey[d.Key] = d
+	}
+
+	for key, input := range f.CustomFields {
+		d, ok := byKey[key]
+		value, isText := input.(string)
+		if !ok || !isText {
+			return "invalid_custom_field_filter", nil
+		}
+		raw, _ := json.Marshal(value)
+		switch d.FieldType {
+		case customFieldTypeInt, customFieldTypeBool:
+			raw = json.RawMessage(strings.ToLower(value))
+		}
+		v, reason := parseCustomFieldValue(d, raw)
+		if reason != "" {
+			return "invalid_custom_field_filter", nil
+		}
+		f.CustomFields[key] = v
+	}
+	return "", nil
+}
+
+func (a *app) handleCustomFieldsList(w http.ResponseWriter, r *http.Request) {
+	scope := strings.TrimSpace(r.URL.Query().Get("scope"))
+	if scope != "" && !customFieldScopes[scope] {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_scope"})
+		return
+	}
+
+	rows, err := a.st.ListCustomFieldDefinitions(r.Context(), scope)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]customFieldDefinitionItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, customFieldDefinitionFromRow(row))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// validateCustomFieldDefinition проверяет общие для создания и изменения
+// атрибуты поля. scope и fieldType — уже сохранённые или запрошенные значения.
+func validateCustomFieldDefinition(req *customFieldUpsertRequest, scope, fieldType string) string {
+	req.Label = strings.TrimSpace(req.Label)
+	req.DeviceType = strings.TrimSpace(req.DeviceType)
+	if req.Label == "" {
+		return "label_required"
+	}
+	if req.DeviceType != "" && scope != customFieldScopeDevice && scope != customFieldScopeModel {
+		return "device_type_not_allowed"
+	}
+
+	if fieldType != customFieldTypeEnum {
+		if len(req.EnumValues) > 0 {
+			return "enum_values_not_allowed"
+		}
+		req.EnumValues = []string{}
+		return ""
+	}
+	seen := make(map[string]bool, len(req.EnumValues))
+	values := make([]string, 0, len(req.EnumValues))
+	for _, v := range req.EnumValues {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			return "invalid_enum_values"
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return "enum_values_required"
+	}
+	req.EnumValues = values
+	return ""
+}
+
+func (a *app) handleCustomFieldsCreate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	var req customFieldUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+
+	req.Scope = strings.TrimSpace(req.Scope)
+	req.Key = strings.TrimSpace(req.Key)
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	if !customFieldScopes[req.Scope] {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_scope"})
+		return
+	}
+	if !customFieldKeyPattern.MatchString(req.Key) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_key"})
+		return
+	}
+	if !customFieldTypes[req.Type] {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_type"})
+		return
+	}
+	if errCode := validateCustomFieldDefinition(&req, req.Scope, req.Type); errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	id, err := a.st.CreateCustomFieldDefinition(r.Context(), store.CustomFieldDefinition{
+		Scope:      req.Scope,
+		Key:        req.Key,
+		Label:      req.Label,
+		FieldType:  req.Type,
+		Required:   req.Required,
+		EnumValues: req.EnumValues,
+		SortOrder:  req.SortOrder,
+	}, nullIfEmpty(req.DeviceType))
+	if err != nil {
+		if isUniqueViolation(err, "custom_field_definitions_scope_key_unique") {
+			writeJSON(w, http.StatusConflict, apiError{Error: "key_taken"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+// handleCustomFieldsUpdate меняет подпись, обязательность, варианты enum, тип
+// модели и порядок. Область, ключ и тип поля неизменяемы.
+func (a *app) handleCustomFieldsUpdate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req customFieldUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+
+	existing, err := a.st.GetCustomFieldDefinition(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if (req.Scope != "" && req.Scope != existing.Scope) ||
+		(req.Key != "" && req.Key != existing.Key) ||
+		(req.Type != "" && req.Type != existing.FieldType) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "immutable_field"})
+		return
+	}
+	if errCode := validateCustomFieldDefinition(&req, existing.Scope, existing.FieldType); errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	affected, err := a.st.UpdateCustomFieldDefinition(r.Context(), id, req.Label, req.Required, req.EnumValues, nullIfEmpty(req.DeviceType), req.SortOrder)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: id})
+}
+
+// handleCustomFieldsDelete удаляет определение вместе с сохранёнными значениями.
+func (a *app) handleCustomFieldsDelete(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	existing, err := qtx.GetCustomFieldDefinition(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if _, err := qtx.DeleteCustomFieldDefinition(ctx, id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if err := qtx.RemoveCustomFieldKey(ctx, existing.Scope, existing.Key); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
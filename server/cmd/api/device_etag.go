@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"telecombase/server/internal/store"
+)
+
+// devicePreconditionFailed — ответ 412: клиент правил устаревшую версию.
+// Current содержит актуальное состояние для диалога слияния.
+type devicePreconditionFailed struct {
+	Error   string                `json:"error"`
+	Current deviceDetailsResponse `json:"current"`
+}
+
+// deviceETag — сильный ETag по номеру версии строки устройства.
+func deviceETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch разбирает If-Match. Поддерживаются "*" (any = true) и список
+// ETag через запятую; слабые ETag (W/"...") для записи не принимаются.
+func parseIfMatch(header string) (versions []int64, any bool, ok bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, false, false
+	}
+	if header == "*" {
+		return nil, true, true
+	}
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) < 2 || part[0] != '"' || part[len(part)-1] != '"' {
+			return nil, false, false
+		}
+		v, err := strconv.ParseInt(part[1:len(part)-1], 10, 64)
+		if err != nil || v <= 0 {
+			return nil, false, false
+		}
+		versions = append(versions, v)
+	}
+	return versions, false, true
+}
+
+func ifMatchVersion(versions []int64, any bool, current int64) bool {
+	if any {
+		return true
+	}
+	for _, v := range versions {
+		if v == current {
+			return true
+		}
+	}
+	return false
+}
+
+func deviceDetailsFromRow(row store.GetDeviceByIDRow) deviceDetailsResponse {
+	return deviceDetailsResponse{
+		Id:              row.ID,
+		ModelId:         row.ModelID,
+		LocationId:      row.LocationID,
+		SerialNumber:    row.SerialNumber,
+		InventoryNumber: row.InventoryNumber,
+		Status:          row.Status,
+		InstalledAt:     row.InstalledAt,
+		Description:     row.Description,
+		Version:         row.Version,
+		UpdatedAt:       row.UpdatedAt.UTC().Format(time.RFC3339),
+		CustomFields:    customFieldsResponse(row.CustomFields),
+		Hostname:        row.Hostname,
+		ManagementIp:    row.ManagementIP,
+		IpAddresses:     deviceIPAddressesResponse(row),
+		MacAddresses:    append([]string{}, row.MACAddresses...),
+		RackPositionU:   row.RackPositionU,
+		RackFace:        row.RackFace,
+		Coverage:        deviceCoverageFromRow(row),
+	}
+}
+
+func writePreconditionFailed(w http.ResponseWriter, current store.GetDeviceByIDRow) {
+	w.Header().Set("ETag", deviceETag(current.Version))
+	writeJSON(w, http.StatusPreconditionFailed, devicePreconditionFailed{
+		Error:   "precondition_failed",
+		Current: deviceDetailsFromRow(current),
+	})
+}
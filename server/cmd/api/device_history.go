@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	deviceHistoryCreate  = "create"
+	deviceHistoryUpdate  = "update"
+	deviceHistoryDelete  = "delete"
+	deviceHistoryRestore = "restore"
+	deviceHistoryPurge   = "purge"
+)
+
+type deviceFieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type deviceHistoryItem struct {
+	Id        int64               `json:"id"`
+	Action    string              `json:"action"`
+	Actor     string              `json:"actor"`
+	CreatedAt string              `json:"createdAt"`
+	Changes   []deviceFieldChange `json:"changes"`
+}
+
+type deviceHistoryField struct {
+	name  string
+	value string
+}
+
+// deviceHistoryFields задаёт порядок и отображаемые значения полей в diff.
+func deviceHistoryFields(s *store.GetDeviceSnapshotRow) []deviceHistoryField {
+	if s == nil {
+		return nil
+	}
+	location := ""
+	if s.LocationID != nil {
+		location = fmt.Sprintf("%s (#%d)", s.LocationName, *s.LocationID)
+	}
+	rackPosition := ""
+	if s.RackPositionU != nil {
+		rackPosition = fmt.Sprintf("U%d %s", *s.RackPositionU, s.RackFace)
+	}
+	fields := []deviceHistoryField{
+		{name: "model", value: fmt.Sprintf("%s (#%d)", s.ModelName, s.ModelID)},
+		{name: "location", value: location},
+		{name: "serialNumber", value: s.SerialNumber},
+		{name: "inventoryNumber", value: s.InventoryNumber},
+		{name: "status", value: s.Status},
+		{name: "installedAt", value: s.InstalledAt},
+		{name: "description", value: s.Description},
+		{name: "hostname", value: s.Hostname},
+		{name: "ipAddresses", value: s.IPAddresses},
+		{name: "macAddresses", value: s.MACAddresses},
+		{name: "rackPosition", value: rackPosition},
+	}
+	// Пользовательские поля идут после основных, в порядке ключей.
+	var custom map[string]json.RawMessage
+	_ = json.Unmarshal(s.CustomFields, &custom)
+	keys := make([]string, 0, len(custom))
+	for key := range custom {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := string(custom[key])
+		var str string
+		if json.Unmarshal(custom[key], &str) == nil {
+			value = str
+		}
+		fields = append(fields, deviceHistoryField{name: customFieldFilterPrefix + key, value: value})
+	}
+	return fields
+}
+
+// diffDeviceSnapshots возвращает изменившиеся поля. before == nil — создание,
+// after == nil — удаление.
+func diffDeviceSnapshots(before, after *store.GetDeviceSnapshotRow) []deviceFieldChange {
+	oldFields := deviceHistoryFields(before)
+	newFields := deviceHistoryFields(after)
+
+	changes := make([]deviceFieldChange, 0, max(len(oldFields), len(newFields)))
+	index := make(map[string]int, len(oldFields))
+	for _, f := range oldFields {
+		index[f.name] = len(changes)
+		changes = append(changes, deviceFieldChange{Field: f.name, Old: f.value})
+	}
+	for _, f := range newFields {
+		if i, ok := index[f.name]; ok {
+			changes[i].New = f.value
+			continue
+		}
+		changes = append(changes, deviceFieldChange{Field: f.name, New: f.value})
+	}
+
+	out := changes[:0]
+	for _, c := range changes {
+		if c.Old != c.New {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// recordDeviceHistory пишет запись истории в рамках текущей транзакции q.
+// Обновление без фактических изменений не записывается.
+func recordDeviceHistory(ctx context.Context, q *store.Queries, deviceID int64, action string, before, after *store.GetDeviceSnapshotRow) error {
+	changes := diffDeviceSnapshots(before, after)
+	if action == deviceHistoryUpdate && len(changes) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return q.CreateDeviceHistory(ctx, deviceID, action, authUsername(ctx), payload)
+}
+
+func (a *app) handleDeviceHistoryList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	rows, err := a.st.ListDeviceHistory(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	// История удалённого устройства остаётся доступной; 404 — только если
+	// устройства нет и записей о нём тоже нет.
+	if len(rows) == 0 {
+		if _, err := a.st.GetDeviceByID(r.Context(), id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+
+	items := make([]deviceHistoryItem, 0, len(rows))
+	for _, row := range rows {
+		changes := []deviceFieldChange{}
+		if err := json.Unmarshal(row.Changes, &changes); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		items = append(items, deviceHistoryItem{
+			Id:        row.ID,
+			Action:    row.Action,
+			Actor:     row.Actor,
+			CreatedAt: row.CreatedAt.Format(time.RFC3339),
+			Changes:   changes,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
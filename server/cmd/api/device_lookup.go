@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	deviceLookupCodeMax = 200
+	// deviceLookupMaxCandidates — сколько неоднозначных совпадений отдаётся;
+	// больше оператору у сканера всё равно не разобрать.
+	deviceLookupMaxCandidates = 50
+)
+
+type deviceLookupCandidate struct {
+	Id              int64    `json:"id"`
+	Label           string   `json:"label"`
+	Hostname        string   `json:"hostname"`
+	SerialNumber    string   `json:"serialNumber"`
+	InventoryNumber string   `json:"inventoryNumber"`
+	Status          string   `json:"status"`
+	LocationName    string   `json:"locationName"`
+	MatchedBy       []string `json:"matchedBy"`
+}
+
+// deviceLookupResponse — результат поиска по коду. Device заполнен, только
+// если совпало ровно одно устройство; Candidates перечисляет все совпадения.
+type deviceLookupResponse struct {
+	Code       string                  `json:"code"`
+	Device     *deviceDetailsResponse  `json:"device"`
+	Candidates []deviceLookupCandidate `json:"candidates"`
+	Truncated  bool                    `json:"truncated"`
+}
+
+// normalizeScannedCode убирает то, что сканеры добавляют к коду: пробелы и
+// переводы строк по краям и управляющие символы (разделители GS и т.п.).
+func normalizeScannedCode(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+// handleDevicesLookup находит устройство по коду со сканера: точное совпадение
+// серийного номера (без пробелов и регистра), инвентарного номера (без
+// крайних пробелов и регистра) или MAC-адреса в любой записи. В отличие от
+// поиска в списке устройств, частичных совпадений нет.
+func (a *app) handleDevicesLookup(w http.ResponseWriter, r *http.Request) {
+	code := normalizeScannedCode(r.URL.Query().Get("code"))
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "code_required"})
+		return
+	}
+	if utf8.RuneCountInString(code) > deviceLookupCodeMax {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_code"})
+		return
+	}
+	mac, _ := normalizeMACAddress(code)
+
+	ctx := r.Context()
+	rows, err := a.st.LookupDevices(ctx, code, mac, deviceLookupMaxCandidates+1)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if len(rows) == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	resp := deviceLookupResponse{Code: code, Candidates: []deviceLookupCandidate{}}
+	if len(rows) > deviceLookupMaxCandidates {
+		rows = rows[:deviceLookupMaxCandidates]
+		resp.Truncated = true
+	}
+	for _, row := range rows {
+		resp.Candidates = append(resp.Candidates, deviceLookupCandidate{
+			Id:              row.ID,
+			Label:           deviceLabel(row.Hostname, row.VendorName, row.ModelName, row.SerialNumber),
+			Hostname:        row.Hostname,
+			SerialNumber:    row.SerialNumber,
+			InventoryNumber: row.InventoryNumber,
+			Status:          row.Status,
+			LocationName:    row.LocationName,
+			MatchedBy:       row.MatchedBy,
+		})
+	}
+
+	if len(rows) == 1 {
+		device, err := a.st.GetDeviceByID(ctx, rows[0].ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		details := deviceDetailsFromRow(device)
+		resp.Device = &details
+		w.Header().Set("ETag", deviceETag(device.Version))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"net/netip"
+	"strings"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	deviceHostnameMaxLen = 253
+	deviceAddressesMax   = 64
+)
+
+type deviceIPAddress struct {
+	Address    string `json:"address"`
+	Management bool   `json:"management"`
+}
+
+// normalizeHostname приводит имя хоста к нижнему регистру и проверяет его по
+// RFC 1123: метки из букв, цифр и дефиса, не длиннее 63 символов.
+func normalizeHostname(s string) (string, bool) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+	if s == "" || len(s) > deviceHostnameMaxLen {
+		return "", false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return "", false
+			}
+		}
+	}
+	return s, true
+}
+
+// normalizeIPAddress разбирает адрес в записи «10.0.0.1» или «10.0.0.1/24» и
+// возвращает её в том виде, в каком PostgreSQL выводит inet: маска опускается,
+// если она полная. IPv4, отображённые в IPv6, приводятся к IPv4.
+func normalizeIPAddress(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil || addr.Zone() != "" {
+			return "", false
+		}
+		return addr.Unmap().String(), true
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return "", false
+	}
+	addr := prefix.Addr()
+	bits := prefix.Bits()
+	if addr.Is4In6() {
+		if bits < 96 {
+			return "", false
+		}
+		addr, bits = addr.Unmap(), bits-96
+	}
+	if bits == addr.BitLen() {
+		return addr.String(), true
+	}
+	return netip.PrefixFrom(addr, bits).String(), true
+}
+
+// normalizeCIDR разбирает подсеть для фильтра и обнуляет биты хоста:
+// «10.0.0.5/24» ищет по сети 10.0.0.0/24.
+func normalizeCIDR(s string) (string, bool) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+	if err != nil {
+		return "", false
+	}
+	return prefix.Masked().String(), true
+}
+
+// normalizeMACAddress принимает MAC-адрес в любой распространённой записи
+// (00:11:22:33:44:55, 00-11-22-33-44-55, 0011.2233.4455, 001122334455) и
+// возвращает её в виде 00:11:22:33:44:55.
+func normalizeMACAddress(s string) (string, bool) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '-', '.', ' ':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if len(digits) != 12 {
+		return "", false
+	}
+	raw, err := hex.DecodeString(digits)
+	if err != nil {
+		return "", false
+	}
+	parts := make([]string, len(raw))
+	for i, b := range raw {
+		parts[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(parts, ":"), true
+}
+
+// validateDeviceNetwork проверяет и нормализует имя хоста и адреса устройства.
+// nil-поля запроса означают «не менять» и остаются nil.
+func validateDeviceNetwork(req deviceUpsertRequest, in *deviceInput) string {
+	if req.Hostname != nil {
+		hostname := ""
+		if strings.TrimSpace(*req.Hostname) != "" {
+			var ok bool
+			if hostname, ok = normalizeHostname(*req.Hostname); !ok {
+				return "invalid_hostname"
+			}
+		}
+		in.Hostname = &hostname
+	}
+
+	if req.IpAddresses != nil {
+		if len(req.IpAddresses) > deviceAddressesMax {
+			return "too_many_addresses"
+		}
+		in.IPAddresses = make([]string, 0, len(req.IpAddresses))
+		seen := make(map[string]bool, len(req.IpAddresses))
+		for _, it := range req.IpAddresses {
+			addr, ok := normalizeIPAddress(it.Address)
+			if !ok {
+				return "invalid_ip_address"
+			}
+			if seen[addr] {
+				return "duplicate_ip_address"
+			}
+			seen[addr] = true
+			in.IPAddresses = append(in.IPAddresses, addr)
+			if it.Management {
+				if in.ManagementIP != nil {
+					return "multiple_management_ips"
+				}
+				in.ManagementIP = &addr
+			}
+		}
+	}
+
+	if req.MacAddresses != nil {
+		if len(req.MacAddresses) > deviceAddressesMax {
+			return "too_many_addresses"
+		}
+		in.MACAddresses = make([]string, 0, len(req.MacAddresses))
+		seen := make(map[string]bool, len(req.MacAddresses))
+		for _, raw := range req.MacAddresses {
+			mac, ok := normalizeMACAddress(raw)
+			if !ok {
+				return "invalid_mac_address"
+			}
+			if seen[mac] {
+				return "duplicate_mac_address"
+			}
+			seen[mac] = true
+			in.MACAddresses = append(in.MACAddresses, mac)
+		}
+	}
+
+	return ""
+}
+
+// saveDeviceAddresses заменяет адреса устройства, если они были переданы.
+func saveDeviceAddresses(ctx context.Context, qtx *store.Queries, id int64, in deviceInput) error {
+	if in.IPAddresses != nil {
+		// Адреса устройств IPAM считает занятыми в глобальном VRF, поэтому
+		// их запись идёт в очереди с выделением адресов из префиксов.
+		if err := qtx.LockIPAMVRF(ctx, ""); err != nil {
+			return err
+		}
+		if err := qtx.ReplaceDeviceIPAddresses(ctx, id, in.IPAddresses, in.ManagementIP); err != nil {
+			return err
+		}
+	}
+	if in.MACAddresses != nil {
+		if err := qtx.ReplaceDeviceMACAddresses(ctx, id, in.MACAddresses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deviceIPAddressesResponse(row store.GetDeviceByIDRow) []deviceIPAddress {
+	items := make([]deviceIPAddress, 0, len(row.IPAddresses))
+	for _, addr := range row.IPAddresses {
+		items = append(items, deviceIPAddress{Address: addr, Management: addr == row.ManagementIP})
+	}
+	return items
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"telecombase/server/internal/store"
+)
+
+// deviceSearchMatch указывает, в каком поле найдено совпадение и где именно
+// (смещение и длина — в символах), чтобы клиент мог подсветить фрагмент.
+type deviceSearchMatch struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// findDeviceSearchMatch ищет строку запроса целиком, а затем по словам в полях
+// в порядке приоритета. Для нечётких (trigram) совпадений подсветки нет.
+func findDeviceSearchMatch(query string, row store.ListDevicesRow) *deviceSearchMatch {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"serialNumber", row.SerialNumber},
+		{"inventoryNumber", row.InventoryNumber},
+		{"hostname", row.Hostname},
+		{"modelName", row.ModelName},
+		{"vendorName", row.VendorName},
+		{"locationName", row.LocationName},
+		{"description", row.Description},
+	}
+
+	needles := append([]string{query}, strings.Fields(query)...)
+	for _, needle := range needles {
+		lowerNeedle := strings.ToLower(needle)
+		for _, f := range fields {
+			lowerValue := strings.ToLower(f.value)
+			idx := strings.Index(lowerValue, lowerNeedle)
+			if idx < 0 {
+				continue
+			}
+			return &deviceSearchMatch{
+				Field:  f.name,
+				Value:  f.value,
+				Offset: utf8.RuneCountInString(lowerValue[:idx]),
+				Length: utf8.RuneCountInString(lowerNeedle),
+			}
+		}
+	}
+	return nil
+}
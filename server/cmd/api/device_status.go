@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+// Статусы жизненного цикла устройства. Набор совпадает с CHECK-ограничением
+// devices_status_check (db/migrations/003_device_lifecycle.sql).
+const (
+	deviceStatusInStock        = "in_stock"
+	deviceStatusActive         = "active"
+	deviceStatusMaintenance    = "maintenance"
+	deviceStatusFaulty         = "faulty"
+	deviceStatusDecommissioned = "decommissioned"
+	deviceStatusWrittenOff     = "written_off"
+)
+
+// deviceStatusTransitions описывает допустимые переходы: из ключа можно перейти
+// только в перечисленные статусы. written_off — конечное состояние.
+var deviceStatusTransitions = map[string][]string{
+	deviceStatusInStock:        {deviceStatusActive, deviceStatusFaulty, deviceStatusWrittenOff},
+	deviceStatusActive:         {deviceStatusInStock, deviceStatusMaintenance, deviceStatusFaulty, deviceStatusDecommissioned},
+	deviceStatusMaintenance:    {deviceStatusActive, deviceStatusInStock, deviceStatusFaulty, deviceStatusDecommissioned},
+	deviceStatusFaulty:         {deviceStatusMaintenance, deviceStatusInStock, deviceStatusDecommissioned, deviceStatusWrittenOff},
+	deviceStatusDecommissioned: {deviceStatusInStock, deviceStatusWrittenOff},
+	deviceStatusWrittenOff:     {},
+}
+
+type deviceTransitionRequest struct {
+	ToStatus string `json:"toStatus"`
+	Reason   string `json:"reason"`
+}
+
+type deviceTransitionItem struct {
+	Id         int64  `json:"id"`
+	FromStatus string `json:"fromStatus"`
+	ToStatus   string `json:"toStatus"`
+	Reason     string `json:"reason"`
+	Actor      string `json:"actor"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+type deviceTransitionConflict struct {
+	Error   string   `json:"error"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed []string `json:"allowed"`
+}
+
+// normalizeDeviceStatus приводит ввод к каноническому статусу.
+// "installed" принимается как синоним "active".
+func normalizeDeviceStatus(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "installed" {
+		s = deviceStatusActive
+	}
+	if _, ok := deviceStatusTransitions[s]; !ok {
+		return "", false
+	}
+	return s, true
+}
+
+func deviceStatusTransitionAllowed(from, to string) bool {
+	for _, s := range deviceStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTransitionConflict(w http.ResponseWriter, from, to string) {
+	allowed := deviceStatusTransitions[from]
+	if allowed == nil {
+		allowed = []string{}
+	}
+	writeJSON(w, http.StatusConflict, deviceTransitionConflict{
+		Error:   "invalid_transition",
+		From:    from,
+		To:      to,
+		Allowed: allowed,
+	})
+}
+
+// transitionDeviceStatus переводит устройство id в статус toStatus внутри
+// транзакции q: блокирует строку, проверяет допустимость перехода, пишет журнал
+// переходов и историю. ok == false — переход из текущего статуса from запрещён,
+// ничего не изменено. pgx.ErrNoRows — устройства нет или оно в корзине.
+func transitionDeviceStatus(ctx context.Context, q *store.Queries, id int64, toStatus, reason, actor string) (from string, created store.CreateDeviceTransitionRow, ok bool, err error) {
+	before, err := q.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		return "", created, false, err
+	}
+	from = before.Status
+	if !deviceStatusTransitionAllowed(from, toStatus) {
+		return from, created, false, nil
+	}
+
+	if _, err := q.SetDeviceStatus(ctx, id, toStatus); err != nil {
+		return from, created, false, err
+	}
+	created, err = q.CreateDeviceTransition(ctx, id, from, toStatus, nullIfEmpty(reason), actor)
+	if err != nil {
+		return from, created, false, err
+	}
+
+	after := before
+	after.Status = toStatus
+	if err := recordDeviceHistory(ctx, q, id, deviceHistoryUpdate, &before, &after); err != nil {
+		return from, created, false, err
+	}
+	return from, created, true, nil
+}
+
+func (a *app) handleDeviceTransitionsList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	if _, err := a.st.GetDeviceByID(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	rows, err := a.st.ListDeviceTransitions(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]deviceTransitionItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, deviceTransitionItem{
+			Id:         row.ID,
+			FromStatus: row.FromStatus,
+			ToStatus:   row.ToStatus,
+			Reason:     row.Reason,
+			Actor:      row.Actor,
+			CreatedAt:  row.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleDeviceTransitionsCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req deviceTransitionRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	toStatus, ok := normalizeDeviceStatus(req.ToStatus)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_status"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	actor := authUsername(ctx)
+	fromStatus, created, ok, err := transitionDeviceStatus(ctx, qtx, id, toStatus, req.Reason, actor)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !ok {
+		writeTransitionConflict(w, fromStatus, toStatus)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, deviceTransitionItem{
+		Id:         created.ID,
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		Reason:     strings.TrimSpace(req.Reason),
+		Actor:      actor,
+		CreatedAt:  created.CreatedAt.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceTrashPurgeInterval — как часто фоновая очистка проверяет корзину.
+const deviceTrashPurgeInterval = time.Hour
+
+type deviceTrashItem struct {
+	Id              int64  `json:"id"`
+	VendorName      string `json:"vendorName"`
+	ModelName       string `json:"modelName"`
+	LocationName    string `json:"locationName"`
+	SerialNumber    string `json:"serialNumber"`
+	InventoryNumber string `json:"inventoryNumber"`
+	Status          string `json:"status"`
+	DeletedAt       string `json:"deletedAt"`
+	DeletedBy       string `json:"deletedBy"`
+	PurgeAt         string `json:"purgeAt,omitempty"`
+}
+
+type deviceTrashPurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+func (a *app) handleDevicesTrashList(w http.ResponseWriter, r *http.Request) {
+	rows, err := a.st.ListTrashedDevices(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]deviceTrashItem, 0, len(rows))
+	for _, row := range rows {
+		item := deviceTrashItem{
+			Id:              row.ID,
+			VendorName:      row.VendorName,
+			ModelName:       row.ModelName,
+			LocationName:    row.LocationName,
+			SerialNumber:    row.SerialNumber,
+			InventoryNumber: row.InventoryNumber,
+			Status:          row.Status,
+			DeletedAt:       row.DeletedAt.Format(time.RFC3339),
+			DeletedBy:       row.DeletedBy,
+		}
+		if a.cfg.deviceTrashRetention > 0 {
+			item.PurgeAt = row.DeletedAt.Add(a.cfg.deviceTrashRetention).Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleDevicesRestore(w http.ResponseWriter, r *http.Request) {
+	role := authRole(r.Context())
+	if role != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	affected, err := qtx.RestoreDevice(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	after, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	// Пока устройство лежало в корзине, его место в стойке могли занять:
+	// тогда оно восстанавливается в ту же стойку, но без места.
+	if after.RackPositionU != nil {
+		rackErr, err := checkDeviceRackPlacement(ctx, qtx, id, after.LocationID, after.ModelID, after.RackPositionU, after.RackFace)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if rackErr != nil {
+			if err := qtx.ClearDeviceRackPlacement(ctx, id); err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+			if after, err = qtx.GetDeviceSnapshot(ctx, id); err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+		}
+	}
+	if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryRestore, nil, &after); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	w.Header().Set("ETag", deviceETag(after.Version))
+	writeJSON(w, http.StatusOK, deviceUpsertResponse{Id: id, Version: after.Version})
+}
+
+// handleDevicesPurge окончательно удаляет одно устройство из корзины.
+func (a *app) handleDevicesPurge(w http.ResponseWriter, r *http.Request) {
+	role := authRole(r.Context())
+	if role != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	affected, err := qtx.PurgeDevice(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryPurge, nil, nil); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	// Вложения удалены каскадом вместе с устройством; файлы, на которые больше
+	// никто не ссылается, убираются из хранилища после фиксации.
+	keys, err := qtx.DeleteOrphanAttachmentBlobs(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	a.removeAttachmentFiles(ctx, keys)
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handleDevicesTrashPurge очищает корзину целиком или, с older_than_days,
+// только устройства, удалённые раньше указанного числа дней назад.
+func (a *app) handleDevicesTrashPurge(w http.ResponseWriter, r *http.Request) {
+	role := authRole(r.Context())
+	if role != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	var before *time.Time
+	if v := strings.TrimSpace(r.URL.Query().Get("older_than_days")); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_older_than_days"})
+			return
+		}
+		t := time.Now().AddDate(0, 0, -days)
+		before = &t
+	}
+
+	purged, err := a.purgeTrashedDevices(r.Context(), before)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deviceTrashPurgeResponse{Purged: purged})
+}
+
+func (a *app) purgeTrashedDevices(ctx context.Context, before *time.Time) (int, error) {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	ids, err := qtx.PurgeTrashedDevices(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryPurge, nil, nil); err != nil {
+			return 0, err
+		}
+	}
+	keys, err := qtx.DeleteOrphanAttachmentBlobs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	a.removeAttachmentFiles(ctx, keys)
+	return len(ids), nil
+}
+
+// runDeviceTrashPurger периодически удаляет устройства, пролежавшие в корзине
+// дольше cfg.deviceTrashRetention. Нулевой срок отключает автоочистку.
+func (a *app) runDeviceTrashPurger(ctx context.Context) {
+	retention := a.cfg.deviceTrashRetention
+	if retention <= 0 {
+		return
+	}
+
+	// Записи истории об автоочистке подписываются служебным пользователем.
+	ctx = context.WithValue(ctx, authUsernameKey, "system")
+
+	ticker := time.NewTicker(deviceTrashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		before := time.Now().Add(-retention)
+		purged, err := a.purgeTrashedDevices(ctx, &before)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("device trash purge: %v", err)
+		} else if purged > 0 {
+			log.Printf("device trash purge: %d device(s) removed", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/store"
+)
+
+// Уникальный индекс по нормализованному серийному номеру
+// (db/migrations/008_device_identifier_keys.sql).
+const deviceSerialKeyConstraint = "devices_serial_key_unique"
+
+// deviceUniqueConflict — ответ 409: номер уже принадлежит устройству DeviceId.
+type deviceUniqueConflict struct {
+	Error    string `json:"error"`
+	Field    string `json:"field"`
+	DeviceId int64  `json:"deviceId"`
+	InTrash  bool   `json:"inTrash,omitempty"`
+}
+
+// normalizeSerialKey повторяет выражение devices.serial_key: без пробельных
+// символов, в верхнем регистре.
+func normalizeSerialKey(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+// normalizeInventoryKey повторяет выражение devices.inventory_key.
+func normalizeInventoryKey(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// checkDeviceUnique ищет другое устройство с тем же серийным номером и, если
+// включена политика INVENTORY_NUMBER_UNIQUE, с тем же инвентарным номером.
+// selfID — id редактируемого устройства (0 при создании). Вызывается внутри
+// транзакции, которая затем пишет устройство.
+func (a *app) checkDeviceUnique(ctx context.Context, qtx *store.Queries, selfID int64, in deviceInput) (*deviceUniqueConflict, error) {
+	if in.SerialNumber != "" {
+		found, err := qtx.FindDeviceBySerial(ctx, in.SerialNumber, selfID)
+		if err == nil {
+			return &deviceUniqueConflict{Error: "serial_taken", Field: "serialNumber", DeviceId: found.ID, InTrash: found.InTrash}, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	if a.cfg.inventoryUnique && in.InventoryNumber != "" {
+		if err := qtx.LockDeviceInventoryNumber(ctx, in.InventoryNumber); err != nil {
+			return nil, err
+		}
+		found, err := qtx.FindDeviceByInventoryNumber(ctx, in.InventoryNumber, selfID)
+		if err == nil {
+			return &deviceUniqueConflict{Error: "inventory_taken", Field: "inventoryNumber", DeviceId: found.ID, InTrash: found.InTrash}, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
+// writeSerialTakenAfterViolation отвечает 409, когда серийник заняли между
+// проверкой и записью. Транзакция к этому моменту уже прервана, поэтому
+// владелец номера ищется вне её.
+func (a *app) writeSerialTakenAfterViolation(w http.ResponseWriter, ctx context.Context, selfID int64, serial string) {
+	conflict := deviceUniqueConflict{Error: "serial_taken", Field: "serialNumber"}
+	if found, err := a.st.FindDeviceBySerial(ctx, serial, selfID); err == nil {
+		conflict.DeviceId = found.ID
+		conflict.InTrash = found.InTrash
+	}
+	writeJSON(w, http.StatusConflict, conflict)
+}
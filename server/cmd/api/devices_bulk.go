@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+const deviceBulkMaxDevices = 1000
+
+// deviceBulkFilter повторяет query-параметры GET /devices.
+type deviceBulkFilter struct {
+	Q             string   `json:"q"`
+	VendorId      *int64   `json:"vendorId"`
+	ModelId       *int64   `json:"modelId"`
+	LocationId    *int64   `json:"locationId"`
+	Status        []string `json:"status"`
+	InstalledFrom string   `json:"installedFrom"`
+	InstalledTo   string   `json:"installedTo"`
+	Ip            string   `json:"ip"`
+	Cidr          string   `json:"cidr"`
+	Mac           string   `json:"mac"`
+	// CustomFields — то же, что параметры cf.<key> в GET /devices.
+	CustomFields map[string]string `json:"customFields"`
+}
+
+type deviceBulkChanges struct {
+	LocationId        *int64 `json:"locationId"`
+	ClearLocation     bool   `json:"clearLocation"`
+	Status            string `json:"status"`
+	DescriptionAppend string `json:"descriptionAppend"`
+}
+
+type deviceBulkRequest struct {
+	Ids             []int64           `json:"ids"`
+	Filter          *deviceBulkFilter `json:"filter"`
+	Changes         deviceBulkChanges `json:"changes"`
+	Reason          string            `json:"reason"`
+	ContinueOnError bool              `json:"continueOnError"`
+}
+
+type deviceBulkResult struct {
+	Id    int64  `json:"id"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type deviceBulkResponse struct {
+	Applied bool               `json:"applied"`
+	Updated int                `json:"updated"`
+	Results []deviceBulkResult `json:"results"`
+}
+
+// toStoreFilter проверяет фильтр теми же правилами, что и GET /devices.
+func (f deviceBulkFilter) toStoreFilter() (store.DeviceFilter, string) {
+	v := url.Values{}
+	v.Set("q", f.Q)
+	if f.VendorId != nil {
+		v.Set("vendor_id", strconv.FormatInt(*f.VendorId, 10))
+	}
+	if f.ModelId != nil {
+		v.Set("model_id", strconv.FormatInt(*f.ModelId, 10))
+	}
+	if f.LocationId != nil {
+		v.Set("location_id", strconv.FormatInt(*f.LocationId, 10))
+	}
+	v["status"] = f.Status
+	v.Set("installed_from", f.InstalledFrom)
+	v.Set("installed_to", f.InstalledTo)
+	v.Set("ip", f.Ip)
+	v.Set("cidr", f.Cidr)
+	v.Set("mac", f.Mac)
+	for key, value := range f.CustomFields {
+		v.Set(customFieldFilterPrefix+key, value)
+	}
+	return parseDeviceFilter(v)
+}
+
+// handleDevicesBulkUpdate применяет одинаковые изменения к набору устройств в одной
+// транзакции. По умолчанию — «всё или ничего»: при ошибке хотя бы на одном
+// устройстве транзакция откатывается. С continueOnError каждое устройство
+// обновляется в своей точке сохранения, и ошибочные просто пропускаются.
+func (a *app) handleDevicesBulkUpdate(w http.ResponseWriter, r *http.Request) {
+	var req deviceBulkRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+
+	if (len(req.Ids) == 0) == (req.Filter == nil) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "ids_or_filter_required"})
+		return
+	}
+
+	changes := req.Changes
+	if changes.LocationId != nil && changes.ClearLocation {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_location_change"})
+		return
+	}
+	var toStatus string
+	if strings.TrimSpace(changes.Status) != "" {
+		var ok bool
+		toStatus, ok = normalizeDeviceStatus(changes.Status)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_status"})
+			return
+		}
+	}
+	descriptionAppend := strings.TrimSpace(changes.DescriptionAppend)
+	if changes.LocationId == nil && !changes.ClearLocation && toStatus == "" && descriptionAppend == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "changes_required"})
+		return
+	}
+
+	ctx := r.Context()
+
+	if changes.LocationId != nil {
+		exists, err := a.st.LocationExists(ctx, *changes.LocationId)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if !exists {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "location_not_found"})
+			return
+		}
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	ids := req.Ids
+	if req.Filter != nil {
+		filter, errCode := req.Filter.toStoreFilter()
+		if errCode != "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+			return
+		}
+		if errCode, err := a.resolveCustomFieldFilter(ctx, &filter); err != nil || errCode != "" {
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+			return
+		}
+		ids, err = bulkFilterDevices(ctx, a.st.WithTx(tx), filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+	if len(ids) > deviceBulkMaxDevices {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "too_many_devices"})
+		return
+	}
+
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+			return
+		}
+		if seen[id] {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "duplicate_id"})
+			return
+		}
+		seen[id] = true
+	}
+
+	resp := deviceBulkResponse{Results: make([]deviceBulkResult, 0, len(ids))}
+	failed := false
+	for _, id := range ids {
+		var errCode string
+		if req.ContinueOnError {
+			sp, err := tx.Begin(ctx)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+			errCode, err = bulkPatchDevice(r, a.st.WithTx(sp), id, changes, toStatus, descriptionAppend, req.Reason)
+			if err != nil {
+				_ = sp.Rollback(ctx)
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+			if errCode != "" {
+				err = sp.Rollback(ctx)
+			} else {
+				err = sp.Commit(ctx)
+			}
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+		} else {
+			errCode, err = bulkPatchDevice(r, a.st.WithTx(tx), id, changes, toStatus, descriptionAppend, req.Reason)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+		}
+
+		if errCode != "" {
+			failed = true
+			resp.Results = append(resp.Results, deviceBulkResult{Id: id, Error: errCode})
+			continue
+		}
+		resp.Updated++
+		resp.Results = append(resp.Results, deviceBulkResult{Id: id, Ok: true})
+	}
+
+	if failed && !req.ContinueOnError {
+		resp.Updated = 0
+		for i := range resp.Results {
+			resp.Results[i].Ok = false
+		}
+		writeJSON(w, http.StatusConflict, resp)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp.Applied = true
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// bulkFilterDevices выбирает устройства по фильтру внутри транзакции q и
+// блокирует их. Фильтр проверяется повторно уже по заблокированным строкам:
+// устройство, изменённое между выборкой и блокировкой так, что больше не
+// подходит, не попадает в операцию.
+func bulkFilterDevices(ctx context.Context, q *store.Queries, filter store.DeviceFilter) ([]int64, error) {
+	// Берём на одну строку больше лимита, чтобы отличить «ровно лимит» от «больше».
+	page := store.DevicePage{Sort: "id", Limit: deviceBulkMaxDevices + 1}
+	rows, err := q.ListDevices(ctx, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	if len(ids) == 0 || len(ids) > deviceBulkMaxDevices {
+		return ids, nil
+	}
+	if err := q.LockDevices(ctx, ids); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.ListDevices(ctx, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	matched := make(map[int64]bool, len(rows))
+	for _, row := range rows {
+		matched[row.ID] = true
+	}
+	out := ids[:0]
+	for _, id := range ids {
+		if matched[id] {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// bulkPatchDevice изменяет одно устройство. Ошибка уровня устройства возвращается
+// кодом, ошибка БД — через err. Смена статуса идёт через transitionDeviceStatus;
+// если локация и описание уже такие, как нужно, устройство не обновляется и
+// его версия (ETag) не меняется.
+func bulkPatchDevice(r *http.Request, qtx *store.Queries, id int64, changes deviceBulkChanges, toStatus, descriptionAppend, reason string) (string, error) {
+	ctx := r.Context()
+
+	before, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "not_found", nil
+		}
+		return "", err
+	}
+
+	locationID := before.LocationID
+	if changes.LocationId != nil {
+		locationID = changes.LocationId
+	}
+	if changes.ClearLocation {
+		locationID = nil
+	}
+
+	description := before.Description
+	if descriptionAppend != "" {
+		if description != "" {
+			description += "\n"
+		}
+		description += descriptionAppend
+	}
+
+	if toStatus != "" && toStatus != before.Status {
+		_, _, ok, err := transitionDeviceStatus(ctx, qtx, id, toStatus, reason, authUsername(ctx))
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "invalid_transition", nil
+		}
+		if before, err = qtx.GetDeviceSnapshot(ctx, id); err != nil {
+			return "", err
+		}
+	}
+
+	if sameLocation(before.LocationID, locationID) && description == before.Description {
+		return "", nil
+	}
+	if _, err := qtx.PatchDevice(ctx, id, locationID, before.Status, nullIfEmpty(description)); err != nil {
+		return "", err
+	}
+	if err := recordDeviceMove(ctx, qtx, id, before.LocationID, locationID, reason); err != nil {
+		return "", err
+	}
+
+	after, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryUpdate, &before, &after); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"telecombase/server/internal/store"
+	"telecombase/server/internal/xlsx"
+)
+
+var deviceExportHeader = []string{
+	"id",
+	"vendor",
+	"model",
+	"location",
+	"serial_number",
+	"inventory_number",
+	"status",
+	"installed_at",
+	"description",
+}
+
+// csvSafe защищает ячейку CSV от выполнения как формулы в табличном
+// редакторе (CSV injection): значение, начинающееся с =, +, -, @, табуляции
+// или перевода каретки, предваряется апострофом.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// handleDevicesExport выгружает устройства с теми же фильтрами, поиском и
+// сортировкой, что и GET /devices, но без пагинации. Строки пишутся в ответ по
+// мере чтения из БД.
+func (a *app) handleDevicesExport(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_format"})
+		return
+	}
+
+	filter, errCode := parseDeviceFilter(r.URL.Query())
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	if errCode, err := a.resolveCustomFieldFilter(r.Context(), &filter); err != nil || errCode != "" {
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	page, errCode := parseDevicePage(r.URL.Query(), filter)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	page = store.DevicePage{Sort: page.Sort, Desc: page.Desc}
+
+	filename := "devices-" + time.Now().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	// После первой записи статус уже отправлен, поэтому ошибки только логируются,
+	// а соединение обрывается — клиент получит неполный файл, а не «успех».
+	abort := func(err error) {
+		log.Printf("devices export: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		// BOM, чтобы Excel открыл UTF-8 без мастера импорта.
+		_, _ = w.Write([]byte("\xef\xbb\xbf"))
+
+		cw := csv.NewWriter(w)
+		_ = cw.Write(deviceExportHeader)
+		n := 0
+		err := a.st.EachDevice(r.Context(), filter, page, func(row store.ListDevicesRow) error {
+			if err := cw.Write([]string{
+				strconv.FormatInt(row.ID, 10),
+				csvSafe(row.VendorName),
+				csvSafe(row.ModelName),
+				csvSafe(row.LocationName),
+				csvSafe(row.SerialNumber),
+				csvSafe(row.InventoryNumber),
+				row.Status,
+				row.InstalledAt,
+				csvSafe(row.Description),
+			}); err != nil {
+				return err
+			}
+			n++
+			if n%500 == 0 {
+				cw.Flush()
+			}
+			return cw.Error()
+		})
+		if err != nil {
+			abort(err)
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			abort(err)
+		}
+
+	case "xlsx":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.WriteHeader(http.StatusOK)
+
+		xw, err := xlsx.NewWriter(w, "Devices")
+		if err != nil {
+			abort(err)
+		}
+		if err := xw.WriteHeader(deviceExportHeader...); err != nil {
+			abort(err)
+		}
+		err = a.st.EachDevice(r.Context(), filter, page, func(row store.ListDevicesRow) error {
+			return xw.WriteRow(
+				row.ID,
+				row.VendorName,
+				row.ModelName,
+				row.LocationName,
+				row.SerialNumber,
+				row.InventoryNumber,
+				row.Status,
+				row.InstalledAt,
+				row.Description,
+			)
+		})
+		if err != nil {
+			abort(err)
+		}
+		if err := xw.Close(); err != nil {
+			abort(err)
+		}
+	}
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	deviceImportMaxBytes = 10 << 20
+	deviceImportMaxRows  = 10000
+)
+
+// Колонки CSV. Заголовки сравниваются без регистра, пробелов, '_' и '-',
+// поэтому подходят и "serial_number", и "Serial Number", и "serialNumber".
+// Пользовательские поля устройства задаются колонками cf.<key>, как в фильтре
+// GET /devices.
+const (
+	importColVendor          = "vendor"
+	importColModel           = "model"
+	importColDeviceType      = "device_type"
+	importColLocation        = "location"
+	importColSerialNumber    = "serial_number"
+	importColInventoryNumber = "inventory_number"
+	importColStatus          = "status"
+	importColInstalledAt     = "installed_at"
+	importColDescription     = "description"
+)
+
+var deviceImportHeaderAliases = map[string]string{
+	"vendor":           importColVendor,
+	"vendorname":       importColVendor,
+	"производитель":    importColVendor,
+	"model":            importColModel,
+	"modelname":        importColModel,
+	"модель":           importColModel,
+	"devicetype":       importColDeviceType,
+	"тип":              importColDeviceType,
+	"location":         importColLocation,
+	"locationname":     importColLocation,
+	"локация":          importColLocation,
+	"serial":           importColSerialNumber,
+	"serialnumber":     importColSerialNumber,
+	"серийный":         importColSerialNumber,
+	"серийныйномер":    importColSerialNumber,
+	"inventory":        importColInventoryNumber,
+	"inventorynumber":  importColInventoryNumber,
+	"инвентарный":      importColInventoryNumber,
+	"инвентарныйномер": importColInventoryNumber,
+	"status":           importColStatus,
+	"статус":           importColStatus,
+	"installedat":      importColInstalledAt,
+	"датаустановки":    importColInstalledAt,
+	"description":      importColDescription,
+	"описание":         importColDescription,
+}
+
+type deviceImportRowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+type deviceImportReport struct {
+	DryRun           bool                   `json:"dryRun"`
+	Rows             int                    `json:"rows"`
+	Valid            int                    `json:"valid"`
+	Created          int                    `json:"created"`
+	Ids              []int64                `json:"ids"`
+	Errors           []deviceImportRowError `json:"errors"`
+	MissingVendors   []string               `json:"missingVendors"`
+	MissingModels    []string               `json:"missingModels"`
+	MissingLocations []string               `json:"missingLocations"`
+}
+
+type csvColumnError struct {
+	Error  string `json:"error"`
+	Column string `json:"column"`
+}
+
+// deviceImportRow — строка CSV после разрешения справочников. Если запись
+// справочника ещё не существует (create_missing), id равен 0, а ключ указывает
+// на запись, которую нужно создать.
+type deviceImportRow struct {
+	line        int
+	vendorKey   string
+	modelKey    string
+	locationKey string
+	modelId     int64
+	locationId  *int64
+	input       deviceInput
+}
+
+type pendingModel struct {
+	vendorKey  string
+	name       string
+	deviceType string
+}
+
+func importNameKey(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// importCustomFieldValue переводит ячейку CSV в JSON-значение поля: целые и
+// логические — без кавычек, остальное строкой. Нераспознанное значение тоже
+// уходит строкой, и проверка типа поля отклоняет его.
+func importCustomFieldValue(d store.CustomFieldDefinition, s string) json.RawMessage {
+	switch d.FieldType {
+	case customFieldTypeInt:
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.RawMessage(s)
+		}
+	case customFieldTypeBool:
+		switch strings.ToLower(s) {
+		case "true", "1", "yes", "да":
+			return json.RawMessage("true")
+		case "false", "0", "no", "нет":
+			return json.RawMessage("false")
+		}
+	}
+	raw, _ := json.Marshal(s)
+	return raw
+}
+
+func normalizeImportHeader(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(s)
+}
+
+// readDeviceImportCSV принимает CSV в теле запроса (text/csv) или файлом в поле
+// "file" multipart-формы. Разделитель (',' или ';') определяется по заголовку.
+func readDeviceImportCSV(w http.ResponseWriter, r *http.Request) ([][]string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, deviceImportMaxBytes)
+
+	var src io.Reader = r.Body
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		src = file
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	firstLine, _, _ := bufio.NewReader(bytes.NewReader(data)).ReadLine()
+	cr := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return cr.ReadAll()
+}
+
+func (a *app) handleDevicesImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dryRun, _ := strconv.ParseBool(query.Get("dry_run"))
+	createMissing, _ := strconv.ParseBool(query.Get("create_missing"))
+
+	// Справочники создаёт только admin — так же, как в /vendors, /models, /locations.
+	if createMissing && authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	records, err := readDeviceImportCSV(w, r)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "file_too_large"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_csv"})
+		return
+	}
+	if len(records) < 2 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "no_rows"})
+		return
+	}
+	if len(records)-1 > deviceImportMaxRows {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "too_many_rows"})
+		return
+	}
+
+	columns := make(map[string]int)
+	customColumns := make(map[string]int)
+	for i, h := range records[0] {
+		if name := strings.TrimSpace(h); len(name) > len(customFieldFilterPrefix) &&
+			strings.EqualFold(name[:len(customFieldFilterPrefix)], customFieldFilterPrefix) {
+			key := name[len(customFieldFilterPrefix):]
+			if _, dup := customColumns[key]; dup {
+				writeJSON(w, http.StatusBadRequest, csvColumnError{Error: "duplicate_column", Column: h})
+				return
+			}
+			customColumns[key] = i
+			continue
+		}
+		col, ok := deviceImportHeaderAliases[normalizeImportHeader(h)]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, csvColumnError{Error: "unknown_column", Column: h})
+			return
+		}
+		if _, dup := columns[col]; dup {
+			writeJSON(w, http.StatusBadRequest, csvColumnError{Error: "duplicate_column", Column: h})
+			return
+		}
+		columns[col] = i
+	}
+	for _, required := range []string{importColVendor, importColModel} {
+		if _, ok := columns[required]; !ok {
+			writeJSON(w, http.StatusBadRequest, csvColumnError{Error: "missing_column", Column: required})
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	vendorRows, err := a.st.ListVendors(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	modelRows, err := a.st.ListModels(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	locationRows, err := a.st.ListLocations(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	customFieldDefs, err := a.st.ListCustomFieldDefinitions(ctx, customFieldScopeDevice)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	customFieldByKey := make(map[string]store.CustomFieldDefinition, len(customFieldDefs))
+	for _, d := range customFieldDefs {
+		customFieldByKey[d.Key] = d
+	}
+	for key, i := range customColumns {
+		if _, ok := customFieldByKey[key]; !ok {
+			writeJSON(w, http.StatusBadRequest, csvColumnError{Error: "unknown_column", Column: records[0][i]})
+			return
+		}
+	}
+
+	vendors := make(map[string]int64)
+	for _, v := range vendorRows {
+		if _, ok := vendors[importNameKey(v.Name)]; !ok {
+			vendors[importNameKey(v.Name)] = v.ID
+		}
+	}
+	models := make(map[string]int64)
+	modelTypes := make(map[string]string)
+	for _, m := range modelRows {
+		key := importNameKey(m.VendorName) + "\x00" + importNameKey(m.Name)
+		if _, ok := models[key]; !ok {
+			models[key] = m.ID
+			modelTypes[key] = m.DeviceType
+		}
+	}
+	// Локацию можно указать полным путём («ЦОД / Зал 1 / A3») или просто именем;
+	// при совпадении имён берётся первая по пути.
+	locations := make(map[string]int64)
+	for _, l := range locationRows {
+		locations[importNameKey(l.Path)] = l.ID
+	}
+	for _, l := range locationRows {
+		if _, ok := locations[importNameKey(l.Name)]; !ok {
+			locations[importNameKey(l.Name)] = l.ID
+		}
+	}
+
+	cell := func(rec []string, col string) string {
+		i, ok := columns[col]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	report := deviceImportReport{
+		DryRun:           dryRun,
+		Ids:              []int64{},
+		Errors:           []deviceImportRowError{},
+		MissingVendors:   []string{},
+		MissingModels:    []string{},
+		MissingLocations: []string{},
+	}
+
+	pendingVendors := make(map[string]string)
+	pendingModels := make(map[string]pendingModel)
+	pendingLocations := make(map[string]string)
+	// Номера сравниваются по тем же нормализованным ключам, что и в БД.
+	serialLines := make(map[string]int)
+	inventoryLines := make(map[string]int)
+
+	var rows []deviceImportRow
+	for i, rec := range records[1:] {
+		line := i + 2
+		if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		report.Rows++
+
+		var rowErrors []deviceImportRowError
+		addErr := func(field, code string) {
+			rowErrors = append(rowErrors, deviceImportRowError{Row: line, Field: field, Error: code})
+		}
+
+		row := deviceImportRow{line: line}
+
+		vendorName := cell(rec, importColVendor)
+		modelName := cell(rec, importColModel)
+		row.vendorKey = importNameKey(vendorName)
+		row.modelKey = row.vendorKey + "\x00" + importNameKey(modelName)
+
+		vendorKnown := false
+		switch {
+		case vendorName == "":
+			addErr(importColVendor, "vendor_required")
+		case vendors[row.vendorKey] != 0:
+			vendorKnown = true
+		case createMissing:
+			if _, ok := pendingVendors[row.vendorKey]; !ok {
+				pendingVendors[row.vendorKey] = vendorName
+				report.MissingVendors = append(report.MissingVendors, vendorName)
+			}
+		default:
+			addErr(importColVendor, "vendor_not_found")
+		}
+
+		switch {
+		case modelName == "":
+			addErr(importColModel, "model_required")
+		case vendorKnown && models[row.modelKey] != 0:
+			row.modelId = models[row.modelKey]
+		case vendorName == "":
+		case createMissing:
+			if _, ok := pendingModels[row.modelKey]; !ok {
+				pendingModels[row.modelKey] = pendingModel{
+					vendorKey:  row.vendorKey,
+					name:       modelName,
+					deviceType: cell(rec, importColDeviceType),
+				}
+				report.MissingModels = append(report.MissingModels, vendorName+" "+modelName)
+			}
+		case vendorKnown:
+			addErr(importColModel, "model_not_found")
+		}
+
+		if locationName := cell(rec, importColLocation); locationName != "" {
+			row.locationKey = importNameKey(locationName)
+			switch {
+			case locations[row.locationKey] != 0:
+				id := locations[row.locationKey]
+				row.locationId = &id
+			case createMissing:
+				if _, ok := pendingLocations[row.locationKey]; !ok {
+					pendingLocations[row.locationKey] = locationName
+					report.MissingLocations = append(report.MissingLocations, locationName)
+				}
+			default:
+				addErr(importColLocation, "location_not_found")
+			}
+		}
+
+		// Те же правила, что и в POST /devices, кроме модели: её проверка выше,
+		// а сама модель может быть ещё не создана.
+		in, errCode := validateDeviceFields(deviceUpsertRequest{
+			SerialNumber:    cell(rec, importColSerialNumber),
+			InventoryNumber: cell(rec, importColInventoryNumber),
+			Status:          cell(rec, importColStatus),
+			InstalledAt:     cell(rec, importColInstalledAt),
+			Description:     cell(rec, importColDescription),
+		})
+		switch errCode {
+		case "":
+		case "invalid_status":
+			addErr(importColStatus, errCode)
+		case "invalid_installed_at":
+			addErr(importColInstalledAt, errCode)
+		default:
+			addErr("", errCode)
+		}
+		// Пользовательские поля проверяются по типу устройства модели (у
+		// создаваемой — из колонки device_type), иначе пробный прогон не видит
+		// обязательных полей и импорт падает на первой же строке.
+		if pm, pending := pendingModels[row.modelKey]; row.modelId != 0 || pending {
+			deviceType := modelTypes[row.modelKey]
+			if pending {
+				deviceType = pm.deviceType
+			}
+			input := make(map[string]json.RawMessage, len(customColumns))
+			for key, i := range customColumns {
+				if i < len(rec) && strings.TrimSpace(rec[i]) != "" {
+					input[key] = importCustomFieldValue(customFieldByKey[key], strings.TrimSpace(rec[i]))
+				}
+			}
+			if _, err := mergeCustomFields(customFieldDefs, deviceType, nil, input); err != nil {
+				var cfErr *customFieldError
+				if !errors.As(err, &cfErr) {
+					writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+					return
+				}
+				addErr(customFieldFilterPrefix+cfErr.Field, cfErr.Reason)
+			}
+			in.CustomFields = input
+		}
+		row.input = in
+
+		if key := normalizeSerialKey(in.SerialNumber); key != "" {
+			if _, dup := serialLines[key]; dup {
+				addErr(importColSerialNumber, "serial_duplicate_in_file")
+			} else {
+				serialLines[key] = line
+			}
+		}
+		if key := normalizeInventoryKey(in.InventoryNumber); key != "" && a.cfg.inventoryUnique {
+			if _, dup := inventoryLines[key]; dup {
+				addErr(importColInventoryNumber, "inventory_duplicate_in_file")
+			} else {
+				inventoryLines[key] = line
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			report.Errors = append(report.Errors, rowErrors...)
+			continue
+		}
+		report.Valid++
+		rows = append(rows, row)
+	}
+
+	takenChecks := []struct {
+		lines map[string]int
+		list  func(context.Context, []string) ([]string, error)
+		field string
+		code  string
+	}{
+		{serialLines, a.st.ListExistingDeviceSerialKeys, importColSerialNumber, "serial_taken"},
+		{inventoryLines, a.st.ListExistingDeviceInventoryKeys, importColInventoryNumber, "inventory_taken"},
+	}
+	invalidLines := make(map[int]bool)
+	for _, check := range takenChecks {
+		if len(check.lines) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(check.lines))
+		for key := range check.lines {
+			keys = append(keys, key)
+		}
+		taken, err := check.list(ctx, keys)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		for _, key := range taken {
+			line := check.lines[key]
+			invalidLines[line] = true
+			report.Errors = append(report.Errors, deviceImportRowError{Row: line, Field: check.field, Error: check.code})
+		}
+	}
+	if len(invalidLines) > 0 {
+		valid := rows[:0]
+		for _, row := range rows {
+			if !invalidLines[row.line] {
+				valid = append(valid, row)
+			}
+		}
+		rows = valid
+		report.Valid = len(rows)
+	}
+
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+
+	if dryRun {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	if len(report.Errors) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	for key, name := range pendingVendors {
+		id, err := qtx.CreateVendor(ctx, name, nil)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		vendors[key] = id
+	}
+	for key, m := range pendingModels {
+		id, err := qtx.CreateModel(ctx, vendors[m.vendorKey], m.name, nullIfEmpty(m.deviceType), defaultModelRackSize, store.ModelLifecycle{})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		models[key] = id
+	}
+	for key, name := range pendingLocations {
+		id, err := qtx.CreateLocation(ctx, name, nil, nil, locationTypeSite, nil, store.LocationGeo{})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		locations[key] = id
+	}
+
+	for _, row := range rows {
+		in := row.input
+		in.ModelId = row.modelId
+		if in.ModelId == 0 {
+			in.ModelId = models[row.modelKey]
+		}
+		in.LocationId = row.locationId
+		if in.LocationId == nil && row.locationKey != "" {
+			id := locations[row.locationKey]
+			in.LocationId = &id
+		}
+
+		// Номера могли занять параллельно с импортом.
+		conflict, err := a.checkDeviceUnique(ctx, qtx, 0, in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if conflict == nil {
+			var id int64
+			id, err = createDevice(ctx, qtx, in)
+			if err == nil {
+				report.Ids = append(report.Ids, id)
+				continue
+			}
+			// Поля проверены при разборе строк, но их определения могли
+			// поменяться до записи.
+			var cfErr *customFieldError
+			if errors.As(err, &cfErr) {
+				report.Errors = append(report.Errors, deviceImportRowError{Row: row.line, Field: customFieldFilterPrefix + cfErr.Field, Error: cfErr.Reason})
+				report.Valid--
+				report.Ids = []int64{}
+				writeJSON(w, http.StatusUnprocessableEntity, report)
+				return
+			}
+			if !isUniqueViolation(err, deviceSerialKeyConstraint) {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+			conflict = &deviceUniqueConflict{Error: "serial_taken", Field: "serialNumber"}
+		}
+
+		field := importColSerialNumber
+		if conflict.Field == "inventoryNumber" {
+			field = importColInventoryNumber
+		}
+		report.Errors = append(report.Errors, deviceImportRowError{Row: row.line, Field: field, Error: conflict.Error})
+		report.Valid--
+		report.Ids = []int64{}
+		writeJSON(w, http.StatusConflict, report)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	report.Created = len(report.Ids)
+	writeJSON(w, http.StatusCreated, report)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	devicesPageDefaultLimit = 50
+	devicesPageMaxLimit     = 500
+)
+
+type deviceListResponse struct {
+	Items         []deviceListItem `json:"items"`
+	Total         int64            `json:"total"`
+	NextPageToken string           `json:"nextPageToken"`
+}
+
+// devicePageToken — непрозрачный курсор страницы (base64url от JSON).
+// Сортировка зашита в токен, чтобы курсор нельзя было применить к другому порядку.
+type devicePageToken struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Key  string `json:"k"`
+	ID   int64  `json:"i"`
+}
+
+func encodeDevicePageToken(t devicePageToken) string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeDevicePageToken(s string) (devicePageToken, error) {
+	var t devicePageToken
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return t, err
+	}
+	if t.ID <= 0 {
+		return t, errors.New("invalid page token id")
+	}
+	return t, nil
+}
+
+func parseOptionalID(v string) (*int64, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.New("invalid id")
+	}
+	return &id, nil
+}
+
+// parseDeviceFilter разбирает фильтры списка устройств из query string.
+// Возвращает код ошибки API, если параметр некорректен.
+func parseDeviceFilter(q url.Values) (store.DeviceFilter, string) {
+	var f store.DeviceFilter
+	var err error
+
+	f.Query = strings.TrimSpace(q.Get("q"))
+
+	if f.VendorID, err = parseOptionalID(q.Get("vendor_id")); err != nil {
+		return f, "invalid_vendor_id"
+	}
+	if f.ModelID, err = parseOptionalID(q.Get("model_id")); err != nil {
+		return f, "invalid_model_id"
+	}
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		return f, "invalid_location_id"
+	}
+
+	// status=active,faulty или status=active&status=faulty
+	for _, v := range q["status"] {
+		for _, part := range strings.Split(v, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			status, ok := normalizeDeviceStatus(part)
+			if !ok {
+				return f, "invalid_status"
+			}
+			f.Statuses = append(f.Statuses, status)
+		}
+	}
+
+	if f.InstalledFrom, err = parseDateYYYYMMDD(q.Get("installed_from")); err != nil {
+		return f, "invalid_installed_from"
+	}
+	if f.InstalledTo, err = parseDateYYYYMMDD(q.Get("installed_to")); err != nil {
+		return f, "invalid_installed_to"
+	}
+	if f.InstalledFrom != nil && f.InstalledTo != nil && f.InstalledTo.Before(*f.InstalledFrom) {
+		return f, "invalid_installed_range"
+	}
+
+	// Адреса принимаются в любой записи и сравниваются в нормализованном виде.
+	var ok bool
+	if v := strings.TrimSpace(q.Get("ip")); v != "" {
+		if f.IP, ok = normalizeIPAddress(v); !ok {
+			return f, "invalid_ip"
+		}
+	}
+	if v := strings.TrimSpace(q.Get("cidr")); v != "" {
+		if f.CIDR, ok = normalizeCIDR(v); !ok {
+			return f, "invalid_cidr"
+		}
+	}
+	if v := strings.TrimSpace(q.Get("mac")); v != "" {
+		if f.MAC, ok = normalizeMACAddress(v); !ok {
+			return f, "invalid_mac"
+		}
+	}
+
+	// Фильтры cf.<key> проверяются по определениям полей в resolveCustomFieldFilter.
+	f.CustomFields = parseCustomFieldFilters(q)
+
+	return f, ""
+}
+
+// parseDevicePage разбирает sort/order/limit/page_token. По умолчанию — новые
+// устройства сверху (id DESC), как было до появления пагинации; при поиске —
+// по релевантности.
+func parseDevicePage(q url.Values, f store.DeviceFilter) (store.DevicePage, string) {
+	p := store.DevicePage{Sort: "id", Desc: true, Limit: devicesPageDefaultLimit}
+	if f.Query != "" {
+		p.Sort = "relevance"
+	}
+
+	if v := strings.TrimSpace(q.Get("sort")); v != "" {
+		if !store.DeviceSortValid(v) {
+			return p, "invalid_sort"
+		}
+		p.Sort = v
+		p.Desc = v == "relevance"
+	}
+	switch strings.ToLower(strings.TrimSpace(q.Get("order"))) {
+	case "":
+	case "asc":
+		p.Desc = false
+	case "desc":
+		p.Desc = true
+	default:
+		return p, "invalid_order"
+	}
+
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > devicesPageMaxLimit {
+			return p, "invalid_limit"
+		}
+		p.Limit = int32(limit)
+	}
+
+	if v := strings.TrimSpace(q.Get("page_token")); v != "" {
+		t, err := decodeDevicePageToken(v)
+		if err != nil || t.Sort != p.Sort || t.Desc != p.Desc {
+			return p, "invalid_page_token"
+		}
+		p.After = true
+		p.AfterKey = t.Key
+		p.AfterID = t.ID
+	}
+
+	return p, ""
+}
+
+func (a *app) handleDevicesList(w http.ResponseWriter, r *http.Request) {
+	filter, errCode := parseDeviceFilter(r.URL.Query())
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	if errCode, err := a.resolveCustomFieldFilter(r.Context(), &filter); err != nil || errCode != "" {
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	page, errCode := parseDevicePage(r.URL.Query(), filter)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	total, err := a.st.CountDevices(r.Context(), filter)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	// Читаем на одну строку больше, чтобы понять, есть ли следующая страница.
+	limit := page.Limit
+	page.Limit = limit + 1
+	rows, err := a.st.ListDevices(r.Context(), filter, page)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := deviceListResponse{Items: make([]deviceListItem, 0, len(rows)), Total: total}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		resp.NextPageToken = encodeDevicePageToken(devicePageToken{
+			Sort: page.Sort,
+			Desc: page.Desc,
+			Key:  store.DeviceSortKey(page.Sort, last),
+			ID:   last.ID,
+		})
+	}
+
+	for _, row := range rows {
+		resp.Items = append(resp.Items, deviceListItem{
+			Id:              row.ID,
+			VendorName:      row.VendorName,
+			ModelName:       row.ModelName,
+			LocationName:    row.LocationName,
+			SerialNumber:    row.SerialNumber,
+			InventoryNumber: row.InventoryNumber,
+			Status:          row.Status,
+			InstalledAt:     row.InstalledAt,
+			Hostname:        row.Hostname,
+			ManagementIp:    row.ManagementIP,
+			Rank:            row.Rank,
+			Match:           findDeviceSearchMatch(filter.Query, row),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
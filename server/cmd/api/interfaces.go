@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	interfaceNameUniqueConstraint = "device_interfaces_device_name_unique"
+	interfaceNameMaxLen           = 64
+)
+
+var interfaceTypes = map[string]bool{
+	"ethernet": true,
+	"optical":  true,
+	"serial":   true,
+	"wireless": true,
+	"virtual":  true,
+	"lag":      true,
+	"other":    true,
+}
+
+type deviceInterfaceItem struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	SpeedMbps   *int64 `json:"speedMbps"`
+	AdminState  string `json:"adminState"`
+	MacAddress  string `json:"macAddress"`
+	Description string `json:"description"`
+	// Link — другой конец линка; nil, если порт свободен.
+	Link *deviceInterfaceLink `json:"link"`
+}
+
+type deviceInterfaceLink struct {
+	Id                 int64  `json:"id"`
+	PeerDeviceId       int64  `json:"peerDeviceId"`
+	PeerDeviceHostname string `json:"peerDeviceHostname"`
+	PeerInterfaceId    int64  `json:"peerInterfaceId"`
+	PeerInterfaceName  string `json:"peerInterfaceName"`
+}
+
+type deviceInterfaceUpsertRequest struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	SpeedMbps   *int64 `json:"speedMbps"`
+	AdminState  string `json:"adminState"`
+	MacAddress  string `json:"macAddress"`
+	Description string `json:"description"`
+}
+
+type linkCreateRequest struct {
+	AInterfaceId int64  `json:"aInterfaceId"`
+	BInterfaceId int64  `json:"bInterfaceId"`
+	CableType    string `json:"cableType"`
+	Description  string `json:"description"`
+}
+
+// linkConflict — ответ 409, когда порт уже занят другим линком.
+type linkConflict struct {
+	Error       string `json:"error"`
+	InterfaceId int64  `json:"interfaceId"`
+}
+
+// validateDeviceInterface проверяет поля интерфейса; пустые тип и состояние —
+// ethernet и up.
+func validateDeviceInterface(req deviceInterfaceUpsertRequest) (store.DeviceInterfaceParams, string) {
+	p := store.DeviceInterfaceParams{
+		Name:       strings.TrimSpace(req.Name),
+		Type:       strings.ToLower(strings.TrimSpace(req.Type)),
+		SpeedMbps:  req.SpeedMbps,
+		AdminState: strings.ToLower(strings.TrimSpace(req.AdminState)),
+	}
+	if p.Name == "" {
+		return p, "name_required"
+	}
+	if len(p.Name) > interfaceNameMaxLen {
+		return p, "invalid_name"
+	}
+	if p.Type == "" {
+		p.Type = "ethernet"
+	}
+	if !interfaceTypes[p.Type] {
+		return p, "invalid_type"
+	}
+	if p.SpeedMbps != nil && *p.SpeedMbps <= 0 {
+		return p, "invalid_speed"
+	}
+	switch p.AdminState {
+	case "":
+		p.AdminState = "up"
+	case "up", "down":
+	default:
+		return p, "invalid_admin_state"
+	}
+	if v := strings.TrimSpace(req.MacAddress); v != "" {
+		mac, ok := normalizeMACAddress(v)
+		if !ok {
+			return p, "invalid_mac_address"
+		}
+		p.MACAddress = mac
+	}
+	p.Description = nullIfEmpty(strings.TrimSpace(req.Description))
+	return p, ""
+}
+
+// parseDeviceInterfacePath разбирает {id} и {interfaceId} из пути.
+func parseDeviceInterfacePath(r *http.Request) (deviceID, interfaceID int64, ok bool) {
+	deviceID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || deviceID <= 0 {
+		return 0, 0, false
+	}
+	interfaceID, err = strconv.ParseInt(r.PathValue("interfaceId"), 10, 64)
+	if err != nil || interfaceID <= 0 {
+		return 0, 0, false
+	}
+	return deviceID, interfaceID, true
+}
+
+func (a *app) handleDeviceInterfacesList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	exists, err := a.st.DeviceExists(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	rows, err := a.st.ListDeviceInterfaces(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]deviceInterfaceItem, 0, len(rows))
+	for _, row := range rows {
+		it := deviceInterfaceItem{
+			Id:          row.ID,
+			Name:        row.Name,
+			Type:        row.Type,
+			SpeedMbps:   row.SpeedMbps,
+			AdminState:  row.AdminState,
+			MacAddress:  row.MACAddress,
+			Description: row.Description,
+		}
+		if row.LinkID != nil && row.PeerInterfaceID != nil && row.PeerDeviceID != nil {
+			it.Link = &deviceInterfaceLink{
+				Id:                 *row.LinkID,
+				PeerDeviceId:       *row.PeerDeviceID,
+				PeerDeviceHostname: row.PeerDeviceHostname,
+				PeerInterfaceId:    *row.PeerInterfaceID,
+				PeerInterfaceName:  row.PeerInterfaceName,
+			}
+		}
+		items = append(items, it)
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleDeviceInterfacesCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req deviceInterfaceUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	params, errCode := validateDeviceInterface(req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	exists, err := a.st.DeviceExists(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	ifaceID, err := a.st.CreateDeviceInterface(r.Context(), id, params)
+	if err != nil {
+		if isUniqueViolation(err, interfaceNameUniqueConstraint) {
+			writeJSON(w, http.StatusConflict, apiError{Error: "interface_name_taken"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: ifaceID})
+}
+
+func (a *app) handleDeviceInterfacesUpdate(w http.ResponseWriter, r *http.Request) {
+	deviceID, ifaceID, ok := parseDeviceInterfacePath(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req deviceInterfaceUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	params, errCode := validateDeviceInterface(req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	affected, err := a.st.UpdateDeviceInterface(r.Context(), ifaceID, deviceID, params)
+	if err != nil {
+		if isUniqueViolation(err, interfaceNameUniqueConstraint) {
+			writeJSON(w, http.StatusConflict, apiError{Error: "interface_name_taken"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: ifaceID})
+}
+
+// handleDeviceInterfacesDelete удаляет интерфейс вместе с его линком.
+func (a *app) handleDeviceInterfacesDelete(w http.ResponseWriter, r *http.Request) {
+	deviceID, ifaceID, ok := parseDeviceInterfacePath(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	affected, err := a.st.DeleteDeviceInterface(r.Context(), ifaceID, deviceID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handleLinksCreate соединяет два интерфейса. Строки интерфейсов блокируются,
+// поэтому два параллельных запроса не подключат один порт дважды.
+func (a *app) handleLinksCreate(w http.ResponseWriter, r *http.Request) {
+	var req linkCreateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	if req.AInterfaceId <= 0 || req.BInterfaceId <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "interfaces_required"})
+		return
+	}
+	if req.AInterfaceId == req.BInterfaceId {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "same_interface"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	ends, err := qtx.LockLinkInterfaces(ctx, []int64{req.AInterfaceId, req.BInterfaceId})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if len(ends) != 2 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "interface_not_found"})
+		return
+	}
+	for _, end := range ends {
+		if end.DeviceInTrash {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "interface_not_found"})
+			return
+		}
+		if end.Linked {
+			writeJSON(w, http.StatusConflict, linkConflict{Error: "interface_already_linked", InterfaceId: end.ID})
+			return
+		}
+	}
+
+	id, err := qtx.CreateLink(ctx, req.AInterfaceId, req.BInterfaceId,
+		nullIfEmpty(strings.TrimSpace(req.CableType)), nullIfEmpty(strings.TrimSpace(req.Description)), authUsername(ctx))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			writeJSON(w, http.StatusConflict, apiError{Error: "interface_already_linked"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (a *app) handleLinksDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	affected, err := a.st.DeleteLink(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
@@ -0,0 +1,682 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	ipAddressStatusActive     = "active"
+	ipAddressStatusReserved   = "reserved"
+	ipAddressStatusDeprecated = "deprecated"
+
+	ipAddressesHostUniqueConstraint = "ip_addresses_vrf_host_unique"
+	prefixesUniqueConstraint        = "prefixes_vrf_prefix_unique"
+)
+
+var ipAddressStatuses = map[string]bool{
+	ipAddressStatusActive:     true,
+	ipAddressStatusReserved:   true,
+	ipAddressStatusDeprecated: true,
+}
+
+type prefixItem struct {
+	Id           int64  `json:"id"`
+	Prefix       string `json:"prefix"`
+	Vrf          string `json:"vrf"`
+	VlanId       *int32 `json:"vlanId"`
+	LocationId   *int64 `json:"locationId"`
+	LocationName string `json:"locationName"`
+	Description  string `json:"description"`
+	Used         int64  `json:"used"`
+	// Total — число адресов десятичной строкой: у IPv6-префиксов оно выходит
+	// за точность чисел JSON (2⁵³).
+	Total       string  `json:"total"`
+	Utilization float64 `json:"utilization"`
+}
+
+type prefixUpsertRequest struct {
+	Prefix      string `json:"prefix"`
+	Vrf         string `json:"vrf"`
+	VlanId      *int32 `json:"vlanId"`
+	LocationId  *int64 `json:"locationId"`
+	Description string `json:"description"`
+}
+
+type ipAddressItem struct {
+	Id             int64  `json:"id"`
+	Address        string `json:"address"`
+	Vrf            string `json:"vrf"`
+	DeviceId       *int64 `json:"deviceId"`
+	DeviceHostname string `json:"deviceHostname"`
+	Status         string `json:"status"`
+	Description    string `json:"description"`
+	CreatedBy      string `json:"createdBy"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+type ipAddressCreateRequest struct {
+	Address     string `json:"address"`
+	Vrf         string `json:"vrf"`
+	DeviceId    *int64 `json:"deviceId"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// ipAddressUpdateRequest меняет привязку и атрибуты адреса; сам адрес и VRF
+// неизменяемы — для переноса адрес удаляется и выделяется заново.
+type ipAddressUpdateRequest struct {
+	DeviceId    *int64 `json:"deviceId"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+type prefixAllocateRequest struct {
+	DeviceId    *int64 `json:"deviceId"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// prefixLastAddr возвращает последний адрес префикса (все биты хоста — единицы).
+func prefixLastAddr(p netip.Prefix) netip.Addr {
+	p = p.Masked()
+	raw := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(raw)*8; i++ {
+		raw[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return addr
+}
+
+// prefixRange возвращает диапазон адресов, доступных для выделения, и его размер.
+// В IPv4 адрес сети и широковещательный не выделяются (кроме /31 и /32),
+// в IPv6 — адрес сети (anycast-адрес маршрутизаторов подсети, кроме /127 и /128).
+func prefixRange(p netip.Prefix) (first, last netip.Addr, total *big.Int) {
+	p = p.Masked()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	first, last = p.Addr(), prefixLastAddr(p)
+	total = new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+
+	switch {
+	case p.Addr().Is4() && hostBits >= 2:
+		first, last = first.Next(), last.Prev()
+		total.Sub(total, big.NewInt(2))
+	case p.Addr().Is6() && hostBits >= 2:
+		first = first.Next()
+		total.Sub(total, big.NewInt(1))
+	}
+	return first, last, total
+}
+
+// prefixUtilization возвращает долю занятых адресов в процентах с точностью
+// до сотых.
+func prefixUtilization(used int64, total *big.Int) float64 {
+	if total.Sign() <= 0 {
+		return 0
+	}
+	ratio := new(big.Float).Quo(new(big.Float).SetInt64(used*100), new(big.Float).SetInt(total))
+	pct, _ := ratio.Float64()
+	if pct > 100 {
+		pct = 100
+	}
+	return float64(int64(pct*100+0.5)) / 100
+}
+
+func prefixItemFromRow(row store.PrefixRow) prefixItem {
+	item := prefixItem{
+		Id:           row.ID,
+		Prefix:       row.Prefix,
+		Vrf:          row.VRF,
+		VlanId:       row.VlanID,
+		LocationId:   row.LocationID,
+		LocationName: row.LocationName,
+		Description:  row.Description,
+		Used:         row.Used,
+		Total:        "0",
+	}
+	if p, err := netip.ParsePrefix(row.Prefix); err == nil {
+		_, _, total := prefixRange(p)
+		item.Total = total.String()
+		item.Utilization = prefixUtilization(row.Used, total)
+	}
+	return item
+}
+
+func ipAddressItemFromRow(row store.IPAddressRow) ipAddressItem {
+	return ipAddressItem{
+		Id:             row.ID,
+		Address:        row.Address,
+		Vrf:            row.VRF,
+		DeviceId:       row.DeviceID,
+		DeviceHostname: row.DeviceHostname,
+		Status:         row.Status,
+		Description:    row.Description,
+		CreatedBy:      row.CreatedBy,
+		CreatedAt:      row.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// validatePrefixUpsert проверяет подсеть: биты хоста должны быть нулевыми,
+// чтобы опечатка вида 10.0.0.1/24 не превратилась молча в 10.0.0.0/24.
+func validatePrefixUpsert(req *prefixUpsertRequest) (netip.Prefix, string) {
+	p, err := netip.ParsePrefix(strings.TrimSpace(req.Prefix))
+	if err != nil || p.Addr().Zone() != "" {
+		return p, "invalid_prefix"
+	}
+	if p != p.Masked() {
+		return p, "prefix_host_bits_set"
+	}
+	req.Vrf = strings.TrimSpace(req.Vrf)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.VlanId != nil && (*req.VlanId < 1 || *req.VlanId > 4094) {
+		return p, "invalid_vlan_id"
+	}
+	return p, ""
+}
+
+// normalizeIPAddressStatus подставляет active для пустого статуса.
+func normalizeIPAddressStatus(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return ipAddressStatusActive, true
+	}
+	return s, ipAddressStatuses[s]
+}
+
+// checkIPAddressDevice проверяет, что устройство существует и не в корзине; в
+// транзакции его строка остаётся заблокированной до блокировки VRF.
+func checkIPAddressDevice(ctx context.Context, q *store.Queries, deviceID *int64) (string, error) {
+	if deviceID == nil {
+		return "", nil
+	}
+	exists, err := q.LockIPAddressDevice(ctx, *deviceID)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "device_not_found", nil
+	}
+	return "", nil
+}
+
+func (a *app) handlePrefixesList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f store.PrefixFilter
+	var err error
+
+	// Пустой vrf= — глобальный VRF, отсутствие параметра — все VRF.
+	if q.Has("vrf") {
+		vrf := strings.TrimSpace(q.Get("vrf"))
+		f.VRF = &vrf
+	}
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_location_id"})
+		return
+	}
+	if v := strings.TrimSpace(q.Get("vlan_id")); v != "" {
+		vlan, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || vlan < 1 || vlan > 4094 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_vlan_id"})
+			return
+		}
+		vlanID := int32(vlan)
+		f.VlanID = &vlanID
+	}
+	if v := strings.TrimSpace(q.Get("contains")); v != "" {
+		addr, ok := normalizeIPAddress(v)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_ip"})
+			return
+		}
+		f.Contains = &addr
+	}
+
+	rows, err := a.st.ListPrefixes(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]prefixItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, prefixItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handlePrefixesGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	row, err := a.st.GetPrefix(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prefixItemFromRow(row))
+}
+
+func (a *app) handlePrefixesCreate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	var req prefixUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	prefix, errCode := validatePrefixUpsert(&req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	id, err := a.st.CreatePrefix(r.Context(), prefix.String(), req.Vrf, req.VlanId, req.LocationId, nullIfEmpty(req.Description))
+	if err != nil {
+		writePrefixWriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (a *app) handlePrefixesUpdate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req prefixUpsertRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	prefix, errCode := validatePrefixUpsert(&req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	affected, err := a.st.UpdatePrefix(r.Context(), id, prefix.String(), req.Vrf, req.VlanId, req.LocationId, nullIfEmpty(req.Description))
+	if err != nil {
+		writePrefixWriteError(w, err)
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: id})
+}
+
+// writePrefixWriteError переводит ошибки записи префикса в ответы API.
+func writePrefixWriteError(w http.ResponseWriter, err error) {
+	if isUniqueViolation(err, prefixesUniqueConstraint) {
+		writeJSON(w, http.StatusConflict, apiError{Error: "prefix_taken"})
+		return
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "location_not_found"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+}
+
+// handlePrefixesDelete удаляет префикс. Выделенные в нём адреса остаются:
+// они принадлежат VRF, а не префиксу.
+func (a *app) handlePrefixesDelete(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	affected, err := a.st.DeletePrefix(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handlePrefixesAllocate выделяет наименьший свободный адрес префикса.
+// Выделения в одном VRF сериализуются advisory-блокировкой, поэтому
+// параллельные запросы получают разные адреса.
+func (a *app) handlePrefixesAllocate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req prefixAllocateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	status, ok := normalizeIPAddressStatus(req.Status)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_status"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	row, err := qtx.GetPrefix(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	prefix, err := netip.ParsePrefix(row.Prefix)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if errCode, err := checkIPAddressDevice(ctx, qtx, req.DeviceId); err != nil || errCode != "" {
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	if err := qtx.LockIPAMVRF(ctx, row.VRF); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	first, last, _ := prefixRange(prefix)
+	free, err := qtx.NextFreeIPAddress(ctx, row.Prefix, row.VRF, first.String(), last.String())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusConflict, apiError{Error: "prefix_full"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	address := free + "/" + strconv.Itoa(prefix.Bits())
+	ipID, err := qtx.CreateIPAddress(ctx, address, row.VRF, req.DeviceId, status, nullIfEmpty(strings.TrimSpace(req.Description)), authUsername(ctx))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	created, err := qtx.GetIPAddress(ctx, ipID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, ipAddressItemFromRow(created))
+}
+
+func (a *app) handleIPAddressesList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f store.IPAddressFilter
+	var err error
+
+	if q.Has("vrf") {
+		vrf := strings.TrimSpace(q.Get("vrf"))
+		f.VRF = &vrf
+	}
+	if f.DeviceID, err = parseOptionalID(q.Get("device_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_device_id"})
+		return
+	}
+	if v := strings.TrimSpace(q.Get("status")); v != "" {
+		status := strings.ToLower(v)
+		if !ipAddressStatuses[status] {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_status"})
+			return
+		}
+		f.Status = &status
+	}
+
+	// prefix_id ограничивает выборку подсетью префикса в его VRF.
+	prefixID, err := parseOptionalID(q.Get("prefix_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_prefix_id"})
+		return
+	}
+	if prefixID != nil {
+		prefix, err := a.st.GetPrefix(r.Context(), *prefixID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: "prefix_not_found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if f.VRF != nil && *f.VRF != prefix.VRF {
+			writeJSON(w, http.StatusOK, []ipAddressItem{})
+			return
+		}
+		f.VRF = &prefix.VRF
+		f.Within = &prefix.Prefix
+	}
+
+	rows, err := a.st.ListIPAddresses(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]ipAddressItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, ipAddressItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleIPAddressesGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	row, err := a.st.GetIPAddress(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ipAddressItemFromRow(row))
+}
+
+// handleIPAddressesCreate регистрирует конкретный адрес. Если маска не указана,
+// берётся маска самого узкого префикса VRF, в который адрес входит.
+func (a *app) handleIPAddressesCreate(w http.ResponseWriter, r *http.Request) {
+	var req ipAddressCreateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+
+	address, ok := normalizeIPAddress(req.Address)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_ip_address"})
+		return
+	}
+	status, ok := normalizeIPAddressStatus(req.Status)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_status"})
+		return
+	}
+	vrf := strings.TrimSpace(req.Vrf)
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	if errCode, err := checkIPAddressDevice(ctx, qtx, req.DeviceId); err != nil || errCode != "" {
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	if err := qtx.LockIPAMVRF(ctx, vrf); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if !strings.Contains(address, "/") {
+		bits, err := qtx.FindPrefixMaskLen(ctx, address, vrf)
+		switch {
+		case err == nil:
+			address += "/" + strconv.Itoa(bits)
+		case !errors.Is(err, pgx.ErrNoRows):
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+
+	id, err := qtx.CreateIPAddress(ctx, address, vrf, req.DeviceId, status, nullIfEmpty(strings.TrimSpace(req.Description)), authUsername(ctx))
+	if err != nil {
+		if isUniqueViolation(err, ipAddressesHostUniqueConstraint) {
+			writeJSON(w, http.StatusConflict, apiError{Error: "address_taken"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (a *app) handleIPAddressesUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req ipAddressUpdateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	status, ok := normalizeIPAddressStatus(req.Status)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_status"})
+		return
+	}
+
+	ctx := r.Context()
+	if errCode, err := checkIPAddressDevice(ctx, a.st, req.DeviceId); err != nil || errCode != "" {
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	affected, err := a.st.UpdateIPAddress(ctx, id, req.DeviceId, status, nullIfEmpty(strings.TrimSpace(req.Description)))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: id})
+}
+
+func (a *app) handleIPAddressesDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	affected, err := a.st.DeleteIPAddress(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"telecombase/server/internal/barcode"
+	"telecombase/server/internal/label"
+	"telecombase/server/internal/store"
+)
+
+const (
+	// Наклейка по умолчанию — 58×40 мм, ходовой размер термоэтикеток.
+	labelDefaultWidthMM  = 58
+	labelDefaultHeightMM = 40
+	labelMaxSizeMM       = 200
+	labelDefaultDPI      = 300
+	labelMinDPI          = 96
+	labelMaxDPI          = 1200
+	// labelMaxPixels ограничивает растр PNG целиком: предельные размер и dpi
+	// по отдельности дали бы картинку почти в 90 Мпикс (200×200 мм, 1200 dpi).
+	labelMaxPixels = 8_000_000
+
+	labelSheetDefaultLayout = "a4-3x8"
+	labelSheetMaxDevices    = 1000
+	labelSheetMaxCopies     = 100
+	labelSheetMaxLabels     = 5000
+)
+
+// labelLayout — раскладка листа наклеек; размеры в миллиметрах.
+type labelLayout struct {
+	Name          string  `json:"name,omitempty"`
+	Columns       int     `json:"columns"`
+	Rows          int     `json:"rows"`
+	LabelWidthMm  float64 `json:"labelWidthMm"`
+	LabelHeightMm float64 `json:"labelHeightMm"`
+	MarginTopMm   float64 `json:"marginTopMm"`
+	MarginLeftMm  float64 `json:"marginLeftMm"`
+	GapXMm        float64 `json:"gapXMm"`
+	GapYMm        float64 `json:"gapYMm"`
+}
+
+func toLabelLayout(s label.SheetLayout) labelLayout {
+	return labelLayout{
+		Name:          s.Name,
+		Columns:       s.Columns,
+		Rows:          s.Rows,
+		LabelWidthMm:  s.LabelWidth,
+		LabelHeightMm: s.LabelHeight,
+		MarginTopMm:   s.MarginTop,
+		MarginLeftMm:  s.MarginLeft,
+		GapXMm:        s.GapX,
+		GapYMm:        s.GapY,
+	}
+}
+
+func (l labelLayout) sheetLayout() label.SheetLayout {
+	return label.SheetLayout{
+		Name:        l.Name,
+		Columns:     l.Columns,
+		Rows:        l.Rows,
+		LabelWidth:  l.LabelWidthMm,
+		LabelHeight: l.LabelHeightMm,
+		MarginTop:   l.MarginTopMm,
+		MarginLeft:  l.MarginLeftMm,
+		GapX:        l.GapXMm,
+		GapY:        l.GapYMm,
+	}
+}
+
+// labelSheetRequest печатает наклейки устройств deviceIds в указанном порядке
+// по copies штук. Раскладка — готовая layout или своя customLayout; skip —
+// сколько мест на первом листе уже занято.
+type labelSheetRequest struct {
+	DeviceIds    []int64      `json:"deviceIds"`
+	Barcode      string       `json:"barcode"`
+	Layout       string       `json:"layout"`
+	CustomLayout *labelLayout `json:"customLayout"`
+	Skip         int          `json:"skip"`
+	Copies       int          `json:"copies"`
+	Outline      bool         `json:"outline"`
+}
+
+// deviceLabelError — ошибка печати; DeviceId — устройство, наклейку которого
+// нельзя построить.
+type deviceLabelError struct {
+	Error    string `json:"error"`
+	DeviceId int64  `json:"deviceId"`
+}
+
+func parseLabelSymbology(v string) (label.Symbology, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", string(label.Code128):
+		return label.Code128, true
+	case string(label.QR):
+		return label.QR, true
+	}
+	return "", false
+}
+
+// deviceLabelContent собирает наклейку: код — инвентарный номер, заголовок —
+// вендор и модель, ниже серийный номер.
+func deviceLabelContent(row store.DeviceLabelRow, sym label.Symbology) label.Label {
+	l := label.Label{
+		Symbology: sym,
+		Code:      strings.TrimSpace(row.InventoryNumber),
+		Title:     strings.TrimSpace(row.VendorName + " " + row.ModelName),
+	}
+	if s := strings.TrimSpace(row.SerialNumber); s != "" {
+		l.Lines = []string{"S/N " + s}
+	}
+	return l
+}
+
+// labelErrorCode переводит ошибку построения наклейки в код ответа; пустая
+// строка — внутренняя ошибка.
+func labelErrorCode(err error) string {
+	switch {
+	case errors.Is(err, barcode.ErrUnsupported):
+		return "barcode_unsupported_characters"
+	case errors.Is(err, barcode.ErrTooLong):
+		return "barcode_too_long"
+	case errors.Is(err, label.ErrTooSmall):
+		return "label_too_small"
+	}
+	return ""
+}
+
+func parseLabelSize(v string, def, minValue float64) (float64, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return def, true
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < minValue || f > labelMaxSizeMM {
+		return 0, false
+	}
+	return f, true
+}
+
+// handleDeviceLabel рисует наклейку устройства: format=png (по умолчанию) или
+// svg, barcode=code128 (по умолчанию) или qr, размер width_mm × height_mm и
+// для PNG разрешение dpi; растр PNG ограничен labelMaxPixels.
+func (a *app) handleDeviceLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	q := r.URL.Query()
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_format"})
+		return
+	}
+	sym, ok := parseLabelSymbology(q.Get("barcode"))
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_barcode"})
+		return
+	}
+	width, ok := parseLabelSize(q.Get("width_mm"), labelDefaultWidthMM, label.MinWidth)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_width_mm"})
+		return
+	}
+	height, ok := parseLabelSize(q.Get("height_mm"), labelDefaultHeightMM, label.MinHeight)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_height_mm"})
+		return
+	}
+	dpi := labelDefaultDPI
+	if v := strings.TrimSpace(q.Get("dpi")); v != "" {
+		dpi, err = strconv.Atoi(v)
+		if err != nil || dpi < labelMinDPI || dpi > labelMaxDPI {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_dpi"})
+			return
+		}
+	}
+	if format != "svg" {
+		pxW := math.Round(width / 25.4 * float64(dpi))
+		pxH := math.Round(height / 25.4 * float64(dpi))
+		if pxW*pxH > labelMaxPixels {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "label_too_large"})
+			return
+		}
+	}
+
+	rows, err := a.st.ListDeviceLabels(r.Context(), []int64{id})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if len(rows) == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+		return
+	}
+	content := deviceLabelContent(rows[0], sym)
+	if content.Code == "" {
+		writeJSON(w, http.StatusConflict, apiError{Error: "inventory_number_missing"})
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+		err = content.SVG(&buf, width, height)
+	} else {
+		err = content.PNG(&buf, width, height, dpi)
+	}
+	if err != nil {
+		if code := labelErrorCode(err); code != "" {
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: code})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "render_error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="label-%d.%s"`, id, format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
+
+func (a *app) handleLabelLayoutsList(w http.ResponseWriter, r *http.Request) {
+	items := make([]labelLayout, 0, len(label.Layouts))
+	for _, l := range label.Layouts {
+		items = append(items, toLabelLayout(l))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleLabelsSheet печатает наклейки выбранных устройств на листах A4 в PDF.
+func (a *app) handleLabelsSheet(w http.ResponseWriter, r *http.Request) {
+	var req labelSheetRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	if len(req.DeviceIds) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "device_ids_required"})
+		return
+	}
+	if len(req.DeviceIds) > labelSheetMaxDevices {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "too_many_devices"})
+		return
+	}
+	for _, id := range req.DeviceIds {
+		if id <= 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_device_id"})
+			return
+		}
+	}
+	sym, ok := parseLabelSymbology(req.Barcode)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_barcode"})
+		return
+	}
+	if req.Copies == 0 {
+		req.Copies = 1
+	}
+	if req.Copies < 1 || req.Copies > labelSheetMaxCopies {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_copies"})
+		return
+	}
+	if len(req.DeviceIds)*req.Copies > labelSheetMaxLabels {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "too_many_labels"})
+		return
+	}
+
+	var layout label.SheetLayout
+	if req.CustomLayout != nil {
+		if req.Layout != "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "layout_conflict"})
+			return
+		}
+		layout = req.CustomLayout.sheetLayout()
+		layout.Name = ""
+		if layout.Validate() != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_layout"})
+			return
+		}
+	} else {
+		name := strings.ToLower(strings.TrimSpace(req.Layout))
+		if name == "" {
+			name = labelSheetDefaultLayout
+		}
+		if layout, ok = label.LookupLayout(name); !ok {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_layout"})
+			return
+		}
+	}
+	if req.Skip < 0 || req.Skip >= layout.PerPage() {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_skip"})
+		return
+	}
+
+	rows, err := a.st.ListDeviceLabels(r.Context(), req.DeviceIds)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	byID := make(map[int64]store.DeviceLabelRow, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+
+	labels := make([]label.Label, 0, len(req.DeviceIds)*req.Copies)
+	labelDevices := make([]int64, 0, cap(labels))
+	for _, id := range req.DeviceIds {
+		row, found := byID[id]
+		if !found {
+			writeJSON(w, http.StatusBadRequest, deviceLabelError{Error: "device_not_found", DeviceId: id})
+			return
+		}
+		content := deviceLabelContent(row, sym)
+		if content.Code == "" {
+			writeJSON(w, http.StatusConflict, deviceLabelError{Error: "inventory_number_missing", DeviceId: id})
+			return
+		}
+		for i := 0; i < req.Copies; i++ {
+			labels = append(labels, content)
+			labelDevices = append(labelDevices, id)
+		}
+	}
+
+	doc, err := label.Sheet("Наклейки устройств", labels, layout, req.Skip, req.Outline)
+	if err != nil {
+		var sheetErr *label.SheetError
+		if code := labelErrorCode(err); code != "" && errors.As(err, &sheetErr) {
+			writeJSON(w, http.StatusUnprocessableEntity, deviceLabelError{Error: code, DeviceId: labelDevices[sheetErr.Index]})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "render_error"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "render_error"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="labels.pdf"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
@@ -1,13 +1,93 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	locationTypeSite     = "site"
+	locationTypeBuilding = "building"
+	locationTypeFloor    = "floor"
+	locationTypeRoom     = "room"
+	locationTypeRack     = "rack"
 )
 
+// locationTypeOrder — типы локаций от внешнего к внутреннему. Вложенная
+// локация должна быть строго «глубже» родителя.
+var locationTypeOrder = []string{
+	locationTypeSite,
+	locationTypeBuilding,
+	locationTypeFloor,
+	locationTypeRoom,
+	locationTypeRack,
+}
+
+func locationTypeRank(t string) int {
+	for i, it := range locationTypeOrder {
+		if it == t {
+			return i
+		}
+	}
+	return -1
+}
+
+const locationAddressMaxLen = 500
+
+// optional различает отсутствующее поле, null и значение: при обновлении
+// отсутствие parentId оставляет родителя как есть, а null переносит в корень.
+type optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+type optionalID = optional[int64]
+
+func (o *optional[T]) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if string(b) == "null" {
+		o.Value = nil
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
+
 type locationListItem struct {
-	Id   int64  `json:"id"`
-	Name string `json:"name"`
-	Note string `json:"note"`
+	Id           int64          `json:"id"`
+	Name         string         `json:"name"`
+	Note         string         `json:"note"`
+	ParentId     *int64         `json:"parentId"`
+	Type         string         `json:"type"`
+	Path         string         `json:"path"`
+	RackHeightU  *int32         `json:"rackHeightU"`
+	Latitude     *float64       `json:"latitude"`
+	Longitude    *float64       `json:"longitude"`
+	Address      string         `json:"address"`
+	CustomFields map[string]any `json:"customFields"`
+}
+
+type locationTreeNode struct {
+	Id          int64               `json:"id"`
+	ParentId    *int64              `json:"parentId"`
+	Name        string              `json:"name"`
+	Type        string              `json:"type"`
+	Note        string              `json:"note"`
+	RackHeightU *int32              `json:"rackHeightU"`
+	DeviceCount int64               `json:"deviceCount"`
+	Children    []*locationTreeNode `json:"children"`
 }
 
 func (a *app) handleLocationsList(w http.ResponseWriter, r *http.Request) {
@@ -19,8 +99,141 @@ func (a *app) handleLocationsList(w http.ResponseWriter, r *http.Request) {
 
 	items := make([]locationListItem, 0, len(rows))
 	for _, row := range rows {
-		items = append(items, locationListItem{Id: row.ID, Name: row.Name, Note: row.Note})
+		items = append(items, locationListItem{
+			Id:           row.ID,
+			Name:         row.Name,
+			Note:         row.Note,
+			ParentId:     row.ParentID,
+			Type:         row.Type,
+			Path:         row.Path,
+			RackHeightU:  row.RackHeightU,
+			Latitude:     row.Geo.Latitude,
+			Longitude:    row.Geo.Longitude,
+			Address:      row.Geo.Address,
+			CustomFields: customFieldsResponse(row.CustomFields),
+		})
 	}
 
 	writeJSON(w, http.StatusOK, items)
 }
+
+// handleLocationsTree отдаёт все локации вложенными узлами, корни — площадки
+// без родителя.
+func (a *app) handleLocationsTree(w http.ResponseWriter, r *http.Request) {
+	rows, err := a.st.ListLocationTree(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	// Родитель в выборке всегда раньше детей, поэтому узел уже есть в nodes.
+	roots := make([]*locationTreeNode, 0)
+	nodes := make(map[int64]*locationTreeNode, len(rows))
+	for _, row := range rows {
+		node := &locationTreeNode{
+			Id:          row.ID,
+			ParentId:    row.ParentID,
+			Name:        row.Name,
+			Type:        row.Type,
+			Note:        row.Note,
+			RackHeightU: row.RackHeightU,
+			DeviceCount: row.DeviceCount,
+			Children:    []*locationTreeNode{},
+		}
+		nodes[row.ID] = node
+		if row.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*row.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, roots)
+}
+
+// resolveLocationPlacement проверяет родителя и тип локации id (0 — новая).
+// Пустой тип при создании выводится из родителя: следующий уровень после
+// него, а без родителя — площадка. Вызывается под LockLocationTree.
+func resolveLocationPlacement(ctx context.Context, q *store.Queries, id int64, parentID *int64, locType string) (string, string, error) {
+	locType = strings.ToLower(strings.TrimSpace(locType))
+	if locType != "" && locationTypeRank(locType) < 0 {
+		return "", "invalid_type", nil
+	}
+
+	parentRank := -1
+	if parentID != nil {
+		parent, err := q.GetLocationNode(ctx, *parentID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", "parent_not_found", nil
+			}
+			return "", "", err
+		}
+		if id != 0 {
+			inSubtree, err := q.LocationInSubtree(ctx, id, *parentID)
+			if err != nil {
+				return "", "", err
+			}
+			if inSubtree {
+				return "", "location_cycle", nil
+			}
+		}
+		parentRank = locationTypeRank(parent.Type)
+	}
+
+	if locType == "" {
+		next := parentRank + 1
+		if next >= len(locationTypeOrder) {
+			return "", "invalid_parent_type", nil
+		}
+		locType = locationTypeOrder[next]
+	}
+	rank := locationTypeRank(locType)
+	if rank <= parentRank {
+		return "", "invalid_parent_type", nil
+	}
+
+	if id != 0 {
+		childTypes, err := q.ListChildLocationTypes(ctx, id)
+		if err != nil {
+			return "", "", err
+		}
+		for _, t := range childTypes {
+			if locationTypeRank(t) <= rank {
+				return "", "children_type_conflict", nil
+			}
+		}
+	}
+
+	return locType, "", nil
+}
+
+// resolveLocationGeo накладывает координаты и адрес из запроса на текущие
+// (при создании — пустые): не переданные поля остаются как есть.
+func resolveLocationGeo(req locationUpsertRequest, geo store.LocationGeo) (store.LocationGeo, string) {
+	if req.Latitude.Set {
+		geo.Latitude = req.Latitude.Value
+	}
+	if req.Longitude.Set {
+		geo.Longitude = req.Longitude.Value
+	}
+	if req.Address != nil {
+		geo.Address = strings.TrimSpace(*req.Address)
+	}
+
+	if (geo.Latitude == nil) != (geo.Longitude == nil) {
+		return geo, "coordinates_incomplete"
+	}
+	if geo.Latitude != nil && !(*geo.Latitude >= -90 && *geo.Latitude <= 90) {
+		return geo, "invalid_latitude"
+	}
+	if geo.Longitude != nil && !(*geo.Longitude >= -180 && *geo.Longitude <= 180) {
+		return geo, "invalid_longitude"
+	}
+	if utf8.RuneCountInString(geo.Address) > locationAddressMaxLen {
+		return geo, "address_too_long"
+	}
+	return geo, ""
+}
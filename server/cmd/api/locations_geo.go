@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"telecombase/server/internal/store"
+)
+
+type geoJSONPoint struct {
+	Type string `json:"type"`
+	// Coordinates — [долгота, широта], как требует RFC 7946.
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type locationFeatureProperties struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	ParentId *int64 `json:"parentId"`
+	Address  string `json:"address"`
+	// DeviceCount и StatusCounts учитывают устройства вложенных локаций.
+	DeviceCount  int64            `json:"deviceCount"`
+	StatusCounts map[string]int64 `json:"statusCounts"`
+}
+
+type locationFeature struct {
+	Type       string                    `json:"type"`
+	Id         int64                     `json:"id"`
+	Geometry   geoJSONPoint              `json:"geometry"`
+	Properties locationFeatureProperties `json:"properties"`
+}
+
+type locationFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []locationFeature `json:"features"`
+}
+
+// parseLocationBBox разбирает bbox=minLon,minLat,maxLon,maxLat (порядок
+// RFC 7946). minLon > maxLon допустим — окно пересекает 180-й меридиан.
+func parseLocationBBox(v string) (*store.LocationBBox, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, true
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != 4 {
+		return nil, false
+	}
+	var vals [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, false
+		}
+		vals[i] = f
+	}
+	bbox := store.LocationBBox{MinLon: vals[0], MinLat: vals[1], MaxLon: vals[2], MaxLat: vals[3]}
+	for _, lon := range []float64{bbox.MinLon, bbox.MaxLon} {
+		if !(lon >= -180 && lon <= 180) {
+			return nil, false
+		}
+	}
+	for _, lat := range []float64{bbox.MinLat, bbox.MaxLat} {
+		if !(lat >= -90 && lat <= 90) {
+			return nil, false
+		}
+	}
+	if bbox.MinLat > bbox.MaxLat {
+		return nil, false
+	}
+	return &bbox, true
+}
+
+// handleLocationsGeoJSON отдаёт локации с координатами как GeoJSON
+// FeatureCollection: точка на площадку и счётчики устройств по статусам.
+// Фильтры: bbox — окно карты, type — тип локации (например, только site).
+func (a *app) handleLocationsGeoJSON(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	bbox, ok := parseLocationBBox(q.Get("bbox"))
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_bbox"})
+		return
+	}
+	locType := strings.ToLower(strings.TrimSpace(q.Get("type")))
+	if locType != "" && locationTypeRank(locType) < 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_type"})
+		return
+	}
+
+	rows, err := a.st.ListLocationFeatures(r.Context(), bbox)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := locationFeatureCollection{Type: "FeatureCollection", Features: make([]locationFeature, 0, len(rows))}
+	for _, row := range rows {
+		if locType != "" && row.Type != locType {
+			continue
+		}
+		// Все статусы присутствуют всегда, чтобы клиенту не гадать о нулях.
+		counts := make(map[string]int64, len(deviceStatusTransitions))
+		for status := range deviceStatusTransitions {
+			counts[status] = 0
+		}
+		var total int64
+		for status, n := range row.StatusCounts {
+			counts[status] = n
+			total += n
+		}
+		resp.Features = append(resp.Features, locationFeature{
+			Type: "Feature",
+			Id:   row.ID,
+			Geometry: geoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{row.Longitude, row.Latitude},
+			},
+			Properties: locationFeatureProperties{
+				Name:         row.Name,
+				Type:         row.Type,
+				ParentId:     row.ParentID,
+				Address:      row.Address,
+				DeviceCount:  total,
+				StatusCounts: counts,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
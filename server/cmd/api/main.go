@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
@@ -13,11 +14,12 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 
+	"telecombase/server/internal/filestore"
 	"telecombase/server/internal/store"
 )
 
@@ -25,12 +27,25 @@ type appConfig struct {
 	apiPort     string
 	databaseURL string
 	jwtSecret   string
+	// deviceTrashRetention — сколько устройство хранится в корзине до
+	// автоматической очистки; 0 — не очищать автоматически.
+	deviceTrashRetention time.Duration
+	// inventoryUnique включает уникальность инвентарных номеров (без учёта
+	// регистра и крайних пробелов).
+	inventoryUnique bool
+	// attachmentsDir — каталог локального хранилища вложений устройств;
+	// attachmentsMaxBytes — предельный размер файла; attachmentsAllowedTypes —
+	// MIME-типы, определённые по содержимому, которые можно загружать.
+	attachmentsDir          string
+	attachmentsMaxBytes     int64
+	attachmentsAllowedTypes map[string]bool
 }
 
 type app struct {
-	cfg appConfig
-	db  *pgxpool.Pool
-	st  *store.Queries
+	cfg   appConfig
+	db    *pgxpool.Pool
+	st    *store.Queries
+	files filestore.Storage
 }
 
 type healthResponse struct {
@@ -86,6 +101,11 @@ type deviceListItem struct {
 	InventoryNumber string `json:"inventoryNumber"`
 	Status          string `json:"status"`
 	InstalledAt     string `json:"installedAt"`
+	Hostname        string `json:"hostname"`
+	ManagementIp    string `json:"managementIp"`
+
+	Rank  float64            `json:"rank,omitempty"`
+	Match *deviceSearchMatch `json:"match,omitempty"`
 }
 
 type deviceUpsertRequest struct {
@@ -96,10 +116,22 @@ type deviceUpsertRequest struct {
 	Status          string `json:"status"`
 	InstalledAt     string `json:"installedAt"`
 	Description     string `json:"description"`
+	// CustomFields — значения пользовательских полей; null удаляет значение.
+	// При обновлении отсутствие ключа customFields оставляет значения как есть.
+	CustomFields map[string]json.RawMessage `json:"customFields"`
+	// Hostname, IpAddresses и MacAddresses при обновлении тоже не меняются,
+	// если ключ отсутствует; пустое значение очищает поле.
+	Hostname     *string           `json:"hostname"`
+	IpAddresses  []deviceIPAddress `json:"ipAddresses"`
+	MacAddresses []string          `json:"macAddresses"`
+	// RackPlacement при обновлении тоже не меняется, если ключ отсутствует, но
+	// при смене локации место в стойке освобождается.
+	RackPlacement *deviceRackPlacement `json:"rackPlacement"`
 }
 
 type deviceUpsertResponse struct {
-	Id int64 `json:"id"`
+	Id      int64 `json:"id"`
+	Version int64 `json:"version,omitempty"`
 }
 
 type idResponse struct {
@@ -107,36 +139,70 @@ type idResponse struct {
 }
 
 type vendorListItem struct {
-	Id      int64  `json:"id"`
-	Name    string `json:"name"`
-	Country string `json:"country"`
+	Id           int64          `json:"id"`
+	Name         string         `json:"name"`
+	Country      string         `json:"country"`
+	CustomFields map[string]any `json:"customFields"`
 }
 
 type vendorUpsertRequest struct {
-	Name    string `json:"name"`
-	Country string `json:"country"`
+	Name         string                     `json:"name"`
+	Country      string                     `json:"country"`
+	CustomFields map[string]json.RawMessage `json:"customFields"`
 }
 
 type modelUpsertRequest struct {
-	VendorId   int64  `json:"vendorId"`
-	Name       string `json:"name"`
-	DeviceType string `json:"deviceType"`
+	VendorId     int64                      `json:"vendorId"`
+	Name         string                     `json:"name"`
+	DeviceType   string                     `json:"deviceType"`
+	CustomFields map[string]json.RawMessage `json:"customFields"`
+	// HeightU и FullDepth не меняются при обновлении, если не переданы;
+	// у новой модели по умолчанию 1U и полная глубина.
+	HeightU   *int32 `json:"heightU"`
+	FullDepth *bool  `json:"fullDepth"`
+	// Даты жизненного цикла (YYYY-MM-DD) и модель на замену: при обновлении
+	// отсутствующие поля не меняются, null очищает.
+	EndOfSale          optional[string] `json:"endOfSale"`
+	EndOfSupport       optional[string] `json:"endOfSupport"`
+	EndOfLife          optional[string] `json:"endOfLife"`
+	ReplacementModelId optionalID       `json:"replacementModelId"`
 }
 
 type locationUpsertRequest struct {
-	Name string `json:"name"`
-	Note string `json:"note"`
+	Name         string                     `json:"name"`
+	Note         string                     `json:"note"`
+	ParentId     optionalID                 `json:"parentId"`
+	Type         string                     `json:"type"`
+	CustomFields map[string]json.RawMessage `json:"customFields"`
+	// RackHeightU — высота стойки, только для типа rack; при обновлении
+	// отсутствие поля оставляет высоту как есть.
+	RackHeightU *int32 `json:"rackHeightU"`
+	// Latitude, Longitude и Address при обновлении тоже не меняются, если
+	// не переданы; null в координатах и пустой адрес очищают их.
+	Latitude  optional[float64] `json:"latitude"`
+	Longitude optional[float64] `json:"longitude"`
+	Address   *string           `json:"address"`
 }
 
 type deviceDetailsResponse struct {
-	Id              int64  `json:"id"`
-	ModelId         int64  `json:"modelId"`
-	LocationId      *int64 `json:"locationId"`
-	SerialNumber    string `json:"serialNumber"`
-	InventoryNumber string `json:"inventoryNumber"`
-	Status          string `json:"status"`
-	InstalledAt     string `json:"installedAt"`
-	Description     string `json:"description"`
+	Id              int64                  `json:"id"`
+	ModelId         int64                  `json:"modelId"`
+	LocationId      *int64                 `json:"locationId"`
+	SerialNumber    string                 `json:"serialNumber"`
+	InventoryNumber string                 `json:"inventoryNumber"`
+	Status          string                 `json:"status"`
+	InstalledAt     string                 `json:"installedAt"`
+	Description     string                 `json:"description"`
+	Version         int64                  `json:"version"`
+	UpdatedAt       string                 `json:"updatedAt"`
+	CustomFields    map[string]any         `json:"customFields"`
+	Hostname        string                 `json:"hostname"`
+	ManagementIp    string                 `json:"managementIp"`
+	IpAddresses     []deviceIPAddress      `json:"ipAddresses"`
+	MacAddresses    []string               `json:"macAddresses"`
+	RackPositionU   *int32                 `json:"rackPositionU"`
+	RackFace        string                 `json:"rackFace"`
+	Coverage        deviceCoverageResponse `json:"coverage"`
 }
 
 func main() {
@@ -151,6 +217,22 @@ func main() {
 	if cfg.jwtSecret == "" {
 		log.Fatal("JWT_SECRET is required")
 	}
+	retentionDays, err := strconv.Atoi(getEnv("DEVICE_TRASH_RETENTION_DAYS", "30"))
+	if err != nil || retentionDays < 0 {
+		log.Fatal("DEVICE_TRASH_RETENTION_DAYS must be a non-negative integer")
+	}
+	cfg.deviceTrashRetention = time.Duration(retentionDays) * 24 * time.Hour
+	if v := strings.ToLower(strings.TrimSpace(getEnv("INVENTORY_NUMBER_UNIQUE", ""))); v == "1" || v == "true" || v == "yes" {
+		cfg.inventoryUnique = true
+	}
+	cfg.attachmentsDir = getEnv("ATTACHMENTS_DIR", "data/attachments")
+	attachmentsMaxMB, err := strconv.Atoi(getEnv("ATTACHMENTS_MAX_MB", "20"))
+	if err != nil || attachmentsMaxMB <= 0 {
+		log.Fatal("ATTACHMENTS_MAX_MB must be a positive integer")
+	}
+	cfg.attachmentsMaxBytes = int64(attachmentsMaxMB) << 20
+	cfg.attachmentsAllowedTypes = parseAttachmentTypes(getEnv("ATTACHMENTS_ALLOWED_TYPES",
+		"image/jpeg,image/png,image/gif,image/webp,application/pdf,text/plain"))
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -161,10 +243,16 @@ func main() {
 	}
 	defer db.Close()
 
-	application := &app{cfg: cfg, db: db, st: store.New(db)}
+	files, err := filestore.NewLocal(cfg.attachmentsDir)
+	if err != nil {
+		log.Fatalf("attachments storage: %v", err)
+	}
+
+	application := &app{cfg: cfg, db: db, st: store.New(db), files: files}
 	if v := strings.ToLower(strings.TrimSpace(getEnv("SEED_DEMO", ""))); v == "1" || v == "true" || v == "yes" {
 		application.seedIfEmpty(ctx)
 	}
+	go application.runDeviceTrashPurger(ctx)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /health", application.handleHealth)
@@ -182,15 +270,89 @@ func main() {
 	mux.HandleFunc("DELETE /models/{id}", application.requireAuth(application.handleModelsDelete))
 
 	mux.HandleFunc("GET /locations", application.requireAuth(application.handleLocationsList))
+	mux.HandleFunc("GET /locations.geojson", application.requireAuth(application.handleLocationsGeoJSON))
+	mux.HandleFunc("GET /locations/tree", application.requireAuth(application.handleLocationsTree))
 	mux.HandleFunc("POST /locations", application.requireAuth(application.handleLocationsCreate))
 	mux.HandleFunc("PUT /locations/{id}", application.requireAuth(application.handleLocationsUpdate))
 	mux.HandleFunc("DELETE /locations/{id}", application.requireAuth(application.handleLocationsDelete))
+	mux.HandleFunc("GET /locations/{id}/elevation", application.requireAuth(application.handleRackElevation))
 
 	mux.HandleFunc("GET /devices", application.requireAuth(application.handleDevicesList))
+	mux.HandleFunc("GET /devices/export", application.requireAuth(application.handleDevicesExport))
+	mux.HandleFunc("GET /devices/lookup", application.requireAuth(application.handleDevicesLookup))
+	mux.HandleFunc("GET /devices/trash", application.requireAuth(application.handleDevicesTrashList))
+	mux.HandleFunc("DELETE /devices/trash", application.requireAuth(application.handleDevicesTrashPurge))
+	mux.HandleFunc("DELETE /devices/trash/{id}", application.requireAuth(application.handleDevicesPurge))
 	mux.HandleFunc("GET /devices/{id}", application.requireAuth(application.handleDevicesGet))
 	mux.HandleFunc("POST /devices", application.requireAuth(application.handleDevicesCreate))
+	mux.HandleFunc("POST /devices/import", application.requireAuth(application.handleDevicesImport))
+	mux.HandleFunc("PATCH /devices/bulk", application.requireAuth(application.handleDevicesBulkUpdate))
+	mux.HandleFunc("GET /devices/transfers", application.requireAuth(application.handleDeviceTransfersList))
+	mux.HandleFunc("POST /devices/transfers", application.requireAuth(application.handleDeviceTransfersCreate))
+	// Документ читается по /transfers/{id}: /devices/transfers/{id} пересекался
+	// бы с маршрутами /devices/{id}/...
+	mux.HandleFunc("GET /transfers/{id}", application.requireAuth(application.handleTransfersGet))
+	mux.HandleFunc("GET /transfers/{id}/act", application.requireAuth(application.handleTransfersAct))
 	mux.HandleFunc("PUT /devices/{id}", application.requireAuth(application.handleDevicesUpdate))
 	mux.HandleFunc("DELETE /devices/{id}", application.requireAuth(application.handleDevicesDelete))
+	mux.HandleFunc("POST /devices/{id}/restore", application.requireAuth(application.handleDevicesRestore))
+	mux.HandleFunc("GET /devices/{id}/transitions", application.requireAuth(application.handleDeviceTransitionsList))
+	mux.HandleFunc("POST /devices/{id}/transitions", application.requireAuth(application.handleDeviceTransitionsCreate))
+	mux.HandleFunc("GET /devices/{id}/history", application.requireAuth(application.handleDeviceHistoryList))
+	mux.HandleFunc("GET /devices/{id}/attachments", application.requireAuth(application.handleDeviceAttachmentsList))
+	mux.HandleFunc("POST /devices/{id}/attachments", application.requireAuth(application.handleDeviceAttachmentsCreate))
+	mux.HandleFunc("GET /devices/{id}/attachments/{attachmentId}", application.requireAuth(application.handleDeviceAttachmentsDownload))
+	mux.HandleFunc("DELETE /devices/{id}/attachments/{attachmentId}", application.requireAuth(application.handleDeviceAttachmentsDelete))
+	mux.HandleFunc("GET /devices/{id}/label", application.requireAuth(application.handleDeviceLabel))
+	mux.HandleFunc("GET /labels/layouts", application.requireAuth(application.handleLabelLayoutsList))
+	mux.HandleFunc("POST /labels/sheet", application.requireAuth(application.handleLabelsSheet))
+
+	mux.HandleFunc("GET /devices/{id}/interfaces", application.requireAuth(application.handleDeviceInterfacesList))
+	mux.HandleFunc("POST /devices/{id}/interfaces", application.requireAuth(application.handleDeviceInterfacesCreate))
+	mux.HandleFunc("PUT /devices/{id}/interfaces/{interfaceId}", application.requireAuth(application.handleDeviceInterfacesUpdate))
+	mux.HandleFunc("DELETE /devices/{id}/interfaces/{interfaceId}", application.requireAuth(application.handleDeviceInterfacesDelete))
+	mux.HandleFunc("POST /links", application.requireAuth(application.handleLinksCreate))
+	mux.HandleFunc("DELETE /links/{id}", application.requireAuth(application.handleLinksDelete))
+	mux.HandleFunc("GET /topology", application.requireAuth(application.handleTopology))
+
+	mux.HandleFunc("GET /prefixes", application.requireAuth(application.handlePrefixesList))
+	mux.HandleFunc("POST /prefixes", application.requireAuth(application.handlePrefixesCreate))
+	mux.HandleFunc("GET /prefixes/{id}", application.requireAuth(application.handlePrefixesGet))
+	mux.HandleFunc("PUT /prefixes/{id}", application.requireAuth(application.handlePrefixesUpdate))
+	mux.HandleFunc("DELETE /prefixes/{id}", application.requireAuth(application.handlePrefixesDelete))
+	mux.HandleFunc("POST /prefixes/{id}/allocate", application.requireAuth(application.handlePrefixesAllocate))
+	mux.HandleFunc("GET /ip-addresses", application.requireAuth(application.handleIPAddressesList))
+	mux.HandleFunc("POST /ip-addresses", application.requireAuth(application.handleIPAddressesCreate))
+	mux.HandleFunc("GET /ip-addresses/{id}", application.requireAuth(application.handleIPAddressesGet))
+	mux.HandleFunc("PUT /ip-addresses/{id}", application.requireAuth(application.handleIPAddressesUpdate))
+	mux.HandleFunc("DELETE /ip-addresses/{id}", application.requireAuth(application.handleIPAddressesDelete))
+
+	mux.HandleFunc("GET /contracts", application.requireAuth(application.handleContractsList))
+	mux.HandleFunc("POST /contracts", application.requireAuth(application.handleContractsCreate))
+	mux.HandleFunc("GET /contracts/{id}", application.requireAuth(application.handleContractsGet))
+	mux.HandleFunc("PUT /contracts/{id}", application.requireAuth(application.handleContractsUpdate))
+	mux.HandleFunc("DELETE /contracts/{id}", application.requireAuth(application.handleContractsDelete))
+	mux.HandleFunc("GET /devices/{id}/contracts", application.requireAuth(application.handleDeviceContractsList))
+	mux.HandleFunc("GET /reports/warranty-expiring", application.requireAuth(application.handleReportsWarrantyExpiring))
+	mux.HandleFunc("GET /reports/eol", application.requireAuth(application.handleReportsEol))
+	mux.HandleFunc("GET /work-orders", application.requireAuth(application.handleWorkOrdersList))
+	mux.HandleFunc("POST /work-orders", application.requireAuth(application.handleWorkOrdersCreate))
+	mux.HandleFunc("GET /work-orders/{id}", application.requireAuth(application.handleWorkOrdersGet))
+	mux.HandleFunc("PUT /work-orders/{id}", application.requireAuth(application.handleWorkOrdersUpdate))
+	mux.HandleFunc("POST /work-orders/{id}/state", application.requireAuth(application.handleWorkOrdersState))
+	mux.HandleFunc("GET /devices/{id}/work-orders", application.requireAuth(application.handleDeviceWorkOrdersList))
+	mux.HandleFunc("GET /stock", application.requireAuth(application.handleStockList))
+	mux.HandleFunc("POST /stock", application.requireAuth(application.handleStockCreate))
+	mux.HandleFunc("PUT /stock/{id}", application.requireAuth(application.handleStockUpdate))
+	mux.HandleFunc("DELETE /stock/{id}", application.requireAuth(application.handleStockDelete))
+	mux.HandleFunc("GET /stock/movements", application.requireAuth(application.handleStockMovementsList))
+	mux.HandleFunc("POST /stock/movements", application.requireAuth(application.handleStockMovementsCreate))
+	mux.HandleFunc("GET /reports/low-stock", application.requireAuth(application.handleReportsLowStock))
+
+	mux.HandleFunc("GET /custom-fields", application.requireAuth(application.handleCustomFieldsList))
+	mux.HandleFunc("POST /custom-fields", application.requireAuth(application.handleCustomFieldsCreate))
+	mux.HandleFunc("PUT /custom-fields/{id}", application.requireAuth(application.handleCustomFieldsUpdate))
+	mux.HandleFunc("DELETE /custom-fields/{id}", application.requireAuth(application.handleCustomFieldsDelete))
 
 	mux.HandleFunc("GET /users/pending", application.requireAuth(application.handleUsersPendingList))
 	mux.HandleFunc("POST /users/{id}/approve", application.requireAuth(application.handleUsersApprove))
@@ -542,7 +704,7 @@ func (a *app) handleVendorsList(w http.ResponseWriter, r *http.Request) {
 
 	items := make([]vendorListItem, 0, len(rows))
 	for _, row := range rows {
-		items = append(items, vendorListItem{Id: row.ID, Name: row.Name, Country: row.Country})
+		items = append(items, vendorListItem{Id: row.ID, Name: row.Name, Country: row.Country, CustomFields: customFieldsResponse(row.CustomFields)})
 	}
 
 	writeJSON(w, http.StatusOK, items)
@@ -566,12 +728,33 @@ func (a *app) handleVendorsCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
 	var id int64
-	id, err := a.st.CreateVendor(r.Context(), name, nullIfEmpty(req.Country))
+	id, err = qtx.CreateVendor(ctx, name, nullIfEmpty(req.Country))
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
 	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeVendor, id, "", true, req.CustomFields); err != nil {
+		if writeCustomFieldError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
 	writeJSON(w, http.StatusCreated, idResponse{Id: id})
 }
@@ -599,7 +782,16 @@ func (a *app) handleVendorsUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	affected, err := a.st.UpdateVendor(r.Context(), id, name, nullIfEmpty(req.Country))
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	affected, err := qtx.UpdateVendor(ctx, id, name, nullIfEmpty(req.Country))
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
@@ -608,6 +800,18 @@ func (a *app) handleVendorsUpdate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
 		return
 	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeVendor, id, "", false, req.CustomFields); err != nil {
+		if writeCustomFieldError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
 	writeJSON(w, http.StatusOK, idResponse{Id: id})
 }
@@ -664,21 +868,48 @@ func (a *app) handleModelsCreate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, apiError{Error: "name_required"})
 		return
 	}
+	size := defaultModelRackSize
+	if errCode := applyModelRackSize(req, &size); errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	var lifecycle store.ModelLifecycle
+	if errCode := applyModelLifecycle(req, 0, &lifecycle); errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
 
 	var id int64
-	id, err := a.st.CreateModel(r.Context(), req.VendorId, name, nullIfEmpty(req.DeviceType))
+	id, err = qtx.CreateModel(ctx, req.VendorId, name, nullIfEmpty(req.DeviceType), size, lifecycle)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == "23503" {
-				writeJSON(w, http.StatusBadRequest, apiError{Error: "vendor_not_found"})
-				return
-			}
+		if writeModelFKError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeModel, id, req.DeviceType, true, req.CustomFields); err != nil {
+		if writeCustomFieldError(w, err) {
+			return
 		}
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
 	writeJSON(w, http.StatusCreated, idResponse{Id: id})
 }
 
@@ -709,14 +940,52 @@ func (a *app) handleModelsUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	affected, err := a.st.UpdateModel(r.Context(), id, req.VendorId, name, nullIfEmpty(req.DeviceType))
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == "23503" {
-				writeJSON(w, http.StatusBadRequest, apiError{Error: "vendor_not_found"})
-				return
-			}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	before, err := qtx.GetModelRackSize(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	size := before
+	if errCode := applyModelRackSize(req, &size); errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	lifecycle, err := qtx.GetModelLifecycle(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if errCode := applyModelLifecycle(req, id, &lifecycle); errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	rackErr, err := checkModelRackResize(ctx, qtx, id, before, size)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if rackErr != nil {
+		writeRackConflict(w, rackErr)
+		return
+	}
+
+	affected, err := qtx.UpdateModel(ctx, id, req.VendorId, name, nullIfEmpty(req.DeviceType), size, lifecycle)
+	if err != nil {
+		if writeModelFKError(w, err) {
+			return
 		}
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
@@ -725,6 +994,18 @@ func (a *app) handleModelsUpdate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
 		return
 	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeModel, id, req.DeviceType, false, req.CustomFields); err != nil {
+		if writeCustomFieldError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
 	writeJSON(w, http.StatusOK, idResponse{Id: id})
 }
@@ -777,13 +1058,56 @@ func (a *app) handleLocationsCreate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, apiError{Error: "name_required"})
 		return
 	}
+	geo, errCode := resolveLocationGeo(req, store.LocationGeo{})
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	if err := qtx.LockLocationTree(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	locType, errCode, err := resolveLocationPlacement(ctx, qtx, 0, req.ParentId.Value, req.Type)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if errCode == "" {
+		errCode = validateRackHeight(locType, req.RackHeightU)
+	}
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
 
 	var id int64
-	id, err := a.st.CreateLocation(r.Context(), name, nullIfEmpty(req.Note))
+	id, err = qtx.CreateLocation(ctx, name, nullIfEmpty(req.Note), req.ParentId.Value, locType, req.RackHeightU, geo)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
 	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeLocation, id, "", true, req.CustomFields); err != nil {
+		if writeCustomFieldError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
 	writeJSON(w, http.StatusCreated, idResponse{Id: id})
 }
@@ -811,7 +1135,69 @@ func (a *app) handleLocationsUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	affected, err := a.st.UpdateLocation(r.Context(), id, name, nullIfEmpty(req.Note))
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	if err := qtx.LockLocationTree(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	existing, err := qtx.GetLocationNode(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	// Не переданные parentId и type остаются прежними.
+	parentID := existing.ParentID
+	if req.ParentId.Set {
+		parentID = req.ParentId.Value
+	}
+	locType := req.Type
+	if strings.TrimSpace(locType) == "" {
+		locType = existing.Type
+	}
+	locType, errCode, err := resolveLocationPlacement(ctx, qtx, id, parentID, locType)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if errCode == "" {
+		errCode = validateRackHeight(locType, req.RackHeightU)
+	}
+	geo := existing.Geo
+	if errCode == "" {
+		geo, errCode = resolveLocationGeo(req, existing.Geo)
+	}
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+	// Высота сохраняется, пока локация остаётся стойкой.
+	rackHeightU := req.RackHeightU
+	if rackHeightU == nil && locType == locationTypeRack {
+		rackHeightU = existing.RackHeightU
+	}
+	rackErr, err := checkRackShrink(ctx, qtx, id, existing.RackHeightU, rackHeightU)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if rackErr != nil {
+		writeRackConflict(w, rackErr)
+		return
+	}
+
+	affected, err := qtx.UpdateLocation(ctx, id, name, nullIfEmpty(req.Note), parentID, locType, rackHeightU, geo)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
@@ -820,10 +1206,24 @@ func (a *app) handleLocationsUpdate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
 		return
 	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeLocation, id, "", false, req.CustomFields); err != nil {
+		if writeCustomFieldError(w, err) {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
 	writeJSON(w, http.StatusOK, idResponse{Id: id})
 }
 
+// handleLocationsDelete удаляет локацию. Если у неё есть вложенные локации,
+// запрос отклоняется, а с children=reparent они переносятся к её родителю.
 func (a *app) handleLocationsDelete(w http.ResponseWriter, r *http.Request) {
 	if authRole(r.Context()) != "admin" {
 		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
@@ -835,8 +1235,64 @@ func (a *app) handleLocationsDelete(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
 		return
 	}
+	reparent := false
+	switch strings.TrimSpace(r.URL.Query().Get("children")) {
+	case "", "refuse":
+	case "reparent":
+		reparent = true
+	default:
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_children_mode"})
+		return
+	}
 
-	affected, err := a.st.DeleteLocation(r.Context(), id)
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	if err := qtx.LockLocationTree(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	node, err := qtx.GetLocationNode(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	childTypes, err := qtx.ListChildLocationTypes(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if len(childTypes) > 0 {
+		if !reparent {
+			writeJSON(w, http.StatusConflict, apiError{Error: "has_children"})
+			return
+		}
+		// Дети глубже удаляемой локации, а значит и её родителя: порядок
+		// типов после переноса сохраняется.
+		if _, err := qtx.ReparentChildLocations(ctx, id, node.ParentID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+
+	if node.Type == locationTypeRack {
+		if err := clearRackPlacements(ctx, qtx, id); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+
+	affected, err := qtx.DeleteLocation(ctx, id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -853,32 +1309,135 @@ func (a *app) handleLocationsDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
-func (a *app) handleDevicesList(w http.ResponseWriter, r *http.Request) {
-	q := strings.TrimSpace(r.URL.Query().Get("q"))
-	rows, err := a.st.ListDevices(r.Context(), q)
+// deviceInput — провалидированные поля устройства для записи в БД.
+type deviceInput struct {
+	ModelId         int64
+	LocationId      *int64
+	SerialNumber    string
+	InventoryNumber string
+	Status          string
+	InstalledAt     *time.Time
+	Description     string
+	CustomFields    map[string]json.RawMessage
+	// nil — не менять (при создании — оставить пустым).
+	Hostname     *string
+	IPAddresses  []string
+	ManagementIP *string
+	MACAddresses []string
+	// RackPlacementSet — в запросе был rackPlacement; RackPositionU == nil
+	// тогда означает «снять с места».
+	RackPlacementSet bool
+	RackPositionU    *int32
+	RackFace         string
+}
+
+// validateDeviceUpsert применяет общие правила создания и обновления устройства.
+// Пустой статус остаётся пустым: при создании подставляется active, при
+// обновлении сохраняется текущий.
+func validateDeviceUpsert(req deviceUpsertRequest) (deviceInput, string) {
+	if req.ModelId <= 0 {
+		return deviceInput{}, "model_required"
+	}
+	return validateDeviceFields(req)
+}
+
+// validateDeviceFields проверяет поля устройства, кроме модели: импорт CSV
+// проверяет строки до того, как создаст недостающие модели.
+func validateDeviceFields(req deviceUpsertRequest) (deviceInput, string) {
+	in := deviceInput{
+		ModelId:         req.ModelId,
+		LocationId:      req.LocationId,
+		SerialNumber:    strings.TrimSpace(req.SerialNumber),
+		InventoryNumber: strings.TrimSpace(req.InventoryNumber),
+		Description:     strings.TrimSpace(req.Description),
+		CustomFields:    req.CustomFields,
+	}
+
+	if strings.TrimSpace(req.Status) != "" {
+		status, ok := normalizeDeviceStatus(req.Status)
+		if !ok {
+			return in, "invalid_status"
+		}
+		in.Status = status
+	}
+
+	installedAt, err := parseDateYYYYMMDD(req.InstalledAt)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
-		return
+		return in, "invalid_installed_at"
 	}
+	in.InstalledAt = installedAt
 
-	items := make([]deviceListItem, 0, len(rows))
-	for _, row := range rows {
-		items = append(items, deviceListItem{
-			Id:              row.ID,
-			VendorName:      row.VendorName,
-			ModelName:       row.ModelName,
-			LocationName:    row.LocationName,
-			SerialNumber:    row.SerialNumber,
-			InventoryNumber: row.InventoryNumber,
-			Status:          row.Status,
-			InstalledAt:     row.InstalledAt,
-		})
+	if errCode := validateDeviceNetwork(req, &in); errCode != "" {
+		return in, errCode
+	}
+	if errCode := validateDeviceRackPlacement(req, &in); errCode != "" {
+		return in, errCode
 	}
 
-	writeJSON(w, http.StatusOK, items)
+	return in, ""
+}
+
+// createDevice создаёт устройство в транзакции qtx вместе с начальной записью
+// журнала переходов и истории.
+func createDevice(ctx context.Context, qtx *store.Queries, in deviceInput) (int64, error) {
+	if in.Status == "" {
+		in.Status = deviceStatusActive
+	}
+	hostname := ""
+	if in.Hostname != nil {
+		hostname = *in.Hostname
+	}
+
+	id, err := qtx.CreateDevice(
+		ctx,
+		in.ModelId,
+		in.LocationId,
+		nullIfEmpty(in.SerialNumber),
+		nullIfEmpty(in.InventoryNumber),
+		in.Status,
+		in.InstalledAt,
+		nullIfEmpty(in.Description),
+		nullIfEmpty(hostname),
+		in.RackPositionU,
+		nullIfEmpty(in.RackFace),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveDeviceAddresses(ctx, qtx, id, in); err != nil {
+		return 0, err
+	}
+
+	deviceType, err := qtx.GetModelDeviceType(ctx, in.ModelId)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveEntityCustomFields(ctx, qtx, customFieldScopeDevice, id, deviceType, true, in.CustomFields); err != nil {
+		return 0, err
+	}
+
+	// Начальный статус тоже попадает в журнал переходов (from_status = NULL).
+	if _, err := qtx.CreateDeviceTransition(ctx, id, nil, in.Status, nil, authUsername(ctx)); err != nil {
+		return 0, err
+	}
+
+	after, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryCreate, nil, &after); err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
 func (a *app) handleDevicesCreate(w http.ResponseWriter, r *http.Request) {
@@ -888,36 +1447,58 @@ func (a *app) handleDevicesCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ModelId <= 0 {
-		writeJSON(w, http.StatusBadRequest, apiError{Error: "model_required"})
+	in, errCode := validateDeviceUpsert(req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
 		return
 	}
-	status := strings.TrimSpace(req.Status)
-	if status == "" {
-		status = "active"
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
 	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
 
-	installedAt, err := parseDateYYYYMMDD(req.InstalledAt)
+	conflict, err := a.checkDeviceUnique(ctx, qtx, 0, in)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_installed_at"})
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if conflict != nil {
+		writeJSON(w, http.StatusConflict, conflict)
 		return
 	}
 
-	var id int64
-	id, err = a.st.CreateDevice(
-		r.Context(),
-		req.ModelId,
-		req.LocationId,
-		nullIfEmpty(req.SerialNumber),
-		nullIfEmpty(req.InventoryNumber),
-		status,
-		installedAt,
-		nullIfEmpty(req.Description),
-	)
+	rackErr, err := checkDeviceRackPlacement(ctx, qtx, 0, in.LocationId, in.ModelId, in.RackPositionU, in.RackFace)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
 	}
+	if rackErr != nil {
+		writeRackConflict(w, rackErr)
+		return
+	}
+
+	id, err := createDevice(ctx, qtx, in)
+	if err != nil {
+		if writeCustomFieldError(w, err) {
+			return
+		}
+		if isUniqueViolation(err, deviceSerialKeyConstraint) {
+			a.writeSerialTakenAfterViolation(w, ctx, 0, in.SerialNumber)
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
 	writeJSON(w, http.StatusCreated, deviceUpsertResponse{Id: id})
 }
@@ -939,18 +1520,8 @@ func (a *app) handleDevicesGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := deviceDetailsResponse{
-		Id:              row.ID,
-		ModelId:         row.ModelID,
-		LocationId:      row.LocationID,
-		SerialNumber:    row.SerialNumber,
-		InventoryNumber: row.InventoryNumber,
-		Status:          row.Status,
-		InstalledAt:     row.InstalledAt,
-		Description:     row.Description,
-	}
-
-	writeJSON(w, http.StatusOK, resp)
+	w.Header().Set("ETag", deviceETag(row.Version))
+	writeJSON(w, http.StatusOK, deviceDetailsFromRow(row))
 }
 
 func (a *app) handleDevicesUpdate(w http.ResponseWriter, r *http.Request) {
@@ -966,33 +1537,120 @@ func (a *app) handleDevicesUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ModelId <= 0 {
-		writeJSON(w, http.StatusBadRequest, apiError{Error: "model_required"})
+	in, errCode := validateDeviceUpsert(req)
+	if errCode != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: errCode})
+		return
+	}
+
+	// Без If-Match правки двух операторов молча перезаписывали бы друг друга.
+	ifMatch := r.Header.Get("If-Match")
+	if strings.TrimSpace(ifMatch) == "" {
+		writeJSON(w, http.StatusPreconditionRequired, apiError{Error: "precondition_required"})
+		return
+	}
+	versions, anyVersion, ok := parseIfMatch(ifMatch)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_if_match"})
+		return
+	}
+
+	// Пустой статус означает «не менять».
+	status := in.Status
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	before, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
 	}
-	status := strings.TrimSpace(req.Status)
+	// Строка уже заблокирована GetDeviceSnapshot, так что версия не изменится
+	// до конца транзакции.
+	if !ifMatchVersion(versions, anyVersion, before.Version) {
+		current, err := qtx.GetDeviceByID(ctx, id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writePreconditionFailed(w, current)
+		return
+	}
+	currentStatus := before.Status
 	if status == "" {
-		status = "active"
+		status = currentStatus
+	}
+	if status != currentStatus && !deviceStatusTransitionAllowed(currentStatus, status) {
+		writeTransitionConflict(w, currentStatus, status)
+		return
 	}
 
-	installedAt, err := parseDateYYYYMMDD(req.InstalledAt)
+	conflict, err := a.checkDeviceUnique(ctx, qtx, id, in)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_installed_at"})
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if conflict != nil {
+		writeJSON(w, http.StatusConflict, conflict)
 		return
 	}
 
-	affected, err := a.st.UpdateDevice(
-		r.Context(),
+	hostname := before.Hostname
+	if in.Hostname != nil {
+		hostname = *in.Hostname
+	}
+
+	// Без rackPlacement место сохраняется, пока устройство остаётся в той же
+	// стойке; при смене модели оно проверяется заново под новую высоту.
+	rackPositionU, rackFace := in.RackPositionU, in.RackFace
+	if !in.RackPlacementSet {
+		rackPositionU, rackFace = nil, ""
+		if sameLocation(before.LocationID, in.LocationId) {
+			rackPositionU, rackFace = before.RackPositionU, before.RackFace
+		}
+	}
+	if rackPositionU != nil && (in.RackPlacementSet || in.ModelId != before.ModelID) {
+		rackErr, err := checkDeviceRackPlacement(ctx, qtx, id, in.LocationId, in.ModelId, rackPositionU, rackFace)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if rackErr != nil {
+			writeRackConflict(w, rackErr)
+			return
+		}
+	}
+
+	affected, err := qtx.UpdateDevice(
+		ctx,
 		id,
-		req.ModelId,
-		req.LocationId,
-		nullIfEmpty(req.SerialNumber),
-		nullIfEmpty(req.InventoryNumber),
+		in.ModelId,
+		in.LocationId,
+		nullIfEmpty(in.SerialNumber),
+		nullIfEmpty(in.InventoryNumber),
 		status,
-		installedAt,
-		nullIfEmpty(req.Description),
+		in.InstalledAt,
+		nullIfEmpty(in.Description),
+		nullIfEmpty(hostname),
+		rackPositionU,
+		nullIfEmpty(rackFace),
 	)
 	if err != nil {
+		if isUniqueViolation(err, deviceSerialKeyConstraint) {
+			a.writeSerialTakenAfterViolation(w, ctx, id, in.SerialNumber)
+			return
+		}
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
 	}
@@ -1000,8 +1658,61 @@ func (a *app) handleDevicesUpdate(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
 		return
 	}
+	if err := saveDeviceAddresses(ctx, qtx, id, in); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	// Значения проверяются заново и при смене модели: у новой модели может быть
+	// другой тип устройства и другой набор полей.
+	if in.CustomFields != nil || in.ModelId != before.ModelID {
+		deviceType, err := qtx.GetModelDeviceType(ctx, in.ModelId)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		values, err := applyCustomFields(ctx, qtx, customFieldScopeDevice, deviceType, before.CustomFields, in.CustomFields)
+		if err != nil {
+			if writeCustomFieldError(w, err) {
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if err := qtx.SetCustomFields(ctx, customFieldScopeDevice, id, values); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+
+	if status != currentStatus {
+		if _, err := qtx.CreateDeviceTransition(ctx, id, currentStatus, status, nil, authUsername(ctx)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+	if err := recordDeviceMove(ctx, qtx, id, before.LocationID, in.LocationId, ""); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	after, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryUpdate, &before, &after); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
 
-	writeJSON(w, http.StatusOK, deviceUpsertResponse{Id: id})
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	w.Header().Set("ETag", deviceETag(after.Version))
+	writeJSON(w, http.StatusOK, deviceUpsertResponse{Id: id, Version: after.Version})
 }
 
 func (a *app) handleDevicesDelete(w http.ResponseWriter, r *http.Request) {
@@ -1017,7 +1728,27 @@ func (a *app) handleDevicesDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	affected, err := a.st.DeleteDevice(r.Context(), id)
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	before, err := qtx.GetDeviceSnapshot(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	// Устройство уходит в корзину; окончательно его удаляет purge.
+	affected, err := qtx.TrashDevice(ctx, id, authUsername(ctx))
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
 		return
@@ -1027,6 +1758,16 @@ func (a *app) handleDevicesDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryDelete, &before, nil); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	eolMilestoneSale    = "sale"
+	eolMilestoneSupport = "support"
+	eolMilestoneLife    = "life"
+
+	modelReplacementFK = "models_replacement_model_fkey"
+
+	// eolReportDefaultDays — горизонт отчёта по умолчанию: год на планирование
+	// замены; eolReportMaxDays ограничивает его сверху.
+	eolReportDefaultDays = 365
+	eolReportMaxDays     = 3650
+)
+
+type eolReplacement struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type eolDeviceItem struct {
+	Id              int64  `json:"id"`
+	Label           string `json:"label"`
+	Hostname        string `json:"hostname"`
+	SerialNumber    string `json:"serialNumber"`
+	InventoryNumber string `json:"inventoryNumber"`
+	Status          string `json:"status"`
+}
+
+// eolModelItem — модель в группе отчёта. Date — выбранная дата жизненного
+// цикла; Past — она уже наступила.
+type eolModelItem struct {
+	ModelId      int64           `json:"modelId"`
+	ModelName    string          `json:"modelName"`
+	EndOfSale    *string         `json:"endOfSale"`
+	EndOfSupport *string         `json:"endOfSupport"`
+	EndOfLife    *string         `json:"endOfLife"`
+	Date         string          `json:"date"`
+	DaysLeft     int32           `json:"daysLeft"`
+	Past         bool            `json:"past"`
+	Replacement  *eolReplacement `json:"replacement"`
+	DeviceCount  int64           `json:"deviceCount"`
+	Devices      []eolDeviceItem `json:"devices"`
+}
+
+// eolGroup — устройства одного вендора в одной локации. LocationId == nil —
+// устройства без локации.
+type eolGroup struct {
+	LocationId   *int64         `json:"locationId"`
+	LocationPath string         `json:"locationPath"`
+	VendorId     int64          `json:"vendorId"`
+	VendorName   string         `json:"vendorName"`
+	DeviceCount  int64          `json:"deviceCount"`
+	PastCount    int64          `json:"pastCount"`
+	Models       []eolModelItem `json:"models"`
+}
+
+type eolReportResponse struct {
+	Milestone   string     `json:"milestone"`
+	Days        int32      `json:"days"`
+	DeviceCount int64      `json:"deviceCount"`
+	PastCount   int64      `json:"pastCount"`
+	Groups      []eolGroup `json:"groups"`
+}
+
+func formatOptionalDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("2006-01-02")
+	return &s
+}
+
+// applyModelLifecycle переносит переданные даты жизненного цикла и замену в
+// lc; отсутствующие поля не меняются, null очищает. modelID — 0 для новой
+// модели.
+func applyModelLifecycle(req modelUpsertRequest, modelID int64, lc *store.ModelLifecycle) string {
+	dates := []struct {
+		field optional[string]
+		dst   **time.Time
+		code  string
+	}{
+		{req.EndOfSale, &lc.EndOfSale, "invalid_end_of_sale"},
+		{req.EndOfSupport, &lc.EndOfSupport, "invalid_end_of_support"},
+		{req.EndOfLife, &lc.EndOfLife, "invalid_end_of_life"},
+	}
+	for _, d := range dates {
+		if !d.field.Set {
+			continue
+		}
+		*d.dst = nil
+		if d.field.Value == nil {
+			continue
+		}
+		parsed, err := parseDateYYYYMMDD(*d.field.Value)
+		if err != nil {
+			return d.code
+		}
+		*d.dst = parsed
+	}
+	if req.ReplacementModelId.Set {
+		lc.ReplacementModelID = req.ReplacementModelId.Value
+		if lc.ReplacementModelID != nil && (*lc.ReplacementModelID <= 0 || *lc.ReplacementModelID == modelID) {
+			return "invalid_replacement_model"
+		}
+	}
+	if dateAfter(lc.EndOfSale, lc.EndOfSupport) || dateAfter(lc.EndOfSupport, lc.EndOfLife) || dateAfter(lc.EndOfSale, lc.EndOfLife) {
+		return "invalid_lifecycle_dates"
+	}
+	return ""
+}
+
+func dateAfter(a, b *time.Time) bool {
+	return a != nil && b != nil && a.After(*b)
+}
+
+// writeModelFKError отвечает на нарушение внешнего ключа при записи модели:
+// не найден вендор или модель-замена. Возвращает false, если err другой.
+func writeModelFKError(w http.ResponseWriter, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23503" {
+		return false
+	}
+	if pgErr.ConstraintName == modelReplacementFK {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "replacement_model_not_found"})
+		return true
+	}
+	writeJSON(w, http.StatusBadRequest, apiError{Error: "vendor_not_found"})
+	return true
+}
+
+// handleReportsEol перечисляет устройства в эксплуатации, у модели которых
+// выбранная дата жизненного цикла (milestone: sale, support или life — по
+// умолчанию life) прошла или наступит в ближайшие days дней. Устройства
+// сгруппированы по локации и вендору, внутри — по моделям, чтобы по отчёту
+// можно было планировать бюджет замены.
+func (a *app) handleReportsEol(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.EolExposureFilter{Milestone: eolMilestoneLife, Days: eolReportDefaultDays}
+	if v := strings.ToLower(strings.TrimSpace(q.Get("milestone"))); v != "" {
+		if v != eolMilestoneSale && v != eolMilestoneSupport && v != eolMilestoneLife {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_milestone"})
+			return
+		}
+		f.Milestone = v
+	}
+	if v := strings.TrimSpace(q.Get("days")); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || n < 0 || n > eolReportMaxDays {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_days"})
+			return
+		}
+		f.Days = int32(n)
+	}
+	var err error
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_location_id"})
+		return
+	}
+	if f.VendorID, err = parseOptionalID(q.Get("vendor_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_vendor_id"})
+		return
+	}
+
+	rows, err := a.st.ListEolExposure(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := eolReportResponse{Milestone: f.Milestone, Days: f.Days, Groups: []eolGroup{}}
+	// Строки отсортированы по локации и вендору, поэтому группа и модель
+	// всегда последние в своих списках.
+	var group *eolGroup
+	var model *eolModelItem
+	for _, row := range rows {
+		if group == nil || !sameLocation(group.LocationId, row.LocationID) || group.VendorId != row.VendorID {
+			resp.Groups = append(resp.Groups, eolGroup{
+				LocationId:   row.LocationID,
+				LocationPath: row.LocationPath,
+				VendorId:     row.VendorID,
+				VendorName:   row.VendorName,
+				Models:       []eolModelItem{},
+			})
+			group = &resp.Groups[len(resp.Groups)-1]
+			model = nil
+		}
+		if model == nil || model.ModelId != row.ModelID {
+			item := eolModelItem{
+				ModelId:      row.ModelID,
+				ModelName:    row.ModelName,
+				EndOfSale:    formatOptionalDate(row.Lifecycle.EndOfSale),
+				EndOfSupport: formatOptionalDate(row.Lifecycle.EndOfSupport),
+				EndOfLife:    formatOptionalDate(row.Lifecycle.EndOfLife),
+				Date:         row.Milestone.Format("2006-01-02"),
+				DaysLeft:     row.DaysLeft,
+				Past:         row.DaysLeft < 0,
+				Devices:      []eolDeviceItem{},
+			}
+			if row.Lifecycle.ReplacementModelID != nil {
+				item.Replacement = &eolReplacement{Id: *row.Lifecycle.ReplacementModelID, Name: row.ReplacementModelName}
+			}
+			group.Models = append(group.Models, item)
+			model = &group.Models[len(group.Models)-1]
+		}
+
+		model.Devices = append(model.Devices, eolDeviceItem{
+			Id:              row.DeviceID,
+			Label:           deviceLabel(row.Hostname, row.VendorName, row.ModelName, row.SerialNumber),
+			Hostname:        row.Hostname,
+			SerialNumber:    row.SerialNumber,
+			InventoryNumber: row.InventoryNumber,
+			Status:          row.Status,
+		})
+		model.DeviceCount++
+		group.DeviceCount++
+		resp.DeviceCount++
+		if model.Past {
+			group.PastCount++
+			resp.PastCount++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
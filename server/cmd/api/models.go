@@ -5,11 +5,19 @@ import (
 )
 
 type modelListItem struct {
-	Id         int64  `json:"id"`
-	VendorId   int64  `json:"vendorId"`
-	VendorName string `json:"vendorName"`
-	Name       string `json:"name"`
-	DeviceType string `json:"deviceType"`
+	Id           int64          `json:"id"`
+	VendorId     int64          `json:"vendorId"`
+	VendorName   string         `json:"vendorName"`
+	Name         string         `json:"name"`
+	DeviceType   string         `json:"deviceType"`
+	CustomFields map[string]any `json:"customFields"`
+	HeightU      int32          `json:"heightU"`
+	FullDepth    bool           `json:"fullDepth"`
+	EndOfSale    *string        `json:"endOfSale"`
+	EndOfSupport *string        `json:"endOfSupport"`
+	EndOfLife    *string        `json:"endOfLife"`
+	// Replacement — рекомендуемая модель на замену.
+	Replacement *eolReplacement `json:"replacement"`
 }
 
 func (a *app) handleModelsList(w http.ResponseWriter, r *http.Request) {
@@ -21,13 +29,23 @@ func (a *app) handleModelsList(w http.ResponseWriter, r *http.Request) {
 
 	items := make([]modelListItem, 0, len(rows))
 	for _, row := range rows {
-		items = append(items, modelListItem{
-			Id:         row.ID,
-			VendorId:   row.VendorID,
-			VendorName: row.VendorName,
-			Name:       row.Name,
-			DeviceType: row.DeviceType,
-		})
+		item := modelListItem{
+			Id:           row.ID,
+			VendorId:     row.VendorID,
+			VendorName:   row.VendorName,
+			Name:         row.Name,
+			DeviceType:   row.DeviceType,
+			CustomFields: customFieldsResponse(row.CustomFields),
+			HeightU:      row.HeightU,
+			FullDepth:    row.FullDepth,
+			EndOfSale:    formatOptionalDate(row.Lifecycle.EndOfSale),
+			EndOfSupport: formatOptionalDate(row.Lifecycle.EndOfSupport),
+			EndOfLife:    formatOptionalDate(row.Lifecycle.EndOfLife),
+		}
+		if row.Lifecycle.ReplacementModelID != nil {
+			item.Replacement = &eolReplacement{Id: *row.Lifecycle.ReplacementModelID, Name: row.ReplacementModelName}
+		}
+		items = append(items, item)
 	}
 
 	writeJSON(w, http.StatusOK, items)
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	rackFaceFront = "front"
+	rackFaceRear  = "rear"
+
+	rackHeightMaxU = 100
+)
+
+// defaultModelRackSize — размеры новой модели, если они не указаны.
+var defaultModelRackSize = store.ModelRackSize{HeightU: 1, FullDepth: true}
+
+// deviceRackPlacement — место устройства в стойке. Стойка — локация
+// устройства; positionU — нижний занимаемый юнит, null снимает устройство
+// с места.
+type deviceRackPlacement struct {
+	PositionU *int32 `json:"positionU"`
+	Face      string `json:"face"`
+}
+
+// rackConflict — ошибка размещения; DeviceId — устройство, которое мешает.
+type rackConflict struct {
+	Error    string `json:"error"`
+	DeviceId *int64 `json:"deviceId,omitempty"`
+}
+
+type rackElevationDevice struct {
+	Id           int64  `json:"id"`
+	Label        string `json:"label"`
+	Hostname     string `json:"hostname"`
+	VendorName   string `json:"vendorName"`
+	ModelName    string `json:"modelName"`
+	SerialNumber string `json:"serialNumber"`
+	Status       string `json:"status"`
+	PositionU    *int32 `json:"positionU"`
+	HeightU      int32  `json:"heightU"`
+	Face         string `json:"face"`
+	FullDepth    bool   `json:"fullDepth"`
+}
+
+// rackElevationUnit — юнит стойки и устройства, занимающие его спереди и сзади.
+type rackElevationUnit struct {
+	U     int32  `json:"u"`
+	Front *int64 `json:"front"`
+	Rear  *int64 `json:"rear"`
+}
+
+type rackFreeRange struct {
+	TopU    int32 `json:"topU"`
+	BottomU int32 `json:"bottomU"`
+	SizeU   int32 `json:"sizeU"`
+}
+
+type rackElevationResponse struct {
+	RackId  int64  `json:"rackId"`
+	Name    string `json:"name"`
+	HeightU int32  `json:"heightU"`
+	// UsedU — юниты, занятые хотя бы с одной стороны; FreeU — свободные с обеих.
+	UsedU      int32 `json:"usedU"`
+	FreeU      int32 `json:"freeU"`
+	FreeFrontU int32 `json:"freeFrontU"`
+	FreeRearU  int32 `json:"freeRearU"`
+	// Units — сверху вниз, как стойку рисуют на схеме.
+	Units []rackElevationUnit `json:"units"`
+	// FreeRanges — непрерывные блоки юнитов, свободных с обеих сторон, сверху вниз.
+	FreeRanges []rackFreeRange       `json:"freeRanges"`
+	Devices    []rackElevationDevice `json:"devices"`
+	// Unplaced — устройства стойки без указанного места.
+	Unplaced []rackElevationDevice `json:"unplaced"`
+}
+
+func normalizeRackFace(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", rackFaceFront:
+		return rackFaceFront, true
+	case rackFaceRear:
+		return rackFaceRear, true
+	}
+	return "", false
+}
+
+func sameLocation(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// validateDeviceRackPlacement переносит rackPlacement запроса в in. Проверки,
+// требующие стойки и модели, делает checkDeviceRackPlacement.
+func validateDeviceRackPlacement(req deviceUpsertRequest, in *deviceInput) string {
+	if req.RackPlacement == nil {
+		return ""
+	}
+	in.RackPlacementSet = true
+	if req.RackPlacement.PositionU == nil {
+		return ""
+	}
+	if *req.RackPlacement.PositionU < 1 || *req.RackPlacement.PositionU > rackHeightMaxU {
+		return "invalid_rack_position"
+	}
+	face, ok := normalizeRackFace(req.RackPlacement.Face)
+	if !ok {
+		return "invalid_rack_face"
+	}
+	in.RackPositionU = req.RackPlacement.PositionU
+	in.RackFace = face
+	return ""
+}
+
+// checkDeviceRackPlacement проверяет, что устройство deviceID (0 — новое)
+// модели modelID помещается в стойку locationID с юнита positionU на стороне
+// face и не пересекается с соседями. Блокирует строку стойки до конца
+// транзакции, поэтому параллельные размещения в одну стойку идут по очереди.
+func checkDeviceRackPlacement(ctx context.Context, q *store.Queries, deviceID int64, locationID *int64, modelID int64, positionU *int32, face string) (*rackConflict, error) {
+	if positionU == nil {
+		return nil, nil
+	}
+	if locationID == nil {
+		return &rackConflict{Error: "rack_required"}, nil
+	}
+	rack, err := q.LockRack(ctx, *locationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &rackConflict{Error: "location_not_found"}, nil
+		}
+		return nil, err
+	}
+	if rack.Type != locationTypeRack {
+		return &rackConflict{Error: "location_not_rack"}, nil
+	}
+	if rack.HeightU == nil {
+		return &rackConflict{Error: "rack_height_unknown"}, nil
+	}
+	size, err := q.GetModelRackSize(ctx, modelID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &rackConflict{Error: "model_not_found"}, nil
+		}
+		return nil, err
+	}
+
+	lastU := *positionU + size.HeightU - 1
+	if lastU > *rack.HeightU {
+		return &rackConflict{Error: "rack_position_out_of_range"}, nil
+	}
+	other, err := q.FindRackOverlap(ctx, store.RackPlacement{
+		RackID:    *locationID,
+		DeviceID:  deviceID,
+		FirstU:    *positionU,
+		LastU:     lastU,
+		Face:      face,
+		FullDepth: size.FullDepth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if other != nil {
+		return &rackConflict{Error: "rack_position_taken", DeviceId: other}, nil
+	}
+	return nil, nil
+}
+
+// writeRackConflict отвечает 409, если место занято другим устройством, и 400
+// на остальные ошибки размещения.
+func writeRackConflict(w http.ResponseWriter, c *rackConflict) {
+	status := http.StatusBadRequest
+	if c.DeviceId != nil {
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, c)
+}
+
+// validateRackHeight проверяет высоту стойки из запроса локации.
+func validateRackHeight(locType string, heightU *int32) string {
+	if heightU == nil {
+		return ""
+	}
+	if locType != locationTypeRack {
+		return "rack_height_not_allowed"
+	}
+	if *heightU < 1 || *heightU > rackHeightMaxU {
+		return "invalid_rack_height"
+	}
+	return ""
+}
+
+// applyModelRackSize переносит переданные heightU и fullDepth модели в size.
+func applyModelRackSize(req modelUpsertRequest, size *store.ModelRackSize) string {
+	if req.HeightU != nil {
+		if *req.HeightU < 1 || *req.HeightU > rackHeightMaxU {
+			return "invalid_height"
+		}
+		size.HeightU = *req.HeightU
+	}
+	if req.FullDepth != nil {
+		size.FullDepth = *req.FullDepth
+	}
+	return ""
+}
+
+// checkRackShrink проверяет, что при новой высоте стойки (nil — локация
+// перестаёт быть стойкой) размещённые устройства остаются внутри неё.
+func checkRackShrink(ctx context.Context, q *store.Queries, rackID int64, oldHeight, newHeight *int32) (*rackConflict, error) {
+	if oldHeight == nil || (newHeight != nil && *newHeight >= *oldHeight) {
+		return nil, nil
+	}
+	if _, err := q.LockRack(ctx, rackID); err != nil {
+		return nil, err
+	}
+	var limit int32
+	if newHeight != nil {
+		limit = *newHeight
+	}
+	other, err := q.FindRackDeviceAbove(ctx, rackID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if other != nil {
+		return &rackConflict{Error: "rack_height_conflict", DeviceId: other}, nil
+	}
+	return nil, nil
+}
+
+// checkModelRackResize проверяет, что устройства модели остаются на своих местах
+// при новых размерах: не выходят за верх стойки и не наезжают на соседей.
+func checkModelRackResize(ctx context.Context, q *store.Queries, modelID int64, before, after store.ModelRackSize) (*rackConflict, error) {
+	grows := after.HeightU > before.HeightU || (after.FullDepth && !before.FullDepth)
+	if !grows {
+		return nil, nil
+	}
+	if err := q.LockModelRacks(ctx, modelID); err != nil {
+		return nil, err
+	}
+	other, err := q.FindModelRackConflict(ctx, modelID, after)
+	if err != nil {
+		return nil, err
+	}
+	if other != nil {
+		return &rackConflict{Error: "rack_conflict", DeviceId: other}, nil
+	}
+	return nil, nil
+}
+
+func rackElevationDeviceFromRow(row store.RackDeviceRow) rackElevationDevice {
+	return rackElevationDevice{
+		Id:           row.ID,
+		Label:        deviceLabel(row.Hostname, row.VendorName, row.ModelName, row.SerialNumber),
+		Hostname:     row.Hostname,
+		VendorName:   row.VendorName,
+		ModelName:    row.ModelName,
+		SerialNumber: row.SerialNumber,
+		Status:       row.Status,
+		PositionU:    row.PositionU,
+		HeightU:      row.HeightU,
+		Face:         row.Face,
+		FullDepth:    row.FullDepth,
+	}
+}
+
+// handleRackElevation отдаёт схему стойки: занятость каждого юнита спереди и
+// сзади, свободные блоки и сводку для планирования ёмкости.
+func (a *app) handleRackElevation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	rack, err := a.st.GetRack(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if rack.Type != locationTypeRack {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "location_not_rack"})
+		return
+	}
+	rows, err := a.st.ListRackDevices(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	var height int32
+	if rack.HeightU != nil {
+		height = *rack.HeightU
+	}
+	resp := rackElevationResponse{
+		RackId:     rack.ID,
+		Name:       rack.Name,
+		HeightU:    height,
+		Units:      make([]rackElevationUnit, height),
+		FreeRanges: []rackFreeRange{},
+		Devices:    []rackElevationDevice{},
+		Unplaced:   []rackElevationDevice{},
+	}
+	// units[i] — юнит height-i: первый элемент — верх стойки.
+	for i := range resp.Units {
+		resp.Units[i].U = height - int32(i)
+	}
+	for _, row := range rows {
+		item := rackElevationDeviceFromRow(row)
+		if row.PositionU == nil {
+			resp.Unplaced = append(resp.Unplaced, item)
+			continue
+		}
+		resp.Devices = append(resp.Devices, item)
+		for u := *row.PositionU; u < *row.PositionU+row.HeightU; u++ {
+			if u < 1 || u > height {
+				continue
+			}
+			unit := &resp.Units[height-u]
+			deviceID := row.ID
+			if row.FullDepth || row.Face == rackFaceFront {
+				unit.Front = &deviceID
+			}
+			if row.FullDepth || row.Face == rackFaceRear {
+				unit.Rear = &deviceID
+			}
+		}
+	}
+
+	var free *rackFreeRange
+	for _, unit := range resp.Units {
+		if unit.Front == nil {
+			resp.FreeFrontU++
+		}
+		if unit.Rear == nil {
+			resp.FreeRearU++
+		}
+		if unit.Front != nil || unit.Rear != nil {
+			resp.UsedU++
+			free = nil
+			continue
+		}
+		resp.FreeU++
+		if free == nil {
+			resp.FreeRanges = append(resp.FreeRanges, rackFreeRange{TopU: unit.U})
+			free = &resp.FreeRanges[len(resp.FreeRanges)-1]
+		}
+		free.BottomU = unit.U
+		free.SizeU = free.TopU - unit.U + 1
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// clearRackPlacements снимает с мест все устройства стойки rackID перед её
+// удалением. Как и при снятии одного устройства, версия растёт, а изменение
+// попадает в историю (для устройств вне корзины).
+func clearRackPlacements(ctx context.Context, q *store.Queries, rackID int64) error {
+	ids, err := q.ListRackPlacedDevices(ctx, rackID)
+	if err != nil {
+		return err
+	}
+	before := make([]store.GetDeviceSnapshotRow, 0, len(ids))
+	for _, id := range ids {
+		snap, err := q.GetDeviceSnapshot(ctx, id)
+		if err != nil {
+			return err
+		}
+		before = append(before, snap)
+	}
+
+	if err := q.ClearRackPlacements(ctx, rackID); err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		after, err := q.GetDeviceSnapshot(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := recordDeviceHistory(ctx, q, id, deviceHistoryUpdate, &before[i], &after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
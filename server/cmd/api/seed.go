@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"log"
+
+	"telecombase/server/internal/store"
 )
 
 func (a *app) seedIfEmpty(ctx context.Context) {
@@ -27,7 +29,7 @@ func (a *app) seedIfEmpty(ctx context.Context) {
 		return
 	}
 	if locationsCount == 0 {
-		if _, err := a.st.CreateLocation(ctx, "Main office", "Default location"); err != nil {
+		if _, err := a.st.CreateLocation(ctx, "Main office", "Default location", nil, locationTypeSite, nil, store.LocationGeo{}); err != nil {
 			log.Printf("seed locations: %v", err)
 			return
 		}
@@ -44,7 +46,7 @@ func (a *app) seedIfEmpty(ctx context.Context) {
 			log.Printf("seed models vendor: %v", err)
 			return
 		}
-		if _, err := a.st.CreateModel(ctx, vendorId, "ISR 4321", "router"); err != nil {
+		if _, err := a.st.CreateModel(ctx, vendorId, "ISR 4321", "router", defaultModelRackSize, store.ModelLifecycle{}); err != nil {
 			log.Printf("seed models: %v", err)
 			return
 		}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	stockMovementReceive  = "receive"
+	stockMovementIssue    = "issue"
+	stockMovementTransfer = "transfer"
+
+	stockItemsUniqueConstraint = "stock_items_model_location_unique"
+	stockQuantityMax           = 1000000
+
+	stockMovementsDefaultLimit = 100
+	stockMovementsMaxLimit     = 1000
+)
+
+type stockItemCreateRequest struct {
+	ModelId     int64  `json:"modelId"`
+	LocationId  int64  `json:"locationId"`
+	MinQuantity int32  `json:"minQuantity"`
+	Note        string `json:"note"`
+}
+
+// stockItemUpdateRequest меняет порог и заметку; остаток меняется только
+// движениями.
+type stockItemUpdateRequest struct {
+	MinQuantity *int32  `json:"minQuantity"`
+	Note        *string `json:"note"`
+}
+
+// stockMovementRequest — движение по складу. receive требует toLocationId,
+// issue — fromLocationId, transfer — обе локации.
+type stockMovementRequest struct {
+	Kind           string `json:"kind"`
+	ModelId        int64  `json:"modelId"`
+	FromLocationId *int64 `json:"fromLocationId"`
+	ToLocationId   *int64 `json:"toLocationId"`
+	Quantity       int32  `json:"quantity"`
+	Reason         string `json:"reason"`
+	DocumentNumber string `json:"documentNumber"`
+}
+
+type stockItem struct {
+	Id           int64  `json:"id"`
+	ModelId      int64  `json:"modelId"`
+	VendorName   string `json:"vendorName"`
+	ModelName    string `json:"modelName"`
+	DeviceType   string `json:"deviceType"`
+	LocationId   int64  `json:"locationId"`
+	LocationPath string `json:"locationPath"`
+	Quantity     int32  `json:"quantity"`
+	MinQuantity  int32  `json:"minQuantity"`
+	// Shortage — сколько не хватает до минимального остатка.
+	Shortage  int32  `json:"shortage"`
+	Low       bool   `json:"low"`
+	Note      string `json:"note"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type stockMovementItem struct {
+	Id               int64  `json:"id"`
+	Kind             string `json:"kind"`
+	ModelId          int64  `json:"modelId"`
+	VendorName       string `json:"vendorName"`
+	ModelName        string `json:"modelName"`
+	FromLocationId   *int64 `json:"fromLocationId"`
+	FromLocationPath string `json:"fromLocationPath"`
+	ToLocationId     *int64 `json:"toLocationId"`
+	ToLocationPath   string `json:"toLocationPath"`
+	Quantity         int32  `json:"quantity"`
+	Reason           string `json:"reason"`
+	DocumentNumber   string `json:"documentNumber"`
+	Actor            string `json:"actor"`
+	CreatedAt        string `json:"createdAt"`
+}
+
+type stockBalance struct {
+	LocationId int64 `json:"locationId"`
+	Quantity   int32 `json:"quantity"`
+}
+
+// stockMovementResponse — запись журнала и остатки затронутых локаций после движения.
+type stockMovementResponse struct {
+	Id        int64          `json:"id"`
+	CreatedAt string         `json:"createdAt"`
+	Balances  []stockBalance `json:"balances"`
+}
+
+type stockShortage struct {
+	Error      string `json:"error"`
+	LocationId int64  `json:"locationId"`
+	Available  int32  `json:"available"`
+	Requested  int32  `json:"requested"`
+}
+
+type lowStockResponse struct {
+	Count int         `json:"count"`
+	Items []stockItem `json:"items"`
+}
+
+func stockItemFromRow(row store.StockItemRow) stockItem {
+	var shortage int32
+	if row.Quantity < row.MinQuantity {
+		shortage = row.MinQuantity - row.Quantity
+	}
+	return stockItem{
+		Id:           row.ID,
+		ModelId:      row.ModelID,
+		VendorName:   row.VendorName,
+		ModelName:    row.ModelName,
+		DeviceType:   row.DeviceType,
+		LocationId:   row.LocationID,
+		LocationPath: row.LocationPath,
+		Quantity:     row.Quantity,
+		MinQuantity:  row.MinQuantity,
+		Shortage:     shortage,
+		Low:          shortage > 0,
+		Note:         row.Note,
+		UpdatedAt:    row.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// stockFKError переводит нарушение внешнего ключа складских таблиц в код
+// ошибки API; "" — err другой.
+func stockFKError(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23503" {
+		return ""
+	}
+	if strings.Contains(pgErr.ConstraintName, "model") {
+		return "model_not_found"
+	}
+	return "location_not_found"
+}
+
+// validateStockMovement проверяет вид движения и набор локаций для него.
+func validateStockMovement(req *stockMovementRequest) string {
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	if req.ModelId <= 0 {
+		return "model_required"
+	}
+	if req.Quantity <= 0 || req.Quantity > stockQuantityMax {
+		return "invalid_quantity"
+	}
+	from, to := req.FromLocationId != nil, req.ToLocationId != nil
+	if (from && *req.FromLocationId <= 0) || (to && *req.ToLocationId <= 0) {
+		return "invalid_location_id"
+	}
+	switch req.Kind {
+	case stockMovementReceive:
+		if from || !to {
+			return "invalid_locations"
+		}
+	case stockMovementIssue:
+		if !from || to {
+			return "invalid_locations"
+		}
+	case stockMovementTransfer:
+		if !from || !to || *req.FromLocationId == *req.ToLocationId {
+			return "invalid_locations"
+		}
+	default:
+		return "invalid_kind"
+	}
+	return ""
+}
+
+func parseStockItemFilter(r *http.Request) (store.StockItemFilter, string) {
+	q := r.URL.Query()
+	f := store.StockItemFilter{Query: strings.TrimSpace(q.Get("q"))}
+	var err error
+	if f.ModelID, err = parseOptionalID(q.Get("model_id")); err != nil {
+		return f, "invalid_model_id"
+	}
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		return f, "invalid_location_id"
+	}
+	return f, ""
+}
+
+func (a *app) handleStockList(w http.ResponseWriter, r *http.Request) {
+	f, code := parseStockItemFilter(r)
+	if code != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+		return
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("low")); v != "" {
+		low, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_low"})
+			return
+		}
+		f.LowOnly = low
+	}
+
+	rows, err := a.st.ListStockItems(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]stockItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, stockItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleReportsLowStock перечисляет позиции, где остаток опустился ниже
+// минимального; фильтры те же, что у списка остатков.
+func (a *app) handleReportsLowStock(w http.ResponseWriter, r *http.Request) {
+	f, code := parseStockItemFilter(r)
+	if code != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+		return
+	}
+	f.LowOnly = true
+
+	rows, err := a.st.ListStockItems(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := lowStockResponse{Count: len(rows), Items: make([]stockItem, 0, len(rows))}
+	for _, row := range rows {
+		resp.Items = append(resp.Items, stockItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *app) handleStockCreate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	var req stockItemCreateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	if req.ModelId <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "model_required"})
+		return
+	}
+	if req.LocationId <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "location_required"})
+		return
+	}
+	if req.MinQuantity < 0 || req.MinQuantity > stockQuantityMax {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_min_quantity"})
+		return
+	}
+
+	id, err := a.st.CreateStockItem(r.Context(), req.ModelId, req.LocationId, req.MinQuantity, nullIfEmpty(req.Note))
+	if err != nil {
+		if isUniqueViolation(err, stockItemsUniqueConstraint) {
+			writeJSON(w, http.StatusConflict, apiError{Error: "stock_item_exists"})
+			return
+		}
+		if code := stockFKError(err); code != "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (a *app) handleStockUpdate(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req stockItemUpdateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+
+	current, err := a.st.GetStockItem(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	minQuantity, note := current.MinQuantity, current.Note
+	if req.MinQuantity != nil {
+		if *req.MinQuantity < 0 || *req.MinQuantity > stockQuantityMax {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_min_quantity"})
+			return
+		}
+		minQuantity = *req.MinQuantity
+	}
+	if req.Note != nil {
+		note = *req.Note
+	}
+
+	if err := a.st.UpdateStockItem(r.Context(), id, minQuantity, nullIfEmpty(note)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: id})
+}
+
+func (a *app) handleStockDelete(w http.ResponseWriter, r *http.Request) {
+	if authRole(r.Context()) != "admin" {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "forbidden"})
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	affected, err := a.st.DeleteStockItem(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if affected == 0 {
+		// Позиция не удалена: либо её нет, либо на ней ещё есть остаток.
+		if _, err := a.st.GetStockItem(r.Context(), id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		writeJSON(w, http.StatusConflict, apiError{Error: "stock_not_empty"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+// handleStockMovementsCreate проводит движение: меняет остатки затронутых
+// локаций и пишет запись в журнал в одной транзакции. Остатки блокируются в
+// порядке id, поэтому встречные перемещения не взаимоблокируются, а остаток
+// не уходит в минус при параллельных выдачах.
+func (a *app) handleStockMovementsCreate(w http.ResponseWriter, r *http.Request) {
+	var req stockMovementRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	if code := validateStockMovement(&req); code != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	var locationIDs []int64
+	if req.FromLocationId != nil {
+		locationIDs = append(locationIDs, *req.FromLocationId)
+	}
+	if req.ToLocationId != nil {
+		if err := qtx.EnsureStockItem(ctx, req.ModelId, *req.ToLocationId); err != nil {
+			if code := stockFKError(err); code != "" {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		locationIDs = append(locationIDs, *req.ToLocationId)
+	}
+
+	locks, err := qtx.LockStockItems(ctx, req.ModelId, locationIDs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	byLocation := make(map[int64]store.StockLock, len(locks))
+	for _, l := range locks {
+		byLocation[l.LocationID] = l
+	}
+
+	var balances []stockBalance
+	if req.FromLocationId != nil {
+		src := byLocation[*req.FromLocationId]
+		if src.Quantity < req.Quantity {
+			writeJSON(w, http.StatusConflict, stockShortage{
+				Error:      "insufficient_stock",
+				LocationId: *req.FromLocationId,
+				Available:  src.Quantity,
+				Requested:  req.Quantity,
+			})
+			return
+		}
+		if err := qtx.AddStockQuantity(ctx, src.ID, -req.Quantity); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		balances = append(balances, stockBalance{LocationId: src.LocationID, Quantity: src.Quantity - req.Quantity})
+	}
+	if req.ToLocationId != nil {
+		dst := byLocation[*req.ToLocationId]
+		if dst.Quantity > stockQuantityMax-req.Quantity {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_quantity"})
+			return
+		}
+		if err := qtx.AddStockQuantity(ctx, dst.ID, req.Quantity); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		balances = append(balances, stockBalance{LocationId: dst.LocationID, Quantity: dst.Quantity + req.Quantity})
+	}
+
+	id, createdAt, err := qtx.CreateStockMovement(ctx, store.StockMovementParams{
+		Kind:           req.Kind,
+		ModelID:        req.ModelId,
+		FromLocationID: req.FromLocationId,
+		ToLocationID:   req.ToLocationId,
+		Quantity:       req.Quantity,
+		Reason:         nullIfEmpty(req.Reason),
+		DocumentNumber: nullIfEmpty(req.DocumentNumber),
+		Actor:          authUsername(ctx),
+	})
+	if err != nil {
+		if code := stockFKError(err); code != "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, stockMovementResponse{
+		Id:        id,
+		CreatedAt: createdAt.UTC().Format(time.RFC3339),
+		Balances:  balances,
+	})
+}
+
+func (a *app) handleStockMovementsList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.StockMovementFilter{Limit: stockMovementsDefaultLimit}
+	var err error
+	if f.ModelID, err = parseOptionalID(q.Get("model_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_model_id"})
+		return
+	}
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_location_id"})
+		return
+	}
+	if v := strings.ToLower(strings.TrimSpace(q.Get("kind"))); v != "" {
+		if v != stockMovementReceive && v != stockMovementIssue && v != stockMovementTransfer {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_kind"})
+			return
+		}
+		f.Kind = &v
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > stockMovementsMaxLimit {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_limit"})
+			return
+		}
+		f.Limit = int32(limit)
+	}
+	if v := strings.TrimSpace(q.Get("offset")); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_offset"})
+			return
+		}
+		f.Offset = int32(offset)
+	}
+
+	rows, err := a.st.ListStockMovements(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]stockMovementItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, stockMovementItem{
+			Id:               row.ID,
+			Kind:             row.Kind,
+			ModelId:          row.ModelID,
+			VendorName:       row.VendorName,
+			ModelName:        row.ModelName,
+			FromLocationId:   row.FromLocationID,
+			FromLocationPath: row.FromLocationPath,
+			ToLocationId:     row.ToLocationID,
+			ToLocationPath:   row.ToLocationPath,
+			Quantity:         row.Quantity,
+			Reason:           row.Reason,
+			DocumentNumber:   row.DocumentNumber,
+			Actor:            row.Actor,
+			CreatedAt:        row.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, items)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type topologyNode struct {
+	Id           int64  `json:"id"`
+	Label        string `json:"label"`
+	Hostname     string `json:"hostname"`
+	VendorName   string `json:"vendorName"`
+	ModelName    string `json:"modelName"`
+	SerialNumber string `json:"serialNumber"`
+	LocationId   *int64 `json:"locationId"`
+	LocationName string `json:"locationName"`
+	Status       string `json:"status"`
+	// External — устройство из другой локации, куда уходит линк.
+	External bool `json:"external"`
+}
+
+type topologyEdge struct {
+	Id              int64  `json:"id"`
+	Source          int64  `json:"source"`
+	SourceInterface string `json:"sourceInterface"`
+	Target          int64  `json:"target"`
+	TargetInterface string `json:"targetInterface"`
+	SpeedMbps       *int64 `json:"speedMbps"`
+	CableType       string `json:"cableType"`
+	Description     string `json:"description"`
+}
+
+type topologyResponse struct {
+	Nodes []topologyNode `json:"nodes"`
+	Edges []topologyEdge `json:"edges"`
+}
+
+// deviceLabel — имя хоста, а без него — модель и серийный номер.
+func deviceLabel(hostname, vendorName, modelName, serialNumber string) string {
+	if hostname != "" {
+		return hostname
+	}
+	label := vendorName + " " + modelName
+	if serialNumber != "" {
+		label += " (" + serialNumber + ")"
+	}
+	return label
+}
+
+// dotQuote экранирует строку для DOT.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func writeTopologyDOT(w http.ResponseWriter, t topologyResponse) {
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range t.Nodes {
+		label := n.Label
+		if n.LocationName != "" {
+			label += "\n" + n.LocationName
+		}
+		attrs := "label=" + dotQuote(label)
+		if n.External {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  d%d [%s];\n", n.Id, attrs)
+	}
+	for _, e := range t.Edges {
+		fmt.Fprintf(&b, "  d%d -- d%d [taillabel=%s, headlabel=%s];\n",
+			e.Source, e.Target, dotQuote(e.SourceInterface), dotQuote(e.TargetInterface))
+	}
+	b.WriteString("}\n")
+
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// handleTopology отдаёт граф устройств и линков локации (location_id, вместе с
+// вложенными) или всей сети: JSON по умолчанию, DOT для Graphviz при format=dot.
+func (a *app) handleTopology(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	locationID, err := parseOptionalID(q.Get("location_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_location_id"})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format != "" && format != "json" && format != "dot" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_format"})
+		return
+	}
+
+	links, err := a.st.ListTopologyLinks(r.Context(), locationID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	// Дальние концы линков тоже попадают в граф, даже если они в другой локации.
+	var extraIDs []int64
+	if locationID != nil {
+		for _, l := range links {
+			extraIDs = append(extraIDs, l.ADeviceID, l.BDeviceID)
+		}
+	}
+	devices, err := a.st.ListTopologyDevices(r.Context(), locationID, extraIDs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	resp := topologyResponse{
+		Nodes: make([]topologyNode, 0, len(devices)),
+		Edges: make([]topologyEdge, 0, len(links)),
+	}
+	for _, d := range devices {
+		resp.Nodes = append(resp.Nodes, topologyNode{
+			Id:           d.ID,
+			Label:        deviceLabel(d.Hostname, d.VendorName, d.ModelName, d.SerialNumber),
+			Hostname:     d.Hostname,
+			VendorName:   d.VendorName,
+			ModelName:    d.ModelName,
+			SerialNumber: d.SerialNumber,
+			LocationId:   d.LocationID,
+			LocationName: d.LocationName,
+			Status:       d.Status,
+			External:     !d.InScope,
+		})
+	}
+	for _, l := range links {
+		resp.Edges = append(resp.Edges, topologyEdge{
+			Id:              l.ID,
+			Source:          l.ADeviceID,
+			SourceInterface: l.AInterfaceName,
+			Target:          l.BDeviceID,
+			TargetInterface: l.BInterfaceName,
+			SpeedMbps:       l.SpeedMbps,
+			CableType:       l.CableType,
+			Description:     l.Description,
+		})
+	}
+
+	if format == "dot" {
+		writeTopologyDOT(w, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/pdf"
+	"telecombase/server/internal/store"
+)
+
+const (
+	transferMaxDevices          = 1000
+	transferDocumentNumberMax   = 100
+	deviceTransfersDefaultLimit = 100
+	deviceTransfersMaxLimit     = 1000
+)
+
+// deviceTransferRequest перемещает устройства в локацию toLocationId одним
+// документом: либо переезжают все, либо ни одно.
+type deviceTransferRequest struct {
+	DeviceIds      []int64 `json:"deviceIds"`
+	ToLocationId   int64   `json:"toLocationId"`
+	Reason         string  `json:"reason"`
+	DocumentNumber string  `json:"documentNumber"`
+}
+
+// deviceTransferError — ошибка перемещения; DeviceId — устройство, из-за
+// которого документ не проведён.
+type deviceTransferError struct {
+	Error    string `json:"error"`
+	DeviceId int64  `json:"deviceId"`
+}
+
+type deviceTransferItem struct {
+	Id             int64  `json:"id"`
+	ToLocationId   *int64 `json:"toLocationId"`
+	ToLocationName string `json:"toLocationName"`
+	Reason         string `json:"reason"`
+	DocumentNumber string `json:"documentNumber"`
+	Actor          string `json:"actor"`
+	CreatedAt      string `json:"createdAt"`
+	DeviceCount    int64  `json:"deviceCount"`
+}
+
+// deviceTransferDevice — строка документа по снимку устройства на момент
+// перемещения; Id пустой, если устройство окончательно удалено.
+type deviceTransferDevice struct {
+	Id               *int64 `json:"id"`
+	Label            string `json:"label"`
+	Hostname         string `json:"hostname"`
+	VendorName       string `json:"vendorName"`
+	ModelName        string `json:"modelName"`
+	SerialNumber     string `json:"serialNumber"`
+	InventoryNumber  string `json:"inventoryNumber"`
+	FromLocationId   *int64 `json:"fromLocationId"`
+	FromLocationName string `json:"fromLocationName"`
+}
+
+type deviceTransferDetails struct {
+	deviceTransferItem
+	Devices []deviceTransferDevice `json:"devices"`
+}
+
+func deviceTransferItemFromRow(row store.DeviceTransferRow) deviceTransferItem {
+	return deviceTransferItem{
+		Id:             row.ID,
+		ToLocationId:   row.ToLocationID,
+		ToLocationName: row.ToLocationName,
+		Reason:         row.Reason,
+		DocumentNumber: row.DocumentNumber,
+		Actor:          row.Actor,
+		CreatedAt:      row.CreatedAt.UTC().Format(time.RFC3339),
+		DeviceCount:    row.DeviceCount,
+	}
+}
+
+// recordDeviceMove пишет в журнал перемещений смену локации одного устройства
+// при обычном редактировании, без номера документа.
+func recordDeviceMove(ctx context.Context, q *store.Queries, deviceID int64, from, to *int64, reason string) error {
+	if sameLocation(from, to) {
+		return nil
+	}
+	transferID, _, err := q.CreateDeviceTransfer(ctx, to, nullIfEmpty(reason), nil, authUsername(ctx))
+	if err != nil {
+		return err
+	}
+	return q.AddDeviceTransferItem(ctx, transferID, deviceID, from)
+}
+
+// loadDeviceTransfer читает документ перемещения вместе с устройствами.
+func loadDeviceTransfer(ctx context.Context, q *store.Queries, id int64) (deviceTransferDetails, error) {
+	row, err := q.GetDeviceTransfer(ctx, id)
+	if err != nil {
+		return deviceTransferDetails{}, err
+	}
+	items, err := q.ListDeviceTransferItems(ctx, id)
+	if err != nil {
+		return deviceTransferDetails{}, err
+	}
+
+	resp := deviceTransferDetails{deviceTransferItem: deviceTransferItemFromRow(row), Devices: make([]deviceTransferDevice, 0, len(items))}
+	for _, it := range items {
+		resp.Devices = append(resp.Devices, deviceTransferDevice{
+			Id:               it.DeviceID,
+			Label:            deviceLabel(it.Hostname, it.VendorName, it.ModelName, it.SerialNumber),
+			Hostname:         it.Hostname,
+			VendorName:       it.VendorName,
+			ModelName:        it.ModelName,
+			SerialNumber:     it.SerialNumber,
+			InventoryNumber:  it.InventoryNumber,
+			FromLocationId:   it.FromLocationID,
+			FromLocationName: it.FromLocationName,
+		})
+	}
+	return resp, nil
+}
+
+// handleDeviceTransfersCreate перемещает одно или несколько устройств в
+// локацию одной транзакцией и заводит документ перемещения. Устройства
+// блокируются в порядке id; место в стойке при переезде освобождается.
+func (a *app) handleDeviceTransfersCreate(w http.ResponseWriter, r *http.Request) {
+	var req deviceTransferRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	if req.ToLocationId <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "location_required"})
+		return
+	}
+	if len(req.DeviceIds) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "devices_required"})
+		return
+	}
+	if len(req.DeviceIds) > transferMaxDevices {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "too_many_devices"})
+		return
+	}
+	documentNumber := strings.TrimSpace(req.DocumentNumber)
+	if len([]rune(documentNumber)) > transferDocumentNumberMax {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "document_number_too_long"})
+		return
+	}
+
+	ids := make([]int64, 0, len(req.DeviceIds))
+	seen := make(map[int64]bool, len(req.DeviceIds))
+	for _, id := range req.DeviceIds {
+		if id <= 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_device_id"})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	exists, err := qtx.LocationExists(ctx, req.ToLocationId)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "location_not_found"})
+		return
+	}
+
+	to := req.ToLocationId
+	transferID, _, err := qtx.CreateDeviceTransfer(ctx, &to, nullIfEmpty(req.Reason), nullIfEmpty(documentNumber), authUsername(ctx))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	for _, id := range ids {
+		before, err := qtx.GetDeviceSnapshot(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusBadRequest, deviceTransferError{Error: "device_not_found", DeviceId: id})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if sameLocation(before.LocationID, &to) {
+			writeJSON(w, http.StatusConflict, deviceTransferError{Error: "already_at_location", DeviceId: id})
+			return
+		}
+
+		if err := qtx.AddDeviceTransferItem(ctx, transferID, id, before.LocationID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if _, err := qtx.PatchDevice(ctx, id, &to, before.Status, nullIfEmpty(before.Description)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		after, err := qtx.GetDeviceSnapshot(ctx, id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if err := recordDeviceHistory(ctx, qtx, id, deviceHistoryUpdate, &before, &after); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+	}
+
+	resp, err := loadDeviceTransfer(ctx, qtx, transferID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (a *app) handleDeviceTransfersList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.DeviceTransferFilter{Limit: deviceTransfersDefaultLimit}
+	var err error
+	if f.DeviceID, err = parseOptionalID(q.Get("device_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_device_id"})
+		return
+	}
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_location_id"})
+		return
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > deviceTransfersMaxLimit {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_limit"})
+			return
+		}
+		f.Limit = int32(limit)
+	}
+	if v := strings.TrimSpace(q.Get("offset")); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_offset"})
+			return
+		}
+		f.Offset = int32(offset)
+	}
+
+	rows, err := a.st.ListDeviceTransfers(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]deviceTransferItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, deviceTransferItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleTransfersGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	resp, err := loadDeviceTransfer(r.Context(), a.st, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleTransfersAct отдаёт акт перемещения в PDF для печати и подписей.
+func (a *app) handleTransfersAct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	t, err := loadDeviceTransfer(r.Context(), a.st, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := renderTransferAct(t).WriteTo(&buf); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "render_error"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="transfer-act-%d.pdf"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
+
+// Колонки таблицы акта: ширина в миллиметрах и значение из строки.
+var transferActColumns = []struct {
+	title string
+	width float64
+	value func(i int, d deviceTransferDevice) string
+}{
+	{"№", 8, func(i int, _ deviceTransferDevice) string { return strconv.Itoa(i + 1) }},
+	{"Устройство", 62, func(_ int, d deviceTransferDevice) string { return d.Label }},
+	{"Серийный номер", 34, func(_ int, d deviceTransferDevice) string { return d.SerialNumber }},
+	{"Инв. номер", 30, func(_ int, d deviceTransferDevice) string { return d.InventoryNumber }},
+	{"Откуда", 36, func(_ int, d deviceTransferDevice) string { return d.FromLocationName }},
+}
+
+// renderTransferAct раскладывает акт на страницы A4: шапка с реквизитами
+// документа, таблица устройств и блок подписей сдавшего и принявшего.
+func renderTransferAct(t deviceTransferDetails) *pdf.Document {
+	const (
+		margin   = 20 * pdf.MM
+		rowH     = 6 * pdf.MM
+		fontSize = 9.0
+		pad      = 1.5 * pdf.MM
+	)
+	number := t.DocumentNumber
+	if number == "" {
+		number = strconv.FormatInt(t.Id, 10)
+	}
+	createdAt, _ := time.Parse(time.RFC3339, t.CreatedAt)
+	to := t.ToLocationName
+	if to == "" {
+		to = "—"
+	}
+
+	doc := pdf.New("Акт приёма-передачи оборудования № " + number)
+	width := pdf.PageWidth - 2*margin
+
+	var page *pdf.Page
+	var y float64
+	pageNo := 0
+	tableHeader := func() {
+		x := margin
+		for _, c := range transferActColumns {
+			w := c.width * pdf.MM
+			page.StrokeRect(x, y, w, rowH, 0.5)
+			page.Text(x+pad, y+rowH-2*pdf.MM, fontSize, true, c.title)
+			x += w
+		}
+		y += rowH
+	}
+	newPage := func() {
+		page = doc.AddPage()
+		pageNo++
+		page.Text(margin, pdf.PageHeight-10*pdf.MM, 8, false, fmt.Sprintf("Акт № %s, страница %d", number, pageNo))
+		y = margin
+	}
+
+	newPage()
+	y += 8 * pdf.MM
+	title := "АКТ ПРИЁМА-ПЕРЕДАЧИ ОБОРУДОВАНИЯ № " + number
+	page.Text((pdf.PageWidth-pdf.TextWidth(title, 14, true))/2, y, 14, true, title)
+	y += 12 * pdf.MM
+
+	fields := [][2]string{
+		{"Дата", createdAt.UTC().Format("02.01.2006 15:04 UTC")},
+		{"Куда", to},
+		{"Основание", t.Reason},
+		{"Оформил", t.Actor},
+		{"Устройств", strconv.FormatInt(t.DeviceCount, 10)},
+	}
+	for _, f := range fields {
+		page.Text(margin, y, 10, true, f[0]+":")
+		lines := pdf.Wrap(f[1], 10, false, width-35*pdf.MM)
+		for _, line := range lines {
+			page.Text(margin+35*pdf.MM, y, 10, false, line)
+			y += 5 * pdf.MM
+		}
+	}
+	y += 5 * pdf.MM
+
+	tableHeader()
+	for i, d := range t.Devices {
+		if y+rowH > pdf.PageHeight-margin {
+			newPage()
+			tableHeader()
+		}
+		x := margin
+		for _, c := range transferActColumns {
+			w := c.width * pdf.MM
+			page.StrokeRect(x, y, w, rowH, 0.5)
+			page.Text(x+pad, y+rowH-2*pdf.MM, fontSize, false, pdf.Fit(c.value(i, d), fontSize, false, w-2*pad))
+			x += w
+		}
+		y += rowH
+	}
+
+	// Блок подписей не разрывается между страницами.
+	const signaturesH = 45 * pdf.MM
+	if y+signaturesH > pdf.PageHeight-margin {
+		newPage()
+	}
+	y += 15 * pdf.MM
+	for _, role := range []string{"Сдал", "Принял"} {
+		page.Text(margin, y, 10, true, role+":")
+		page.Line(margin+35*pdf.MM, y+pdf.MM, margin+95*pdf.MM, y+pdf.MM, 0.5)
+		page.Line(margin+100*pdf.MM, y+pdf.MM, margin+width, y+pdf.MM, 0.5)
+		page.Text(margin+35*pdf.MM, y+4.5*pdf.MM, 7, false, "подпись")
+		page.Text(margin+100*pdf.MM, y+4.5*pdf.MM, 7, false, "ФИО, должность, дата")
+		y += 15 * pdf.MM
+	}
+
+	return doc
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"telecombase/server/internal/store"
+)
+
+const (
+	workOrderTypeRepair             = "repair"
+	workOrderTypePlannedMaintenance = "planned_maintenance"
+	workOrderTypeUpgrade            = "upgrade"
+
+	workOrderStateOpen       = "open"
+	workOrderStateInProgress = "in_progress"
+	workOrderStateOnHold     = "on_hold"
+	workOrderStateDone       = "done"
+	workOrderStateCancelled  = "cancelled"
+
+	workOrdersDefaultLimit = 100
+	workOrdersMaxLimit     = 1000
+	workOrderTitleMaxLen   = 200
+)
+
+// workOrderStateTransitions — допустимые смены состояния заявки. done и
+// cancelled — конечные: закрытую заявку не открывают, а заводят новую.
+var workOrderStateTransitions = map[string][]string{
+	workOrderStateOpen:       {workOrderStateInProgress, workOrderStateOnHold, workOrderStateDone, workOrderStateCancelled},
+	workOrderStateInProgress: {workOrderStateOnHold, workOrderStateDone, workOrderStateCancelled},
+	workOrderStateOnHold:     {workOrderStateInProgress, workOrderStateDone, workOrderStateCancelled},
+	workOrderStateDone:       {},
+	workOrderStateCancelled:  {},
+}
+
+type workOrderCreateRequest struct {
+	DeviceId    int64  `json:"deviceId"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Notes       string `json:"notes"`
+	AssigneeId  *int64 `json:"assigneeId"`
+	ScheduledAt string `json:"scheduledAt"`
+	// MoveToMaintenance переводит устройство в maintenance при открытии заявки.
+	MoveToMaintenance bool `json:"moveToMaintenance"`
+}
+
+// workOrderUpdateRequest — правка заявки; отсутствующие поля не меняются,
+// null в assigneeId и scheduledAt очищает значение.
+type workOrderUpdateRequest struct {
+	Type        *string          `json:"type"`
+	Title       *string          `json:"title"`
+	Notes       *string          `json:"notes"`
+	AssigneeId  optionalID       `json:"assigneeId"`
+	ScheduledAt optional[string] `json:"scheduledAt"`
+}
+
+type workOrderStateRequest struct {
+	State string `json:"state"`
+	// Resolution — итог работ; пустой оставляет прежний.
+	Resolution string `json:"resolution"`
+	// RestoreDeviceStatus при закрытии возвращает устройство из maintenance в
+	// статус, который был до открытия заявки.
+	RestoreDeviceStatus bool `json:"restoreDeviceStatus"`
+}
+
+// workOrderItem — заявка; устройство описано снимком на момент открытия,
+// DeviceId пустой, если устройство окончательно удалено.
+type workOrderItem struct {
+	Id              int64   `json:"id"`
+	DeviceId        *int64  `json:"deviceId"`
+	DeviceLabel     string  `json:"deviceLabel"`
+	SerialNumber    string  `json:"serialNumber"`
+	InventoryNumber string  `json:"inventoryNumber"`
+	Type            string  `json:"type"`
+	State           string  `json:"state"`
+	Title           string  `json:"title"`
+	Notes           string  `json:"notes"`
+	Resolution      string  `json:"resolution"`
+	AssigneeId      *int64  `json:"assigneeId"`
+	Assignee        string  `json:"assignee"`
+	ScheduledAt     *string `json:"scheduledAt"`
+	ReturnStatus    string  `json:"returnStatus"`
+	CreatedBy       string  `json:"createdBy"`
+	CreatedAt       string  `json:"createdAt"`
+	UpdatedAt       string  `json:"updatedAt"`
+	StartedAt       *string `json:"startedAt"`
+	ClosedAt        *string `json:"closedAt"`
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
+
+func workOrderItemFromRow(row store.WorkOrderRow) workOrderItem {
+	return workOrderItem{
+		Id:              row.ID,
+		DeviceId:        row.DeviceID,
+		DeviceLabel:     deviceLabel(row.DeviceHostname, row.VendorName, row.ModelName, row.SerialNumber),
+		SerialNumber:    row.SerialNumber,
+		InventoryNumber: row.InventoryNumber,
+		Type:            row.Type,
+		State:           row.State,
+		Title:           row.Title,
+		Notes:           row.Notes,
+		Resolution:      row.Resolution,
+		AssigneeId:      row.AssigneeID,
+		Assignee:        row.Assignee,
+		ScheduledAt:     formatOptionalTime(row.ScheduledAt),
+		ReturnStatus:    row.ReturnStatus,
+		CreatedBy:       row.CreatedBy,
+		CreatedAt:       row.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:       row.UpdatedAt.UTC().Format(time.RFC3339),
+		StartedAt:       formatOptionalTime(row.StartedAt),
+		ClosedAt:        formatOptionalTime(row.ClosedAt),
+	}
+}
+
+func normalizeWorkOrderType(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case workOrderTypeRepair, workOrderTypePlannedMaintenance, workOrderTypeUpgrade:
+		return s, true
+	}
+	return "", false
+}
+
+func normalizeWorkOrderState(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if _, ok := workOrderStateTransitions[s]; !ok {
+		return "", false
+	}
+	return s, true
+}
+
+func workOrderStateTransitionAllowed(from, to string) bool {
+	for _, s := range workOrderStateTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func workOrderClosed(state string) bool {
+	return state == workOrderStateDone || state == workOrderStateCancelled
+}
+
+func parseWorkOrderTime(v string) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// validateWorkOrderParams нормализует тип и заголовок; возвращает код ошибки API.
+func validateWorkOrderParams(p *store.WorkOrderParams) string {
+	t, ok := normalizeWorkOrderType(p.Type)
+	if !ok {
+		return "invalid_type"
+	}
+	p.Type = t
+	title := strings.TrimSpace(p.Title)
+	if title == "" {
+		return "title_required"
+	}
+	if len([]rune(title)) > workOrderTitleMaxLen {
+		return "title_too_long"
+	}
+	p.Title = title
+	return ""
+}
+
+// checkAssignee проверяет, что исполнитель — существующий пользователь.
+func checkAssignee(ctx context.Context, q *store.Queries, id *int64) (bool, error) {
+	if id == nil {
+		return true, nil
+	}
+	return q.UserExists(ctx, *id)
+}
+
+// parseWorkOrderFilter разбирает фильтры списка заявок. state можно передать
+// несколько раз или через запятую.
+func parseWorkOrderFilter(q url.Values) (store.WorkOrderFilter, string) {
+	f := store.WorkOrderFilter{Limit: workOrdersDefaultLimit}
+	var err error
+
+	if f.DeviceID, err = parseOptionalID(q.Get("device_id")); err != nil {
+		return f, "invalid_device_id"
+	}
+	if f.AssigneeID, err = parseOptionalID(q.Get("assignee_id")); err != nil {
+		return f, "invalid_assignee_id"
+	}
+	if f.LocationID, err = parseOptionalID(q.Get("location_id")); err != nil {
+		return f, "invalid_location_id"
+	}
+	for _, v := range q["state"] {
+		for _, part := range strings.Split(v, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			state, ok := normalizeWorkOrderState(part)
+			if !ok {
+				return f, "invalid_state"
+			}
+			f.States = append(f.States, state)
+		}
+	}
+	if v := strings.TrimSpace(q.Get("type")); v != "" {
+		t, ok := normalizeWorkOrderType(v)
+		if !ok {
+			return f, "invalid_type"
+		}
+		f.Type = &t
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > workOrdersMaxLimit {
+			return f, "invalid_limit"
+		}
+		f.Limit = int32(limit)
+	}
+	if v := strings.TrimSpace(q.Get("offset")); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return f, "invalid_offset"
+		}
+		f.Offset = int32(offset)
+	}
+	return f, ""
+}
+
+func (a *app) handleWorkOrdersList(w http.ResponseWriter, r *http.Request) {
+	f, code := parseWorkOrderFilter(r.URL.Query())
+	if code != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+		return
+	}
+
+	rows, err := a.st.ListWorkOrders(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]workOrderItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, workOrderItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleWorkOrdersGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	row, err := a.st.GetWorkOrder(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, workOrderItemFromRow(row))
+}
+
+// handleDeviceWorkOrdersList отдаёт историю обслуживания устройства: все его
+// заявки, новые сначала.
+func (a *app) handleDeviceWorkOrdersList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	if _, err := a.st.GetDeviceByID(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	rows, err := a.st.ListWorkOrders(r.Context(), store.WorkOrderFilter{DeviceID: &id, Limit: workOrdersMaxLimit})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	items := make([]workOrderItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, workOrderItemFromRow(row))
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *app) handleWorkOrdersCreate(w http.ResponseWriter, r *http.Request) {
+	var req workOrderCreateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	if req.DeviceId <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_device_id"})
+		return
+	}
+	p := store.WorkOrderParams{Type: req.Type, Title: req.Title, Notes: nullIfEmpty(req.Notes), AssigneeID: req.AssigneeId}
+	if code := validateWorkOrderParams(&p); code != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+		return
+	}
+	scheduledAt, err := parseWorkOrderTime(req.ScheduledAt)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_scheduled_at"})
+		return
+	}
+	p.ScheduledAt = scheduledAt
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	if _, err := qtx.GetDeviceSnapshot(ctx, req.DeviceId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "device_not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if ok, err := checkAssignee(ctx, qtx, p.AssigneeID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	} else if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "assignee_not_found"})
+		return
+	}
+
+	actor := authUsername(ctx)
+	id, err := qtx.CreateWorkOrder(ctx, req.DeviceId, p, nil, actor)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if req.MoveToMaintenance {
+		reason := fmt.Sprintf("work order #%d", id)
+		from, _, ok, err := transitionDeviceStatus(ctx, qtx, req.DeviceId, deviceStatusMaintenance, reason, actor)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		switch {
+		case ok:
+			if err := qtx.SetWorkOrderReturnStatus(ctx, id, from); err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+		case from == deviceStatusMaintenance:
+			// Устройство уже обслуживается по другой заявке: статус вернёт она,
+			// а если закроется раньше — передаст статус возврата этой.
+		default:
+			writeTransitionConflict(w, from, deviceStatusMaintenance)
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, idResponse{Id: id})
+}
+
+func (a *app) handleWorkOrdersUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req workOrderUpdateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	current, err := qtx.GetWorkOrder(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if workOrderClosed(current.State) {
+		writeJSON(w, http.StatusConflict, apiError{Error: "work_order_closed"})
+		return
+	}
+
+	p := store.WorkOrderParams{
+		Type:        current.Type,
+		Title:       current.Title,
+		Notes:       nullIfEmpty(current.Notes),
+		AssigneeID:  current.AssigneeID,
+		ScheduledAt: current.ScheduledAt,
+	}
+	if req.Type != nil {
+		p.Type = *req.Type
+	}
+	if req.Title != nil {
+		p.Title = *req.Title
+	}
+	if req.Notes != nil {
+		p.Notes = nullIfEmpty(*req.Notes)
+	}
+	if req.AssigneeId.Set {
+		p.AssigneeID = req.AssigneeId.Value
+	}
+	if req.ScheduledAt.Set {
+		p.ScheduledAt = nil
+		if req.ScheduledAt.Value != nil {
+			if p.ScheduledAt, err = parseWorkOrderTime(*req.ScheduledAt.Value); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_scheduled_at"})
+				return
+			}
+		}
+	}
+	if code := validateWorkOrderParams(&p); code != "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: code})
+		return
+	}
+	if req.AssigneeId.Set {
+		if ok, err := checkAssignee(ctx, qtx, p.AssigneeID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		} else if !ok {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "assignee_not_found"})
+			return
+		}
+	}
+
+	if err := qtx.UpdateWorkOrder(ctx, id, p); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, idResponse{Id: id})
+}
+
+// handleWorkOrdersState меняет состояние заявки. При закрытии с
+// restoreDeviceStatus устройство переходит из maintenance в статус, из которого
+// его забрала заявка; переход пишется в журнал с причиной «work order #N».
+// Пока у устройства есть другие незакрытые заявки, статус не возвращается
+// (409), а при закрытии статус возврата переходит к одной из них.
+func (a *app) handleWorkOrdersState(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_id"})
+		return
+	}
+
+	var req workOrderStateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_json"})
+		return
+	}
+	state, ok := normalizeWorkOrderState(req.State)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid_state"})
+		return
+	}
+	if req.RestoreDeviceStatus && !workOrderClosed(state) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "restore_requires_close"})
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	defer tx.Rollback(ctx)
+	qtx := a.st.WithTx(tx)
+
+	current, err := qtx.GetWorkOrder(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if !workOrderStateTransitionAllowed(current.State, state) {
+		allowed := workOrderStateTransitions[current.State]
+		if allowed == nil {
+			allowed = []string{}
+		}
+		writeJSON(w, http.StatusConflict, deviceTransitionConflict{
+			Error:   "invalid_state_transition",
+			From:    current.State,
+			To:      state,
+			Allowed: allowed,
+		})
+		return
+	}
+
+	// У заявки удалённого устройства нет ни устройства, ни других его заявок.
+	if workOrderClosed(state) && current.DeviceID != nil {
+		deviceID := *current.DeviceID
+		// Блокировка устройства не даёт параллельно завести по нему заявку.
+		if _, err := qtx.GetDeviceSnapshot(ctx, deviceID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		others, err := qtx.CountOtherOpenWorkOrders(ctx, deviceID, id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if others > 0 && req.RestoreDeviceStatus {
+			writeJSON(w, http.StatusConflict, apiError{Error: "device_has_open_work_orders"})
+			return
+		}
+		if others > 0 && current.ReturnStatus != "" {
+			if err := qtx.HandOverWorkOrderReturnStatus(ctx, deviceID, id, current.ReturnStatus); err != nil {
+				writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+				return
+			}
+		}
+	}
+
+	if err := qtx.SetWorkOrderState(ctx, id, state, nullIfEmpty(req.Resolution)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	if req.RestoreDeviceStatus {
+		if current.ReturnStatus == "" {
+			writeJSON(w, http.StatusConflict, apiError{Error: "no_return_status"})
+			return
+		}
+		if current.DeviceID == nil {
+			writeJSON(w, http.StatusConflict, apiError{Error: "device_not_found"})
+			return
+		}
+		reason := fmt.Sprintf("work order #%d %s", id, state)
+		from, _, ok, err := transitionDeviceStatus(ctx, qtx, *current.DeviceID, current.ReturnStatus, reason, authUsername(ctx))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeJSON(w, http.StatusConflict, apiError{Error: "device_not_found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+			return
+		}
+		if from != deviceStatusMaintenance {
+			writeJSON(w, http.StatusConflict, deviceTransitionConflict{
+				Error:   "device_not_in_maintenance",
+				From:    from,
+				To:      current.ReturnStatus,
+				Allowed: []string{},
+			})
+			return
+		}
+		if !ok {
+			writeTransitionConflict(w, from, current.ReturnStatus)
+			return
+		}
+	}
+
+	row, err := qtx.GetWorkOrder(ctx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "db_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, workOrderItemFromRow(row))
+}
@@ -0,0 +1,70 @@
+-- name: UpsertAttachmentBlob :one
+-- Блокирует строку содержимого до конца транзакции, чтобы очистка не удалила
+-- его между загрузкой и записью вложения. inserted — содержимое новое и файл
+-- нужно записать в хранилище под $4.
+INSERT INTO attachment_blobs(sha256, size_bytes, mime_type, storage_key)
+VALUES($1, $2, $3, $4)
+ON CONFLICT (sha256) DO UPDATE SET sha256 = EXCLUDED.sha256
+RETURNING storage_key, (xmax = 0) AS inserted;
+
+-- name: CreateDeviceAttachment :one
+-- Повторная загрузка того же файла к устройству не создаёт вложения.
+INSERT INTO device_attachments(device_id, sha256, filename, uploaded_by)
+VALUES($1, $2, $3, $4)
+ON CONFLICT (device_id, sha256) DO NOTHING
+RETURNING id;
+
+-- name: FindDeviceAttachmentBySHA :one
+SELECT id
+FROM device_attachments
+WHERE device_id = $1
+  AND sha256 = $2;
+
+-- name: ListDeviceAttachments :many
+SELECT a.id,
+       a.device_id,
+       a.filename,
+       b.mime_type,
+       b.size_bytes,
+       a.sha256,
+       b.storage_key,
+       a.uploaded_by,
+       a.created_at
+FROM device_attachments a
+JOIN attachment_blobs b ON b.sha256 = a.sha256
+WHERE a.device_id = $1
+ORDER BY a.created_at DESC, a.id DESC;
+
+-- name: GetDeviceAttachment :one
+SELECT a.id,
+       a.device_id,
+       a.filename,
+       b.mime_type,
+       b.size_bytes,
+       a.sha256,
+       b.storage_key,
+       a.uploaded_by,
+       a.created_at
+FROM device_attachments a
+JOIN attachment_blobs b ON b.sha256 = a.sha256
+WHERE a.id = $1
+  AND a.device_id = $2;
+
+-- name: DeleteDeviceAttachment :exec
+DELETE FROM device_attachments
+WHERE id = $1
+  AND device_id = $2;
+
+-- name: DeleteOrphanAttachmentBlobs :many
+-- Содержимое, на которое не ссылается ни одно вложение. Строки, заблокированные
+-- идущей загрузкой, пропускаются: их проверит следующая очистка.
+WITH orphan AS (
+    SELECT b.sha256
+    FROM attachment_blobs b
+    WHERE NOT EXISTS(SELECT 1 FROM device_attachments a WHERE a.sha256 = b.sha256)
+    FOR UPDATE SKIP LOCKED
+)
+DELETE FROM attachment_blobs b
+USING orphan o
+WHERE b.sha256 = o.sha256
+RETURNING b.storage_key;
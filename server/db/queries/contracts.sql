@@ -0,0 +1,166 @@
+-- name: ListContracts :many
+-- $1 — поиск по номеру и поставщику, $2 — устройство, $3 — состояние
+-- (future/active/expired) на текущую дату.
+SELECT c.id,
+       c.contract_number,
+       c.supplier,
+       c.kind,
+       COALESCE(c.coverage_level, '') AS coverage_level,
+       to_char(c.starts_on, 'YYYY-MM-DD') AS starts_on,
+       to_char(c.ends_on, 'YYYY-MM-DD') AS ends_on,
+       CASE WHEN c.starts_on > current_date THEN 'future'
+            WHEN c.ends_on < current_date THEN 'expired'
+            ELSE 'active' END AS state,
+       COALESCE(c.note, '') AS note,
+       c.created_by,
+       c.created_at,
+       (SELECT count(*)
+        FROM contract_devices cd
+        JOIN devices d ON d.id = cd.device_id
+        WHERE cd.contract_id = c.id AND d.deleted_at IS NULL) AS device_count
+FROM contracts c
+WHERE ($1::text = '' OR c.contract_number ILIKE '%' || $1 || '%' OR c.supplier ILIKE '%' || $1 || '%')
+  AND ($2::bigint IS NULL OR EXISTS(SELECT 1 FROM contract_devices cd WHERE cd.contract_id = c.id AND cd.device_id = $2))
+  AND ($3::text IS NULL
+       OR ($3 = 'future' AND c.starts_on > current_date)
+       OR ($3 = 'active' AND current_date BETWEEN c.starts_on AND c.ends_on)
+       OR ($3 = 'expired' AND c.ends_on < current_date))
+ORDER BY c.ends_on DESC, c.id DESC;
+
+-- name: GetContract :one
+SELECT c.id,
+       c.contract_number,
+       c.supplier,
+       c.kind,
+       COALESCE(c.coverage_level, '') AS coverage_level,
+       to_char(c.starts_on, 'YYYY-MM-DD') AS starts_on,
+       to_char(c.ends_on, 'YYYY-MM-DD') AS ends_on,
+       CASE WHEN c.starts_on > current_date THEN 'future'
+            WHEN c.ends_on < current_date THEN 'expired'
+            ELSE 'active' END AS state,
+       COALESCE(c.note, '') AS note,
+       c.created_by,
+       c.created_at,
+       (SELECT count(*)
+        FROM contract_devices cd
+        JOIN devices d ON d.id = cd.device_id
+        WHERE cd.contract_id = c.id AND d.deleted_at IS NULL) AS device_count
+FROM contracts c
+WHERE c.id = $1;
+
+-- name: CreateContract :one
+INSERT INTO contracts(contract_number, supplier, kind, coverage_level, starts_on, ends_on, note, created_by)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id;
+
+-- name: UpdateContract :exec
+UPDATE contracts
+SET contract_number = $2,
+    supplier = $3,
+    kind = $4,
+    coverage_level = $5,
+    starts_on = $6,
+    ends_on = $7,
+    note = $8
+WHERE id = $1;
+
+-- name: DeleteContract :exec
+DELETE FROM contracts
+WHERE id = $1;
+
+-- name: ListContractDevices :many
+SELECT d.id,
+       COALESCE(d.hostname, '') AS hostname,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       COALESCE(d.inventory_number, '') AS inventory_number,
+       COALESCE(l.name, '') AS location_name,
+       d.status
+FROM contract_devices cd
+JOIN devices d ON d.id = cd.device_id
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN locations l ON l.id = d.location_id
+WHERE cd.contract_id = $1
+  AND d.deleted_at IS NULL
+ORDER BY d.id;
+
+-- name: ListMissingDeviceIDs :many
+-- id из $1, которых нет среди устройств вне корзины.
+SELECT u.id
+FROM unnest($1::bigint[]) AS u(id)
+WHERE NOT EXISTS(SELECT 1 FROM devices d WHERE d.id = u.id AND d.deleted_at IS NULL)
+ORDER BY u.id;
+
+-- name: DeleteContractDevices :exec
+-- Устройства в корзине остаются привязанными: после восстановления покрытие
+-- не теряется.
+DELETE FROM contract_devices cd
+USING devices d
+WHERE cd.contract_id = $1
+  AND d.id = cd.device_id
+  AND d.deleted_at IS NULL;
+
+-- name: AddContractDevices :exec
+INSERT INTO contract_devices(contract_id, device_id)
+SELECT $1, unnest($2::bigint[])
+ON CONFLICT DO NOTHING;
+
+-- name: ListDeviceContracts :many
+SELECT c.id,
+       c.contract_number,
+       c.supplier,
+       c.kind,
+       COALESCE(c.coverage_level, '') AS coverage_level,
+       to_char(c.starts_on, 'YYYY-MM-DD') AS starts_on,
+       to_char(c.ends_on, 'YYYY-MM-DD') AS ends_on,
+       CASE WHEN c.starts_on > current_date THEN 'future'
+            WHEN c.ends_on < current_date THEN 'expired'
+            ELSE 'active' END AS state,
+       COALESCE(c.note, '') AS note,
+       c.created_by,
+       c.created_at,
+       0::bigint AS device_count
+FROM contract_devices cd
+JOIN contracts c ON c.id = cd.contract_id
+WHERE cd.device_id = $1
+ORDER BY c.ends_on DESC, c.id DESC;
+
+-- name: ListExpiringCoverage :many
+-- Устройства, чей контракт заканчивается в ближайшие $1 дней (включая
+-- сегодня) и не продлён: нет другого контракта устройства, который
+-- продолжает покрытие после окончания этого.
+SELECT c.id,
+       c.contract_number,
+       c.supplier,
+       c.kind,
+       COALESCE(c.coverage_level, '') AS coverage_level,
+       to_char(c.ends_on, 'YYYY-MM-DD') AS ends_on,
+       c.ends_on - current_date AS days_left,
+       d.id,
+       COALESCE(d.hostname, '') AS hostname,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       COALESCE(d.inventory_number, '') AS inventory_number,
+       COALESCE(l.name, '') AS location_name,
+       d.status
+FROM contracts c
+JOIN contract_devices cd ON cd.contract_id = c.id
+JOIN devices d ON d.id = cd.device_id
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN locations l ON l.id = d.location_id
+WHERE c.ends_on BETWEEN current_date AND current_date + $1::integer
+  AND d.deleted_at IS NULL
+  AND NOT EXISTS(
+      SELECT 1
+      FROM contract_devices cd2
+      JOIN contracts c2 ON c2.id = cd2.contract_id
+      WHERE cd2.device_id = d.id
+        AND c2.id <> c.id
+        AND c2.starts_on <= c.ends_on + 1
+        AND c2.ends_on > c.ends_on
+  )
+ORDER BY c.ends_on, c.id, d.id;
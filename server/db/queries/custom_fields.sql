@@ -0,0 +1,87 @@
+-- name: ListCustomFieldDefinitions :many
+-- $1 — область ('device', 'model', 'vendor', 'location'); пустая строка — все.
+SELECT id,
+       scope,
+       key,
+       label,
+       field_type,
+       required,
+       enum_values,
+       COALESCE(device_type, '') AS device_type,
+       sort_order
+FROM custom_field_definitions
+WHERE $1::text = '' OR scope = $1
+ORDER BY scope, sort_order, key;
+
+-- name: GetCustomFieldDefinition :one
+SELECT id,
+       scope,
+       key,
+       label,
+       field_type,
+       required,
+       enum_values,
+       COALESCE(device_type, '') AS device_type,
+       sort_order
+FROM custom_field_definitions
+WHERE id = $1;
+
+-- name: CreateCustomFieldDefinition :one
+INSERT INTO custom_field_definitions(scope, key, label, field_type, required, enum_values, device_type, sort_order)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id;
+
+-- name: UpdateCustomFieldDefinition :exec
+-- Область, ключ и тип не меняются: иначе сохранённые значения перестанут им соответствовать.
+UPDATE custom_field_definitions
+SET label = $2,
+    required = $3,
+    enum_values = $4,
+    device_type = $5,
+    sort_order = $6
+WHERE id = $1;
+
+-- name: DeleteCustomFieldDefinition :exec
+DELETE FROM custom_field_definitions
+WHERE id = $1;
+
+-- name: GetModelDeviceType :one
+-- Для несуществующей модели возвращает пустую строку: ошибку внешнего ключа
+-- вернёт сама запись устройства.
+SELECT COALESCE((SELECT device_type FROM models WHERE id = $1), '');
+
+-- name: GetDeviceCustomFields :one
+SELECT custom_fields FROM devices WHERE id = $1 FOR UPDATE;
+
+-- name: GetModelCustomFields :one
+SELECT custom_fields FROM models WHERE id = $1 FOR UPDATE;
+
+-- name: GetVendorCustomFields :one
+SELECT custom_fields FROM vendors WHERE id = $1 FOR UPDATE;
+
+-- name: GetLocationCustomFields :one
+SELECT custom_fields FROM locations WHERE id = $1 FOR UPDATE;
+
+-- name: SetDeviceCustomFields :exec
+UPDATE devices SET custom_fields = $2 WHERE id = $1;
+
+-- name: SetModelCustomFields :exec
+UPDATE models SET custom_fields = $2 WHERE id = $1;
+
+-- name: SetVendorCustomFields :exec
+UPDATE vendors SET custom_fields = $2 WHERE id = $1;
+
+-- name: SetLocationCustomFields :exec
+UPDATE locations SET custom_fields = $2 WHERE id = $1;
+
+-- name: RemoveDeviceCustomFieldKey :exec
+UPDATE devices SET custom_fields = custom_fields - $1::text WHERE custom_fields ? $1::text;
+
+-- name: RemoveModelCustomFieldKey :exec
+UPDATE models SET custom_fields = custom_fields - $1::text WHERE custom_fields ? $1::text;
+
+-- name: RemoveVendorCustomFieldKey :exec
+UPDATE vendors SET custom_fields = custom_fields - $1::text WHERE custom_fields ? $1::text;
+
+-- name: RemoveLocationCustomFieldKey :exec
+UPDATE locations SET custom_fields = custom_fields - $1::text WHERE custom_fields ? $1::text;
@@ -0,0 +1,41 @@
+-- name: GetDeviceSnapshot :one
+SELECT d.id,
+       d.model_id,
+       v.name || ' ' || m.name AS model_name,
+       d.location_id,
+       COALESCE(l.name, '') AS location_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       COALESCE(d.inventory_number, '') AS inventory_number,
+       d.status,
+       COALESCE(to_char(d.installed_at, 'YYYY-MM-DD'), '') AS installed_at,
+       COALESCE(d.description, '') AS description,
+       d.version,
+       d.custom_fields,
+       COALESCE(d.hostname, '') AS hostname,
+       COALESCE((
+         SELECT string_agg(abbrev(a.address) || CASE WHEN a.management THEN ' (mgmt)' ELSE '' END, ', ' ORDER BY a.management DESC, a.address)
+         FROM device_ip_addresses a WHERE a.device_id = d.id
+       ), '') AS ip_addresses,
+       COALESCE((
+         SELECT string_agg(ma.address::text, ', ' ORDER BY ma.address)
+         FROM device_mac_addresses ma WHERE ma.device_id = d.id
+       ), '') AS mac_addresses,
+       d.rack_position_u,
+       COALESCE(d.rack_face, '') AS rack_face
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN locations l ON l.id = d.location_id
+WHERE d.id = $1
+  AND d.deleted_at IS NULL
+FOR UPDATE OF d;
+
+-- name: CreateDeviceHistory :exec
+INSERT INTO device_history(device_id, action, actor, changes)
+VALUES($1, $2, $3, $4);
+
+-- name: ListDeviceHistory :many
+SELECT id, action, actor, changes, created_at
+FROM device_history
+WHERE device_id = $1
+ORDER BY created_at DESC, id DESC;
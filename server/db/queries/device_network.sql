@@ -0,0 +1,19 @@
+-- name: DeleteDeviceIPAddresses :exec
+DELETE FROM device_ip_addresses
+WHERE device_id = $1;
+
+-- name: AddDeviceIPAddresses :exec
+-- $2 — адреса в нормализованной записи, $3 — адрес управления из того же списка
+-- (NULL — без адреса управления).
+INSERT INTO device_ip_addresses(device_id, address, management)
+SELECT $1, a, a IS NOT DISTINCT FROM $3::inet
+FROM unnest($2::inet[]) AS a;
+
+-- name: DeleteDeviceMACAddresses :exec
+DELETE FROM device_mac_addresses
+WHERE device_id = $1;
+
+-- name: AddDeviceMACAddresses :exec
+INSERT INTO device_mac_addresses(device_id, address)
+SELECT $1, a
+FROM unnest($2::macaddr[]) AS a;
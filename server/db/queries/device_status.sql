@@ -0,0 +1,22 @@
+-- name: SetDeviceStatus :exec
+UPDATE devices
+SET status = $2,
+    version = version + 1,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: CreateDeviceTransition :one
+INSERT INTO device_status_transitions(device_id, from_status, to_status, reason, actor)
+VALUES($1, $2, $3, $4, $5)
+RETURNING id, created_at;
+
+-- name: ListDeviceTransitions :many
+SELECT id,
+       COALESCE(from_status, '') AS from_status,
+       to_status,
+       COALESCE(reason, '') AS reason,
+       actor,
+       created_at
+FROM device_status_transitions
+WHERE device_id = $1
+ORDER BY created_at DESC, id DESC;
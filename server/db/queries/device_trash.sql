@@ -0,0 +1,46 @@
+-- name: TrashDevice :exec
+UPDATE devices
+SET deleted_at = now(),
+    deleted_by = $2,
+    version = version + 1,
+    updated_at = now()
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: RestoreDevice :exec
+UPDATE devices
+SET deleted_at = NULL,
+    deleted_by = NULL,
+    version = version + 1,
+    updated_at = now()
+WHERE id = $1
+  AND deleted_at IS NOT NULL;
+
+-- name: PurgeDevice :exec
+DELETE FROM devices
+WHERE id = $1
+  AND deleted_at IS NOT NULL;
+
+-- name: PurgeTrashedDevices :many
+-- $1 — удалить только то, что лежит в корзине дольше; NULL — всю корзину.
+DELETE FROM devices
+WHERE deleted_at IS NOT NULL
+  AND ($1::timestamptz IS NULL OR deleted_at < $1)
+RETURNING id;
+
+-- name: ListTrashedDevices :many
+SELECT d.id,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(l.name, '') AS location_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       COALESCE(d.inventory_number, '') AS inventory_number,
+       d.status,
+       d.deleted_at,
+       COALESCE(d.deleted_by, '') AS deleted_by
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN locations l ON l.id = d.location_id
+WHERE d.deleted_at IS NOT NULL
+ORDER BY d.deleted_at DESC, d.id DESC;
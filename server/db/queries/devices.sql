@@ -1,4 +1,14 @@
 -- name: ListDevices :many
+-- Параметры фильтра: $1 q, $2 vendor_id, $3 model_id, $4 location_id, $5 statuses,
+-- $6 installed_from, $7 installed_to, $8 custom_fields (jsonb-объект
+-- ключ → значение в хранимом виде; @> использует GIN-индекс), $9 ip (точный адрес без учёта маски),
+-- $10 cidr (адрес устройства входит в подсеть), $11 mac (устройства или его
+-- интерфейса). Запрос оборачивается в store.ListDevices: keyset-условие,
+-- ORDER BY и LIMIT применяются к его колонкам.
+-- location_id включает вложенные локации.
+-- Устройства в корзине (deleted_at IS NOT NULL) в список не попадают.
+-- Поиск: подстрока и нечёткое совпадение по search_text (pg_trgm) плюс
+-- полнотекстовое совпадение по search_tsv; rank — релевантность для сортировки.
 SELECT d.id,
        v.name AS vendor_name,
        m.name AS model_name,
@@ -6,24 +16,50 @@ SELECT d.id,
        COALESCE(d.serial_number, '') AS serial_number,
        COALESCE(d.inventory_number, '') AS inventory_number,
        d.status,
-       COALESCE(to_char(d.installed_at, 'YYYY-MM-DD'), '') AS installed_at
+       COALESCE(to_char(d.installed_at, 'YYYY-MM-DD'), '') AS installed_at,
+       COALESCE(d.description, '') AS description,
+       COALESCE(d.hostname, '') AS hostname,
+       COALESCE((SELECT host(a.address) FROM device_ip_addresses a WHERE a.device_id = d.id AND a.management), '') AS management_ip,
+       CASE
+         WHEN $1::text = '' THEN 0::float8
+         ELSE (CASE WHEN lower(d.serial_number) = lower($1) OR lower(d.inventory_number) = lower($1) THEN 1 ELSE 0 END)::float8
+              + ts_rank(d.search_tsv, plainto_tsquery('simple', $1))::float8
+              + word_similarity($1, COALESCE(d.search_text, ''))::float8
+       END AS rank
 FROM devices d
 JOIN models m ON m.id = d.model_id
 JOIN vendors v ON v.id = m.vendor_id
 LEFT JOIN locations l ON l.id = d.location_id
-WHERE (
-  $1::text = ''
-  OR d.serial_number ILIKE '%' || $1 || '%'
-  OR d.inventory_number ILIKE '%' || $1 || '%'
-  OR m.name ILIKE '%' || $1 || '%'
-  OR v.name ILIKE '%' || $1 || '%'
-  OR d.status ILIKE '%' || $1 || '%'
-)
-ORDER BY d.id DESC;
+WHERE d.deleted_at IS NULL
+  AND (
+    $1::text = ''
+    OR d.search_text ILIKE '%' || $1 || '%'
+    OR d.search_tsv @@ plainto_tsquery('simple', $1)
+    OR $1 <% d.search_text
+  )
+  AND ($2::bigint IS NULL OR m.vendor_id = $2)
+  AND ($3::bigint IS NULL OR d.model_id = $3)
+  AND ($4::bigint IS NULL OR d.location_id IN (SELECT location_subtree($4)))
+  AND ($5::text[] IS NULL OR d.status = ANY($5))
+  AND ($6::date IS NULL OR d.installed_at >= $6)
+  AND ($7::date IS NULL OR d.installed_at <= $7)
+  AND ($8::jsonb IS NULL OR d.custom_fields @> $8::jsonb)
+  AND ($9::inet IS NULL OR EXISTS (
+    SELECT 1 FROM device_ip_addresses a WHERE a.device_id = d.id AND host(a.address) = host($9::inet)
+  ))
+  AND ($10::inet IS NULL OR EXISTS (
+    SELECT 1 FROM device_ip_addresses a
+    WHERE a.device_id = d.id AND a.address && $10::inet AND host(a.address)::inet <<= $10::inet
+  ))
+  AND ($11::macaddr IS NULL OR EXISTS (
+    SELECT 1 FROM device_mac_addresses ma WHERE ma.device_id = d.id AND ma.address = $11::macaddr
+    UNION ALL
+    SELECT 1 FROM device_interfaces i WHERE i.device_id = d.id AND i.mac_address = $11::macaddr
+  ));
 
 -- name: CreateDevice :one
-INSERT INTO devices(model_id, location_id, serial_number, inventory_number, status, installed_at, description)
-VALUES($1, $2, $3, $4, $5, $6, $7)
+INSERT INTO devices(model_id, location_id, serial_number, inventory_number, status, installed_at, description, hostname, rack_position_u, rack_face)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 RETURNING id;
 
 -- name: GetDeviceByID :one
@@ -34,9 +70,54 @@ SELECT id,
        COALESCE(inventory_number, '') AS inventory_number,
        status,
        COALESCE(to_char(installed_at, 'YYYY-MM-DD'), '') AS installed_at,
-       COALESCE(description, '') AS description
+       COALESCE(description, '') AS description,
+       version,
+       updated_at,
+       custom_fields,
+       COALESCE(hostname, '') AS hostname,
+       COALESCE((SELECT abbrev(a.address) FROM device_ip_addresses a WHERE a.device_id = devices.id AND a.management), '') AS management_ip,
+       ARRAY(
+         SELECT abbrev(a.address) FROM device_ip_addresses a
+         WHERE a.device_id = devices.id
+         ORDER BY a.management DESC, a.address
+       ) AS ip_addresses,
+       ARRAY(
+         SELECT ma.address::text FROM device_mac_addresses ma
+         WHERE ma.device_id = devices.id
+         ORDER BY ma.address
+       ) AS mac_addresses,
+       rack_position_u,
+       COALESCE(rack_face, '') AS rack_face,
+       cov.contract_id,
+       cov.contract_number,
+       cov.supplier,
+       cov.kind,
+       cov.coverage_level,
+       cov.ends_on,
+       cov.days_left,
+       EXISTS(
+         SELECT 1 FROM contract_devices cd JOIN contracts c ON c.id = cd.contract_id
+         WHERE cd.device_id = devices.id AND c.ends_on < current_date
+       ) AS has_expired_contracts
 FROM devices
-WHERE id = $1;
+-- Текущее покрытие — действующий контракт, который заканчивается позже всех.
+LEFT JOIN LATERAL (
+    SELECT c.id AS contract_id,
+           c.contract_number,
+           c.supplier,
+           c.kind,
+           COALESCE(c.coverage_level, '') AS coverage_level,
+           to_char(c.ends_on, 'YYYY-MM-DD') AS ends_on,
+           c.ends_on - current_date AS days_left
+    FROM contract_devices cd
+    JOIN contracts c ON c.id = cd.contract_id
+    WHERE cd.device_id = devices.id
+      AND current_date BETWEEN c.starts_on AND c.ends_on
+    ORDER BY c.ends_on DESC, c.id
+    LIMIT 1
+) cov ON true
+WHERE id = $1
+  AND deleted_at IS NULL;
 
 -- name: UpdateDevice :exec
 UPDATE devices
@@ -46,9 +127,118 @@ SET model_id = $1,
     inventory_number = $4,
     status = $5,
     installed_at = $6,
-    description = $7
-WHERE id = $8;
+    description = $7,
+    hostname = $8,
+    rack_position_u = $9,
+    rack_face = $10,
+    version = version + 1,
+    updated_at = now()
+WHERE id = $11;
+
+-- name: LockDevices :exec
+-- Блокирует строки устройств до конца транзакции; порядок по id исключает
+-- взаимную блокировку двух массовых операций.
+SELECT id FROM devices WHERE id = ANY($1::bigint[]) ORDER BY id FOR UPDATE;
 
-  -- name: DeleteDevice :exec
-DELETE FROM devices
+-- name: DeviceExists :one
+SELECT EXISTS(SELECT 1 FROM devices WHERE id = $1 AND deleted_at IS NULL);
+
+-- name: ListExistingDeviceSerialKeys :many
+-- Ключи сравниваются с devices.serial_key (см. 008_device_identifier_keys.sql).
+SELECT serial_key
+FROM devices
+WHERE serial_key = ANY($1::text[]);
+
+-- name: ListExistingDeviceInventoryKeys :many
+SELECT DISTINCT inventory_key
+FROM devices
+WHERE inventory_key = ANY($1::text[]);
+
+-- name: FindDeviceBySerial :one
+-- $2 — id редактируемого устройства, чтобы не найти его самого (0 при создании).
+SELECT id, deleted_at IS NOT NULL AS in_trash
+FROM devices
+WHERE serial_key = NULLIF(upper(regexp_replace($1, '\s+', '', 'g')), '')
+  AND id <> $2
+LIMIT 1;
+
+-- name: FindDeviceByInventoryNumber :one
+SELECT id, deleted_at IS NOT NULL AS in_trash
+FROM devices
+WHERE inventory_key = NULLIF(lower(btrim($1)), '')
+  AND id <> $2
+ORDER BY id
+LIMIT 1;
+
+-- name: LockDeviceInventoryNumber :exec
+-- Сериализует параллельные проверки одного инвентарного номера до конца транзакции:
+-- уникального индекса по нему нет, уникальность включается настройкой API.
+SELECT pg_advisory_xact_lock(hashtext('device_inventory:' || lower(btrim($1))));
+
+-- name: PatchDevice :exec
+-- При переносе в другую локацию место в стойке освобождается.
+UPDATE devices
+SET location_id = $2,
+    rack_position_u = CASE WHEN location_id IS DISTINCT FROM $2 THEN NULL ELSE rack_position_u END,
+    rack_face = CASE WHEN location_id IS DISTINCT FROM $2 THEN NULL ELSE rack_face END,
+    status = $3,
+    description = $4,
+    version = version + 1,
+    updated_at = now()
 WHERE id = $1;
+
+-- name: ListDeviceLabels :many
+-- Данные для наклеек устройств из $1; устройства в корзине не попадают.
+SELECT d.id,
+       COALESCE(d.inventory_number, '') AS inventory_number,
+       COALESCE(d.serial_number, '') AS serial_number,
+       v.name AS vendor_name,
+       m.name AS model_name
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+WHERE d.id = ANY($1::bigint[])
+  AND d.deleted_at IS NULL
+ORDER BY d.id;
+
+-- name: LookupDevices :many
+-- Точное совпадение кода со сканера: $1 сравнивается с ключами серийного и
+-- инвентарного номеров так же, как они нормализуются в devices (см.
+-- 008_device_identifier_keys.sql), $2 — MAC-адрес устройства или его
+-- интерфейса (NULL, если код не похож на MAC). matched_by — по каким полям
+-- найдено устройство. $3 — предел числа строк.
+WITH matches AS (
+    SELECT id, 'serial' AS matched_by
+    FROM devices
+    WHERE serial_key = NULLIF(upper(regexp_replace($1, '\s+', '', 'g')), '')
+    UNION ALL
+    SELECT id, 'inventory'
+    FROM devices
+    WHERE inventory_key = NULLIF(lower(btrim($1)), '')
+    UNION ALL
+    SELECT device_id, 'mac'
+    FROM device_mac_addresses
+    WHERE address = $2::macaddr
+    UNION ALL
+    SELECT device_id, 'mac'
+    FROM device_interfaces
+    WHERE mac_address = $2::macaddr
+)
+SELECT d.id,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(l.name, '') AS location_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       COALESCE(d.inventory_number, '') AS inventory_number,
+       COALESCE(d.hostname, '') AS hostname,
+       d.status,
+       array_agg(DISTINCT mt.matched_by ORDER BY mt.matched_by) AS matched_by
+FROM matches mt
+JOIN devices d ON d.id = mt.id
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN locations l ON l.id = d.location_id
+WHERE d.deleted_at IS NULL
+GROUP BY d.id, v.name, m.name, l.name
+ORDER BY d.id
+LIMIT $3;
@@ -0,0 +1,107 @@
+-- name: ListDeviceInterfaces :many
+-- Интерфейсы устройства с другим концом линка (если он есть).
+SELECT i.id,
+       i.name,
+       i.if_type,
+       i.speed_mbps,
+       i.admin_state,
+       COALESCE(i.mac_address::text, '') AS mac_address,
+       COALESCE(i.description, '') AS description,
+       l.id AS link_id,
+       peer.id AS peer_interface_id,
+       COALESCE(peer.name, '') AS peer_interface_name,
+       peer.device_id AS peer_device_id,
+       COALESCE(pd.hostname, '') AS peer_device_hostname
+FROM device_interfaces i
+LEFT JOIN links l ON l.a_interface_id = i.id OR l.b_interface_id = i.id
+LEFT JOIN device_interfaces peer
+       ON peer.id = CASE WHEN l.a_interface_id = i.id THEN l.b_interface_id ELSE l.a_interface_id END
+LEFT JOIN devices pd ON pd.id = peer.device_id
+WHERE i.device_id = $1
+ORDER BY i.name, i.id;
+
+-- name: CreateDeviceInterface :one
+INSERT INTO device_interfaces(device_id, name, if_type, speed_mbps, admin_state, mac_address, description)
+VALUES($1, $2, $3, $4, $5, $6, $7)
+RETURNING id;
+
+-- name: UpdateDeviceInterface :exec
+UPDATE device_interfaces
+SET name = $3,
+    if_type = $4,
+    speed_mbps = $5,
+    admin_state = $6,
+    mac_address = $7,
+    description = $8
+WHERE id = $1
+  AND device_id = $2;
+
+-- name: DeleteDeviceInterface :exec
+DELETE FROM device_interfaces
+WHERE id = $1
+  AND device_id = $2;
+
+-- name: LockLinkInterfaces :many
+-- Блокирует интерфейсы будущего линка, чтобы параллельные запросы не заняли
+-- один порт дважды, и сообщает, подключён ли интерфейс уже.
+SELECT i.id,
+       d.deleted_at IS NOT NULL AS device_in_trash,
+       EXISTS(SELECT 1 FROM links l WHERE l.a_interface_id = i.id OR l.b_interface_id = i.id) AS linked
+FROM device_interfaces i
+JOIN devices d ON d.id = i.device_id
+WHERE i.id = ANY($1::bigint[])
+ORDER BY i.id
+FOR UPDATE OF i;
+
+-- name: CreateLink :one
+INSERT INTO links(a_interface_id, b_interface_id, cable_type, description, created_by)
+VALUES($1, $2, $3, $4, $5)
+RETURNING id;
+
+-- name: DeleteLink :exec
+DELETE FROM links
+WHERE id = $1;
+
+-- name: ListTopologyLinks :many
+-- Линки между устройствами не из корзины; $1 — локация (вместе с вложенными)
+-- хотя бы одного конца, NULL — вся сеть.
+SELECT l.id,
+       ia.device_id AS a_device_id,
+       ia.name AS a_interface_name,
+       ib.device_id AS b_device_id,
+       ib.name AS b_interface_name,
+       LEAST(ia.speed_mbps, ib.speed_mbps) AS speed_mbps,
+       COALESCE(l.cable_type, '') AS cable_type,
+       COALESCE(l.description, '') AS description
+FROM links l
+JOIN device_interfaces ia ON ia.id = l.a_interface_id
+JOIN device_interfaces ib ON ib.id = l.b_interface_id
+JOIN devices da ON da.id = ia.device_id AND da.deleted_at IS NULL
+JOIN devices db ON db.id = ib.device_id AND db.deleted_at IS NULL
+WHERE $1::bigint IS NULL
+   OR da.location_id IN (SELECT location_subtree($1))
+   OR db.location_id IN (SELECT location_subtree($1))
+ORDER BY l.id;
+
+-- name: ListTopologyDevices :many
+-- Узлы графа: устройства локации $1 с вложенными (NULL — все) и устройства $2 —
+-- дальние концы линков, уходящих из локации (in_scope = false).
+SELECT d.id,
+       COALESCE(d.hostname, '') AS hostname,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       d.location_id,
+       COALESCE(l.name, '') AS location_name,
+       d.status,
+       s.in_scope
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN locations l ON l.id = d.location_id
+CROSS JOIN LATERAL (
+    SELECT $1::bigint IS NULL OR d.location_id IN (SELECT location_subtree($1)) AS in_scope
+) s
+WHERE d.deleted_at IS NULL
+  AND (s.in_scope OR d.id = ANY($2::bigint[]))
+ORDER BY d.id;
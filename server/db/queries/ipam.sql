@@ -0,0 +1,137 @@
+-- name: ListPrefixes :many
+-- $1 vrf, $2 location_id, $3 vlan_id, $4 адрес, который должен входить в префикс;
+-- NULL — без фильтра. used — число занятых адресов (см. ipam_used_addresses).
+SELECT p.id,
+       p.prefix::text,
+       p.vrf,
+       p.vlan_id,
+       p.location_id,
+       COALESCE(l.name, '') AS location_name,
+       COALESCE(p.description, '') AS description,
+       (SELECT count(*) FROM ipam_used_addresses(p.prefix, p.vrf)) AS used
+FROM prefixes p
+LEFT JOIN locations l ON l.id = p.location_id
+WHERE ($1::text IS NULL OR p.vrf = $1)
+  AND ($2::bigint IS NULL OR p.location_id = $2)
+  AND ($3::integer IS NULL OR p.vlan_id = $3)
+  AND ($4::inet IS NULL OR p.prefix >>= $4::inet)
+ORDER BY p.vrf, p.prefix;
+
+-- name: GetPrefix :one
+SELECT p.id,
+       p.prefix::text,
+       p.vrf,
+       p.vlan_id,
+       p.location_id,
+       COALESCE(l.name, '') AS location_name,
+       COALESCE(p.description, '') AS description,
+       (SELECT count(*) FROM ipam_used_addresses(p.prefix, p.vrf)) AS used
+FROM prefixes p
+LEFT JOIN locations l ON l.id = p.location_id
+WHERE p.id = $1;
+
+-- name: CreatePrefix :one
+INSERT INTO prefixes(prefix, vrf, vlan_id, location_id, description)
+VALUES($1, $2, $3, $4, $5)
+RETURNING id;
+
+-- name: UpdatePrefix :exec
+UPDATE prefixes
+SET prefix = $2,
+    vrf = $3,
+    vlan_id = $4,
+    location_id = $5,
+    description = $6
+WHERE id = $1;
+
+-- name: DeletePrefix :exec
+DELETE FROM prefixes
+WHERE id = $1;
+
+-- name: LockIPAMVRF :exec
+-- Выделение и ручное добавление адресов в одном VRF идут по очереди до конца
+-- транзакции: иначе два запроса «следующего свободного» получат один адрес.
+SELECT pg_advisory_xact_lock(hashtext('ipam_vrf:' || $1));
+
+-- name: LockIPAddressDevice :one
+-- Строка устройства блокируется до блокировки VRF: сохранение устройства
+-- держит её FOR UPDATE и затем ждёт VRF (см. saveDeviceAddresses), поэтому
+-- обратный порядок привёл бы к взаимной блокировке на проверке внешнего ключа.
+SELECT EXISTS(SELECT 1 FROM devices WHERE id = $1 AND deleted_at IS NULL FOR KEY SHARE);
+
+-- name: NextFreeIPAddress :one
+-- $1 префикс, $2 vrf, $3 и $4 — первый и последний адреса, доступные для выделения.
+-- Кандидаты — начало диапазона и адреса сразу за занятыми; первый незанятый
+-- из них и есть наименьший свободный адрес.
+WITH used AS (
+    SELECT a
+    FROM ipam_used_addresses($1::cidr, $2) AS a
+    WHERE a BETWEEN $3::inet AND $4::inet
+)
+SELECT host(c)
+FROM (
+    SELECT $3::inet AS c
+    UNION ALL
+    SELECT a + 1 FROM used WHERE a < $4::inet
+) cand
+WHERE NOT EXISTS (SELECT 1 FROM used u WHERE u.a = cand.c)
+ORDER BY c
+LIMIT 1;
+
+-- name: FindPrefixMaskLen :one
+-- Длина маски самого узкого префикса VRF $2, в который входит адрес $1.
+SELECT masklen(prefix)
+FROM prefixes
+WHERE vrf = $2
+  AND prefix >>= $1::inet
+ORDER BY masklen(prefix) DESC
+LIMIT 1;
+
+-- name: ListIPAddresses :many
+-- $1 vrf, $2 device_id, $3 подсеть (адрес входит в неё), $4 status; NULL — без фильтра.
+SELECT a.id,
+       abbrev(a.address),
+       a.vrf,
+       a.device_id,
+       COALESCE(d.hostname, '') AS device_hostname,
+       a.status,
+       COALESCE(a.description, '') AS description,
+       a.created_by,
+       a.created_at
+FROM ip_addresses a
+LEFT JOIN devices d ON d.id = a.device_id
+WHERE ($1::text IS NULL OR a.vrf = $1)
+  AND ($2::bigint IS NULL OR a.device_id = $2)
+  AND ($3::cidr IS NULL OR (a.address && $3::cidr AND host(a.address)::inet <<= $3::cidr))
+  AND ($4::text IS NULL OR a.status = $4)
+ORDER BY a.vrf, a.address;
+
+-- name: GetIPAddress :one
+SELECT a.id,
+       abbrev(a.address),
+       a.vrf,
+       a.device_id,
+       COALESCE(d.hostname, '') AS device_hostname,
+       a.status,
+       COALESCE(a.description, '') AS description,
+       a.created_by,
+       a.created_at
+FROM ip_addresses a
+LEFT JOIN devices d ON d.id = a.device_id
+WHERE a.id = $1;
+
+-- name: CreateIPAddress :one
+INSERT INTO ip_addresses(address, vrf, device_id, status, description, created_by)
+VALUES($1, $2, $3, $4, $5, $6)
+RETURNING id;
+
+-- name: UpdateIPAddress :exec
+UPDATE ip_addresses
+SET device_id = $2,
+    status = $3,
+    description = $4
+WHERE id = $1;
+
+-- name: DeleteIPAddress :exec
+DELETE FROM ip_addresses
+WHERE id = $1;
@@ -1,18 +1,61 @@
 -- name: ListLocations :many
-SELECT id, name, COALESCE(note, '') AS note
-FROM locations
-ORDER BY name;
+-- path — имена от корня через « / », по нему же и сортировка.
+WITH RECURSIVE tree AS (
+    SELECT id, name::text AS path
+    FROM locations
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT l.id, t.path || ' / ' || l.name
+    FROM locations l
+    JOIN tree t ON l.parent_id = t.id
+)
+SELECT l.id, l.name, COALESCE(l.note, '') AS note, l.custom_fields, l.parent_id, l.location_type, t.path, l.rack_height_u,
+       l.latitude, l.longitude, COALESCE(l.address, '') AS address
+FROM locations l
+JOIN tree t ON t.id = l.id
+ORDER BY t.path;
+
+-- name: ListLocationTree :many
+-- Обход дерева в глубину: строки упорядочены так, что родитель всегда идёт
+-- раньше детей, а соседние узлы — по имени. device_count — устройства,
+-- привязанные непосредственно к локации.
+WITH RECURSIVE tree AS (
+    SELECT id, 0 AS depth, ARRAY[lower(name), id::text] AS sort_path
+    FROM locations
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT l.id, t.depth + 1, t.sort_path || ARRAY[lower(l.name), l.id::text]
+    FROM locations l
+    JOIN tree t ON l.parent_id = t.id
+)
+SELECT l.id,
+       l.parent_id,
+       l.name,
+       l.location_type,
+       COALESCE(l.note, '') AS note,
+       t.depth,
+       l.rack_height_u,
+       (SELECT count(*) FROM devices d WHERE d.location_id = l.id AND d.deleted_at IS NULL) AS device_count
+FROM tree t
+JOIN locations l ON l.id = t.id
+ORDER BY t.sort_path;
 
 -- name: CreateLocation :one
-INSERT INTO locations(name, note)
-VALUES($1, $2)
+INSERT INTO locations(name, note, parent_id, location_type, rack_height_u, latitude, longitude, address)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING id;
 
 -- name: UpdateLocation :exec
 UPDATE locations
 SET name = $1,
-    note = $2
-WHERE id = $3;
+    note = $2,
+    parent_id = $3,
+    location_type = $4,
+    rack_height_u = $5,
+    latitude = $6,
+    longitude = $7,
+    address = $8
+WHERE id = $9;
 
 -- name: DeleteLocation :exec
 DELETE FROM locations
@@ -21,3 +64,61 @@ WHERE id = $1;
 -- name: CountLocations :one
 SELECT COUNT(*)
 FROM locations;
+
+-- name: LocationExists :one
+SELECT EXISTS(SELECT 1 FROM locations WHERE id = $1);
+
+-- name: GetLocationNode :one
+SELECT id, parent_id, location_type, rack_height_u, latitude, longitude, COALESCE(address, '') AS address
+FROM locations
+WHERE id = $1;
+
+-- name: LockLocationTree :exec
+-- Изменения структуры дерева идут по очереди: иначе два встречных переноса
+-- могут вместе замкнуть цикл, хотя каждый по отдельности проверку проходит.
+SELECT pg_advisory_xact_lock(hashtext('location_tree'));
+
+-- name: LocationInSubtree :one
+-- Входит ли $2 в поддерево $1 (включая сам $1).
+SELECT EXISTS(SELECT 1 FROM location_subtree($1) AS s WHERE s = $2);
+
+-- name: ListChildLocationTypes :many
+SELECT DISTINCT location_type
+FROM locations
+WHERE parent_id = $1;
+
+-- name: ReparentChildLocations :exec
+UPDATE locations
+SET parent_id = $2
+WHERE parent_id = $1;
+
+-- name: ListLocationFeatures :many
+-- Локации с координатами для карты. Окно $1..$4 — minLon, minLat, maxLon,
+-- maxLat; при minLon > maxLon окно пересекает 180-й меридиан. status_counts —
+-- устройства локации вместе с вложенными, по статусам.
+SELECT l.id,
+       l.name,
+       l.location_type,
+       l.parent_id,
+       COALESCE(l.address, '') AS address,
+       l.latitude,
+       l.longitude,
+       COALESCE((
+         SELECT jsonb_object_agg(s.status, s.cnt)
+         FROM (
+           SELECT d.status, count(*) AS cnt
+           FROM devices d
+           WHERE d.location_id IN (SELECT location_subtree(l.id))
+             AND d.deleted_at IS NULL
+           GROUP BY d.status
+         ) s
+       ), '{}'::jsonb) AS status_counts
+FROM locations l
+WHERE l.latitude IS NOT NULL
+  AND ($1::float8 IS NULL OR (
+        l.latitude BETWEEN $2::float8 AND $4::float8
+        AND CASE WHEN $1::float8 <= $3::float8
+                 THEN l.longitude BETWEEN $1::float8 AND $3::float8
+                 ELSE l.longitude >= $1::float8 OR l.longitude <= $3::float8
+            END))
+ORDER BY l.name, l.id;
@@ -3,22 +3,38 @@ SELECT m.id,
        m.vendor_id,
        v.name AS vendor_name,
        m.name,
-       COALESCE(m.device_type, '') AS device_type
+       COALESCE(m.device_type, '') AS device_type,
+       m.custom_fields,
+       m.height_u,
+       m.full_depth,
+       m.end_of_sale,
+       m.end_of_support,
+       m.end_of_life,
+       m.replacement_model_id,
+       COALESCE(rv.name || ' ' || r.name, '') AS replacement_model_name
 FROM models m
 JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN models r ON r.id = m.replacement_model_id
+LEFT JOIN vendors rv ON rv.id = r.vendor_id
 ORDER BY v.name, m.name;
 
 -- name: CreateModel :one
-INSERT INTO models(vendor_id, name, device_type)
-VALUES($1, $2, $3)
+INSERT INTO models(vendor_id, name, device_type, height_u, full_depth, end_of_sale, end_of_support, end_of_life, replacement_model_id)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING id;
 
 -- name: UpdateModel :exec
 UPDATE models
 SET vendor_id = $1,
     name = $2,
-    device_type = $3
-WHERE id = $4;
+    device_type = $3,
+    height_u = $4,
+    full_depth = $5,
+    end_of_sale = $6,
+    end_of_support = $7,
+    end_of_life = $8,
+    replacement_model_id = $9
+WHERE id = $10;
 
 -- name: DeleteModel :exec
 DELETE FROM models
@@ -27,3 +43,64 @@ WHERE id = $1;
 -- name: CountModels :one
 SELECT COUNT(*)
 FROM models;
+
+-- name: GetModelLifecycle :one
+SELECT end_of_sale, end_of_support, end_of_life, replacement_model_id
+FROM models
+WHERE id = $1;
+
+-- name: ListEolExposure :many
+-- Устройства в эксплуатации, у модели которых выбранная дата ($1: sale,
+-- support или life) уже прошла или наступит в ближайшие $2 дней. $3 —
+-- локация вместе с вложенными, $4 — вендор. Списанные, выведенные из
+-- эксплуатации и удалённые в корзину не учитываются.
+WITH RECURSIVE tree AS (
+    SELECT id, name::text AS path
+    FROM locations
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT l.id, t.path || ' / ' || l.name
+    FROM locations l
+    JOIN tree t ON l.parent_id = t.id
+),
+exposed AS (
+    SELECT d.*,
+           CASE $1::text
+               WHEN 'sale' THEN m.end_of_sale
+               WHEN 'support' THEN m.end_of_support
+               ELSE m.end_of_life
+           END AS milestone
+    FROM devices d
+    JOIN models m ON m.id = d.model_id
+    WHERE d.deleted_at IS NULL
+      AND d.status NOT IN ('decommissioned', 'written_off')
+      AND ($3::bigint IS NULL OR d.location_id IN (SELECT location_subtree($3)))
+      AND ($4::bigint IS NULL OR m.vendor_id = $4)
+)
+SELECT e.location_id,
+       COALESCE(t.path, '') AS location_path,
+       v.id AS vendor_id,
+       v.name AS vendor_name,
+       m.id AS model_id,
+       m.name AS model_name,
+       m.end_of_sale,
+       m.end_of_support,
+       m.end_of_life,
+       e.milestone,
+       e.milestone - current_date AS days_left,
+       m.replacement_model_id,
+       COALESCE(rv.name || ' ' || r.name, '') AS replacement_model_name,
+       e.id,
+       COALESCE(e.hostname, '') AS hostname,
+       COALESCE(e.serial_number, '') AS serial_number,
+       COALESCE(e.inventory_number, '') AS inventory_number,
+       e.status
+FROM exposed e
+JOIN models m ON m.id = e.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN tree t ON t.id = e.location_id
+LEFT JOIN models r ON r.id = m.replacement_model_id
+LEFT JOIN vendors rv ON rv.id = r.vendor_id
+WHERE e.milestone IS NOT NULL
+  AND e.milestone <= current_date + $2::integer
+ORDER BY t.path NULLS LAST, e.location_id, v.name, v.id, e.milestone, m.name, m.id, e.id;
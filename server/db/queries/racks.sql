@@ -0,0 +1,142 @@
+-- name: LockRack :one
+-- Размещения в одной стойке проверяются по очереди: строка стойки блокируется
+-- до конца транзакции. NO KEY UPDATE не мешает вставке устройств с FK на неё.
+SELECT location_type, rack_height_u
+FROM locations
+WHERE id = $1
+FOR NO KEY UPDATE;
+
+-- name: GetRack :one
+SELECT id, name, location_type, rack_height_u
+FROM locations
+WHERE id = $1;
+
+-- name: GetModelRackSize :one
+SELECT height_u, full_depth
+FROM models
+WHERE id = $1;
+
+-- name: FindRackOverlap :one
+-- Устройство стойки $1 (кроме $2), занимающее хотя бы один из юнитов $3..$4
+-- на стороне $5. Полноразмерные устройства занимают обе стороны; $6 —
+-- полноразмерно ли размещаемое.
+SELECT d.id
+FROM devices d
+JOIN models m ON m.id = d.model_id
+WHERE d.location_id = $1
+  AND d.id <> $2
+  AND d.deleted_at IS NULL
+  AND d.rack_position_u IS NOT NULL
+  AND d.rack_position_u <= $4
+  AND d.rack_position_u + m.height_u - 1 >= $3
+  AND (d.rack_face = $5 OR m.full_depth OR $6::boolean)
+ORDER BY d.rack_position_u
+LIMIT 1;
+
+-- name: FindRackDeviceAbove :one
+-- Устройство стойки $1, не помещающееся в высоту $2 (0 — любое размещённое).
+SELECT d.id
+FROM devices d
+JOIN models m ON m.id = d.model_id
+WHERE d.location_id = $1
+  AND d.deleted_at IS NULL
+  AND d.rack_position_u IS NOT NULL
+  AND d.rack_position_u + m.height_u - 1 > $2
+ORDER BY d.rack_position_u DESC
+LIMIT 1;
+
+-- name: LockModelRacks :exec
+-- Блокирует стойки, где размещены устройства модели $1, перед сменой её размеров.
+SELECT l.id
+FROM locations l
+WHERE l.id IN (
+    SELECT d.location_id FROM devices d
+    WHERE d.model_id = $1 AND d.rack_position_u IS NOT NULL AND d.deleted_at IS NULL
+)
+ORDER BY l.id
+FOR NO KEY UPDATE;
+
+-- name: FindModelRackConflict :one
+-- Первое устройство модели $1, которое при высоте $2 и глубине $3 вышло бы за
+-- верх стойки или пересеклось с соседом.
+WITH placed AS (
+    SELECT d.id,
+           d.model_id,
+           d.location_id,
+           d.rack_position_u AS first_u,
+           d.rack_position_u + CASE WHEN d.model_id = $1 THEN $2::integer ELSE m.height_u END - 1 AS last_u,
+           d.rack_face AS face,
+           CASE WHEN d.model_id = $1 THEN $3::boolean ELSE m.full_depth END AS full_depth
+    FROM devices d
+    JOIN models m ON m.id = d.model_id
+    WHERE d.deleted_at IS NULL
+      AND d.rack_position_u IS NOT NULL
+      AND d.location_id IN (
+          SELECT location_id FROM devices
+          WHERE model_id = $1 AND rack_position_u IS NOT NULL AND deleted_at IS NULL
+      )
+)
+SELECT a.id
+FROM placed a
+JOIN locations l ON l.id = a.location_id
+WHERE a.model_id = $1
+  AND (a.last_u > COALESCE(l.rack_height_u, 0)
+       OR EXISTS (
+           SELECT 1 FROM placed b
+           WHERE b.location_id = a.location_id
+             AND b.id <> a.id
+             AND b.first_u <= a.last_u
+             AND b.last_u >= a.first_u
+             AND (b.face = a.face OR a.full_depth OR b.full_depth)
+       ))
+ORDER BY a.id
+LIMIT 1;
+
+-- name: ListRackDevices :many
+-- Все устройства локации: сначала размещённые сверху вниз, затем без места.
+SELECT d.id,
+       COALESCE(d.hostname, '') AS hostname,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(d.serial_number, '') AS serial_number,
+       d.status,
+       d.rack_position_u,
+       COALESCE(d.rack_face, '') AS rack_face,
+       m.height_u,
+       m.full_depth
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+WHERE d.location_id = $1
+  AND d.deleted_at IS NULL
+ORDER BY d.rack_position_u DESC NULLS LAST, d.id;
+
+-- name: ClearDeviceRackPlacement :exec
+UPDATE devices
+SET rack_position_u = NULL,
+    rack_face = NULL,
+    version = version + 1,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: ListRackPlacedDevices :many
+-- Устройства с местом в стойке $1 (без корзины) для истории при снятии с
+-- мест; строки блокируются до конца транзакции.
+SELECT id
+FROM devices
+WHERE location_id = $1
+  AND rack_position_u IS NOT NULL
+  AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE;
+
+-- name: ClearRackPlacements :exec
+-- Перед удалением стойки: ON DELETE SET NULL не может оставить у устройства
+-- место в стойке без самой стойки.
+UPDATE devices
+SET rack_position_u = NULL,
+    rack_face = NULL,
+    version = version + 1,
+    updated_at = now()
+WHERE location_id = $1
+  AND rack_position_u IS NOT NULL;
@@ -0,0 +1,121 @@
+-- name: ListStockItems :many
+-- $1 модель, $2 локация (вместе с вложенными), $3 поиск по вендору и модели,
+-- $4 — только позиции ниже минимального остатка.
+WITH RECURSIVE tree AS (
+    SELECT id, name::text AS path
+    FROM locations
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT l.id, t.path || ' / ' || l.name
+    FROM locations l
+    JOIN tree t ON l.parent_id = t.id
+)
+SELECT s.id,
+       s.model_id,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       COALESCE(m.device_type, '') AS device_type,
+       s.location_id,
+       t.path AS location_path,
+       s.quantity,
+       s.min_quantity,
+       COALESCE(s.note, '') AS note,
+       s.updated_at
+FROM stock_items s
+JOIN models m ON m.id = s.model_id
+JOIN vendors v ON v.id = m.vendor_id
+JOIN tree t ON t.id = s.location_id
+WHERE ($1::bigint IS NULL OR s.model_id = $1)
+  AND ($2::bigint IS NULL OR s.location_id IN (SELECT location_subtree($2)))
+  AND ($3::text = '' OR v.name ILIKE '%' || $3 || '%' OR m.name ILIKE '%' || $3 || '%')
+  AND (NOT $4::boolean OR s.quantity < s.min_quantity)
+ORDER BY t.path, v.name, m.name, s.id;
+
+-- name: GetStockItem :one
+SELECT id, model_id, location_id, quantity, min_quantity, COALESCE(note, '') AS note
+FROM stock_items
+WHERE id = $1;
+
+-- name: CreateStockItem :one
+INSERT INTO stock_items(model_id, location_id, min_quantity, note)
+VALUES($1, $2, $3, $4)
+RETURNING id;
+
+-- name: UpdateStockItem :exec
+UPDATE stock_items
+SET min_quantity = $2,
+    note = $3,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: DeleteStockItem :exec
+-- Удаляется только пустая позиция: иначе остаток пропал бы без записи в журнале.
+DELETE FROM stock_items
+WHERE id = $1
+  AND quantity = 0;
+
+-- name: EnsureStockItem :exec
+-- Позиция создаётся при первом поступлении в локацию.
+INSERT INTO stock_items(model_id, location_id)
+VALUES($1, $2)
+ON CONFLICT (model_id, location_id) DO NOTHING;
+
+-- name: LockStockItems :many
+-- Блокировка в порядке id: встречные перемещения между двумя локациями не
+-- взаимоблокируются.
+SELECT id, location_id, quantity
+FROM stock_items
+WHERE model_id = $1
+  AND location_id = ANY($2::bigint[])
+ORDER BY id
+FOR UPDATE;
+
+-- name: AddStockQuantity :exec
+UPDATE stock_items
+SET quantity = quantity + $2,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: CreateStockMovement :one
+INSERT INTO stock_movements(kind, model_id, from_location_id, to_location_id, quantity, reason, document_number, actor)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at;
+
+-- name: ListStockMovements :many
+-- $1 модель, $2 локация (откуда или куда, вместе с вложенными), $3 вид
+-- движения; $4/$5 — limit/offset.
+WITH RECURSIVE tree AS (
+    SELECT id, name::text AS path
+    FROM locations
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT l.id, t.path || ' / ' || l.name
+    FROM locations l
+    JOIN tree t ON l.parent_id = t.id
+)
+SELECT sm.id,
+       sm.kind,
+       sm.model_id,
+       v.name AS vendor_name,
+       m.name AS model_name,
+       sm.from_location_id,
+       COALESCE(tf.path, '') AS from_location_path,
+       sm.to_location_id,
+       COALESCE(tt.path, '') AS to_location_path,
+       sm.quantity,
+       COALESCE(sm.reason, '') AS reason,
+       COALESCE(sm.document_number, '') AS document_number,
+       sm.actor,
+       sm.created_at
+FROM stock_movements sm
+JOIN models m ON m.id = sm.model_id
+JOIN vendors v ON v.id = m.vendor_id
+LEFT JOIN tree tf ON tf.id = sm.from_location_id
+LEFT JOIN tree tt ON tt.id = sm.to_location_id
+WHERE ($1::bigint IS NULL OR sm.model_id = $1)
+  AND ($2::bigint IS NULL
+       OR sm.from_location_id IN (SELECT location_subtree($2))
+       OR sm.to_location_id IN (SELECT location_subtree($2)))
+  AND ($3::text IS NULL OR sm.kind = $3)
+ORDER BY sm.created_at DESC, sm.id DESC
+LIMIT $4 OFFSET $5;
@@ -0,0 +1,65 @@
+-- name: CreateDeviceTransfer :one
+INSERT INTO device_transfers(to_location_id, to_location_name, reason, document_number, actor)
+VALUES($1, COALESCE(location_path($1), ''), $2, $3, $4)
+RETURNING id, created_at;
+
+-- name: AddDeviceTransferItem :exec
+-- Устройство копируется в строку документа, чтобы акт не менялся при правке
+-- или удалении устройства.
+INSERT INTO device_transfer_items(transfer_id, device_id, from_location_id, from_location_name,
+                                  hostname, vendor_name, model_name, serial_number, inventory_number)
+SELECT $1::bigint, d.id, $3::bigint, COALESCE(location_path($3), ''),
+       COALESCE(d.hostname, ''), v.name, m.name, COALESCE(d.serial_number, ''), COALESCE(d.inventory_number, '')
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+WHERE d.id = $2;
+
+-- name: ListDeviceTransfers :many
+-- $1 устройство, $2 локация (откуда или куда, вместе с вложенными);
+-- $3/$4 — limit/offset.
+SELECT t.id,
+       t.to_location_id,
+       t.to_location_name,
+       COALESCE(t.reason, '') AS reason,
+       COALESCE(t.document_number, '') AS document_number,
+       t.actor,
+       t.created_at,
+       (SELECT COUNT(*) FROM device_transfer_items i WHERE i.transfer_id = t.id) AS device_count
+FROM device_transfers t
+WHERE ($1::bigint IS NULL OR EXISTS(
+          SELECT 1 FROM device_transfer_items i WHERE i.transfer_id = t.id AND i.device_id = $1))
+  AND ($2::bigint IS NULL
+       OR t.to_location_id IN (SELECT location_subtree($2))
+       OR EXISTS(
+          SELECT 1 FROM device_transfer_items i
+          WHERE i.transfer_id = t.id AND i.from_location_id IN (SELECT location_subtree($2))))
+ORDER BY t.created_at DESC, t.id DESC
+LIMIT $3 OFFSET $4;
+
+-- name: GetDeviceTransfer :one
+SELECT t.id,
+       t.to_location_id,
+       t.to_location_name,
+       COALESCE(t.reason, '') AS reason,
+       COALESCE(t.document_number, '') AS document_number,
+       t.actor,
+       t.created_at,
+       (SELECT COUNT(*) FROM device_transfer_items i WHERE i.transfer_id = t.id) AS device_count
+FROM device_transfers t
+WHERE t.id = $1;
+
+-- name: ListDeviceTransferItems :many
+-- Только снимок из строк документа: у окончательно удалённых устройств
+-- device_id пустой, а строка акта остаётся.
+SELECT i.device_id,
+       i.hostname,
+       i.vendor_name,
+       i.model_name,
+       i.serial_number,
+       i.inventory_number,
+       i.from_location_id,
+       i.from_location_name
+FROM device_transfer_items i
+WHERE i.transfer_id = $1
+ORDER BY i.id;
@@ -1,5 +1,5 @@
 -- name: ListVendors :many
-SELECT id, name, COALESCE(country, '') AS country
+SELECT id, name, COALESCE(country, '') AS country, custom_fields
 FROM vendors
 ORDER BY name;
 
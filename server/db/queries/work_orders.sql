@@ -0,0 +1,122 @@
+-- name: ListWorkOrders :many
+-- $1 устройство, $2 состояния, $3 тип, $4 исполнитель, $5 локация (вместе с
+-- вложенными); $6/$7 — limit/offset. Устройство показывается по снимку в
+-- заявке: после окончательного удаления устройства device_id пустой.
+SELECT wo.id,
+       wo.device_id,
+       wo.hostname,
+       wo.vendor_name,
+       wo.model_name,
+       wo.serial_number,
+       wo.inventory_number,
+       wo.order_type,
+       wo.state,
+       wo.title,
+       COALESCE(wo.notes, '') AS notes,
+       COALESCE(wo.resolution, '') AS resolution,
+       wo.assignee_id,
+       COALESCE(u.username, '') AS assignee,
+       wo.scheduled_at,
+       COALESCE(wo.return_status, '') AS return_status,
+       wo.created_by,
+       wo.created_at,
+       wo.updated_at,
+       wo.started_at,
+       wo.closed_at
+FROM work_orders wo
+LEFT JOIN devices d ON d.id = wo.device_id
+LEFT JOIN users u ON u.id = wo.assignee_id
+WHERE ($1::bigint IS NULL OR wo.device_id = $1)
+  AND ($2::text[] IS NULL OR wo.state = ANY($2))
+  AND ($3::text IS NULL OR wo.order_type = $3)
+  AND ($4::bigint IS NULL OR wo.assignee_id = $4)
+  AND ($5::bigint IS NULL OR d.location_id IN (SELECT location_subtree($5)))
+ORDER BY wo.created_at DESC, wo.id DESC
+LIMIT $6 OFFSET $7;
+
+-- name: GetWorkOrder :one
+-- FOR UPDATE: смена состояния и правка идут по очереди.
+SELECT wo.id,
+       wo.device_id,
+       wo.hostname,
+       wo.vendor_name,
+       wo.model_name,
+       wo.serial_number,
+       wo.inventory_number,
+       wo.order_type,
+       wo.state,
+       wo.title,
+       COALESCE(wo.notes, '') AS notes,
+       COALESCE(wo.resolution, '') AS resolution,
+       wo.assignee_id,
+       COALESCE(u.username, '') AS assignee,
+       wo.scheduled_at,
+       COALESCE(wo.return_status, '') AS return_status,
+       wo.created_by,
+       wo.created_at,
+       wo.updated_at,
+       wo.started_at,
+       wo.closed_at
+FROM work_orders wo
+LEFT JOIN users u ON u.id = wo.assignee_id
+WHERE wo.id = $1
+FOR UPDATE OF wo;
+
+-- name: CreateWorkOrder :one
+-- Устройство копируется в заявку, чтобы история обслуживания пережила его
+-- окончательное удаление.
+INSERT INTO work_orders(device_id, order_type, title, notes, assignee_id, scheduled_at, return_status, created_by,
+                        hostname, vendor_name, model_name, serial_number, inventory_number)
+SELECT d.id, $2::text, $3::text, $4::text, $5::bigint, $6::timestamptz, $7::text, $8::text,
+       COALESCE(d.hostname, ''), v.name, m.name, COALESCE(d.serial_number, ''), COALESCE(d.inventory_number, '')
+FROM devices d
+JOIN models m ON m.id = d.model_id
+JOIN vendors v ON v.id = m.vendor_id
+WHERE d.id = $1
+RETURNING id;
+
+-- name: UpdateWorkOrder :exec
+UPDATE work_orders
+SET order_type = $2,
+    title = $3,
+    notes = $4,
+    assignee_id = $5,
+    scheduled_at = $6,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: SetWorkOrderState :exec
+-- started_at фиксирует первый переход в работу, closed_at — закрытие.
+UPDATE work_orders
+SET state = $2,
+    resolution = COALESCE($3, resolution),
+    started_at = CASE WHEN $2 = 'in_progress' THEN COALESCE(started_at, now()) ELSE started_at END,
+    closed_at = CASE WHEN $2 IN ('done', 'cancelled') THEN now() ELSE NULL END,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: UserExists :one
+SELECT EXISTS(SELECT 1 FROM users WHERE id = $1);
+
+-- name: SetWorkOrderReturnStatus :exec
+UPDATE work_orders
+SET return_status = $2
+WHERE id = $1;
+
+-- name: CountOtherOpenWorkOrders :one
+-- Незакрытые заявки устройства $1, кроме заявки $2.
+SELECT COUNT(*)
+FROM work_orders
+WHERE device_id = $1 AND id <> $2 AND state NOT IN ('done', 'cancelled');
+
+-- name: HandOverWorkOrderReturnStatus :exec
+-- Статус возврата закрываемой заявки $2 переходит к самой ранней другой
+-- незакрытой заявке устройства $1, у которой своего нет.
+UPDATE work_orders
+SET return_status = $3
+WHERE id = (
+    SELECT id FROM work_orders
+    WHERE device_id = $1 AND id <> $2 AND state NOT IN ('done', 'cancelled') AND return_status IS NULL
+    ORDER BY id
+    LIMIT 1
+);
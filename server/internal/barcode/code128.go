@@ -0,0 +1,119 @@
+// Package barcode кодирует строки в штрихкоды Code 128 и QR-коды. Результат —
+// модули без свободной зоны вокруг: отрисовка остаётся за вызывающим.
+package barcode
+
+import "errors"
+
+var (
+	ErrEmpty       = errors.New("barcode: empty value")
+	ErrUnsupported = errors.New("barcode: unsupported character")
+	ErrTooLong     = errors.New("barcode: value too long")
+)
+
+// Code128QuietZone — ширина свободной зоны слева и справа в модулях.
+const Code128QuietZone = 10
+
+const (
+	code128CodeB  = 100
+	code128CodeC  = 99
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+
+	// code128MaxLen — ограничение длины строки: длиннее на наклейку не
+	// поместится читаемый штрихкод.
+	code128MaxLen = 80
+)
+
+// code128Patterns — ширины штрихов и пробелов символов 0–106 в модулях,
+// начиная со штриха. Стоп-символ включает завершающий штрих.
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// Code128 кодирует печатные символы ASCII. Длинные серии цифр пишутся
+// набором C (по две цифры в символе), остальное — набором B. Возвращает
+// модули слева направо: true — штрих.
+func Code128(s string) ([]bool, error) {
+	if s == "" {
+		return nil, ErrEmpty
+	}
+	if len(s) > code128MaxLen {
+		return nil, ErrTooLong
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 32 || s[i] > 126 {
+			return nil, ErrUnsupported
+		}
+	}
+
+	digitRun := func(i int) int {
+		n := 0
+		for i+n < len(s) && s[i+n] >= '0' && s[i+n] <= '9' {
+			n++
+		}
+		return n
+	}
+
+	var codes []int
+	setC := false
+	if run := digitRun(0); run >= 4 || (run == len(s) && run%2 == 0) {
+		codes = append(codes, code128StartC)
+		setC = true
+	} else {
+		codes = append(codes, code128StartB)
+	}
+	for i := 0; i < len(s); {
+		run := digitRun(i)
+		if setC {
+			if run >= 2 {
+				codes = append(codes, int(s[i]-'0')*10+int(s[i+1]-'0'))
+				i += 2
+				continue
+			}
+			codes = append(codes, code128CodeB)
+			setC = false
+		}
+		// В наборе C выгодно переходить на серию из шести цифр, а в конце
+		// строки — из четырёх. При нечётной серии первая цифра остаётся в B.
+		if run >= 6 || (run >= 4 && i+run == len(s)) {
+			if run%2 == 1 {
+				codes = append(codes, int(s[i])-32)
+				i++
+			}
+			codes = append(codes, code128CodeC)
+			setC = true
+			continue
+		}
+		codes = append(codes, int(s[i])-32)
+		i++
+	}
+
+	checksum := codes[0]
+	for i, c := range codes[1:] {
+		checksum += (i + 1) * c
+	}
+	codes = append(codes, checksum%103, code128Stop)
+
+	var modules []bool
+	for _, c := range codes {
+		bar := true
+		for _, w := range code128Patterns[c] {
+			for n := 0; n < int(w-'0'); n++ {
+				modules = append(modules, bar)
+			}
+			bar = !bar
+		}
+	}
+	return modules, nil
+}
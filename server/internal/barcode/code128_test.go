@@ -0,0 +1,176 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Эталонные узоры символов из таблицы ISO/IEC 15417.
+func TestCode128Patterns(t *testing.T) {
+	tests := []struct {
+		value   int
+		pattern string
+	}{
+		{0, "212222"},
+		{1, "222122"},
+		{2, "222221"},
+		{3, "121223"},
+		{16, "123122"},
+		{17, "123221"},
+		{33, "111323"},
+		{63, "111224"},
+		{64, "111422"},
+		{89, "212141"},
+		{95, "114113"},
+		{99, "113141"},
+		{100, "114131"},
+		{101, "311141"},
+		{102, "411131"},
+		{103, "211412"},
+		{104, "211214"},
+		{105, "211232"},
+		{106, "2331112"},
+	}
+	for _, tt := range tests {
+		if got := code128Patterns[tt.value]; got != tt.pattern {
+			t.Errorf("pattern %d = %s, want %s", tt.value, got, tt.pattern)
+		}
+	}
+}
+
+func TestCode128PatternTable(t *testing.T) {
+	seen := make(map[string]int)
+	for v, p := range code128Patterns {
+		want, elems := 11, 6
+		if v == code128Stop {
+			want, elems = 13, 7
+		}
+		if len(p) != elems {
+			t.Errorf("pattern %d: %d elements", v, len(p))
+		}
+		sum, barSum := 0, 0
+		for i, c := range p {
+			w := int(c - '0')
+			if w < 1 || w > 4 {
+				t.Errorf("pattern %d: width %d", v, w)
+			}
+			sum += w
+			if i%2 == 0 {
+				barSum += w
+			}
+		}
+		if sum != want {
+			t.Errorf("pattern %d: %d modules, want %d", v, sum, want)
+		}
+		// Сумма ширин штрихов чётная — на этом построена проверка чётности
+		// у сканеров.
+		if v != code128Stop && barSum%2 != 0 {
+			t.Errorf("pattern %d: odd bar width sum %d", v, barSum)
+		}
+		if prev, dup := seen[p]; dup {
+			t.Errorf("pattern %d duplicates %d", v, prev)
+		}
+		seen[p] = v
+	}
+}
+
+// decodeCode128 разбирает модули обратно в номера символов по ширинам.
+func decodeCode128(t *testing.T, modules []bool) []int {
+	t.Helper()
+	var widths []byte
+	for i := 0; i < len(modules); {
+		j := i
+		for j < len(modules) && modules[j] == modules[i] {
+			j++
+		}
+		widths = append(widths, byte('0'+j-i))
+		i = j
+	}
+	if len(widths) < 13 || (len(widths)-7)%6 != 0 || !modules[0] || !modules[len(modules)-1] {
+		t.Fatalf("malformed symbol: %s", widths)
+	}
+	index := make(map[string]int, len(code128Patterns))
+	for v, p := range code128Patterns {
+		index[p] = v
+	}
+	var values []int
+	for i := 0; i < len(widths); i += 6 {
+		p := string(widths[i:min(i+6, len(widths))])
+		if len(widths)-i == 7 {
+			p = string(widths[i:])
+		}
+		v, ok := index[p]
+		if !ok {
+			t.Fatalf("unknown pattern %s at %d", p, i)
+		}
+		values = append(values, v)
+		if v == code128Stop {
+			break
+		}
+	}
+	return values
+}
+
+func TestCode128(t *testing.T) {
+	// Ожидаемые символы выписаны вручную по стандарту: старт, данные,
+	// контрольный символ (сумма по модулю 103) и стоп.
+	tests := []struct {
+		name string
+		in   string
+		want []int
+	}{
+		{"set B", "PJJ123C", []int{104, 48, 42, 42, 17, 18, 19, 35, 55, 106}},
+		{"set C only", "12345678", []int{105, 12, 34, 56, 78, 47, 106}},
+		{"short even digits", "42", []int{105, 42, 44, 106}},
+		{"short digits stay in B", "123", []int{104, 17, 18, 19, 8, 106}},
+		{"switch to C", "INV-000123", []int{104, 41, 46, 54, 13, 99, 0, 1, 23, 4, 106}},
+		{"odd trailing run", "A12345", []int{104, 33, 17, 99, 23, 45, 64, 106}},
+		{"start C then B", "1234AB", []int{105, 12, 34, 100, 33, 34, 66, 106}},
+		{"space and tilde", " ~", []int{104, 0, 94, 86, 106}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modules, err := Code128(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 11*(len(tt.want)-1) + 13; len(modules) != want {
+				t.Errorf("%d modules, want %d", len(modules), want)
+			}
+			got := decodeCode128(t, modules)
+			if !equalInts(got, tt.want) {
+				t.Errorf("Code128(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCode128Errors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"", ErrEmpty},
+		{"ИНВ-1", ErrUnsupported},
+		{"A\tB", ErrUnsupported},
+		{strings.Repeat("A", code128MaxLen+1), ErrTooLong},
+	}
+	for _, tt := range tests {
+		if _, err := Code128(tt.in); !errors.Is(err, tt.want) {
+			t.Errorf("Code128(%q) error = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,466 @@
+package barcode
+
+// QR-код в байтовом режиме, версии 1–10 (до 271 байта при уровне L).
+// Построение следует ISO/IEC 18004: данные дополняются до ёмкости версии,
+// делятся на блоки с кодом Рида — Соломона, раскладываются змейкой вне
+// служебных узоров и маскируются маской с наименьшим штрафом.
+
+// Level — уровень коррекции ошибок.
+type Level int
+
+const (
+	LevelL Level = iota
+	LevelM
+	LevelQ
+	LevelH
+)
+
+// QRQuietZone — ширина свободной зоны вокруг QR-кода в модулях.
+const QRQuietZone = 4
+
+const qrMaxVersion = 10
+
+// qrBlocks описывает блоки версии и уровня: число байт коррекции на блок и
+// группы блоков (число блоков, байт данных в блоке).
+type qrBlocks struct {
+	ecLen  int
+	groups [2][2]int
+}
+
+// qrBlockTable[версия-1][уровень L, M, Q, H].
+var qrBlockTable = [qrMaxVersion][4]qrBlocks{
+	{{7, [2][2]int{{1, 19}}}, {10, [2][2]int{{1, 16}}}, {13, [2][2]int{{1, 13}}}, {17, [2][2]int{{1, 9}}}},
+	{{10, [2][2]int{{1, 34}}}, {16, [2][2]int{{1, 28}}}, {22, [2][2]int{{1, 22}}}, {28, [2][2]int{{1, 16}}}},
+	{{15, [2][2]int{{1, 55}}}, {26, [2][2]int{{1, 44}}}, {18, [2][2]int{{2, 17}}}, {22, [2][2]int{{2, 13}}}},
+	{{20, [2][2]int{{1, 80}}}, {18, [2][2]int{{2, 32}}}, {26, [2][2]int{{2, 24}}}, {16, [2][2]int{{4, 9}}}},
+	{{26, [2][2]int{{1, 108}}}, {24, [2][2]int{{2, 43}}}, {18, [2][2]int{{2, 15}, {2, 16}}}, {22, [2][2]int{{2, 11}, {2, 12}}}},
+	{{18, [2][2]int{{2, 68}}}, {16, [2][2]int{{4, 27}}}, {24, [2][2]int{{4, 19}}}, {28, [2][2]int{{4, 15}}}},
+	{{20, [2][2]int{{2, 78}}}, {18, [2][2]int{{4, 31}}}, {18, [2][2]int{{2, 14}, {4, 15}}}, {26, [2][2]int{{4, 13}, {1, 14}}}},
+	{{24, [2][2]int{{2, 97}}}, {22, [2][2]int{{2, 38}, {2, 39}}}, {22, [2][2]int{{4, 18}, {2, 19}}}, {26, [2][2]int{{4, 14}, {2, 15}}}},
+	{{30, [2][2]int{{2, 116}}}, {22, [2][2]int{{3, 36}, {2, 37}}}, {20, [2][2]int{{4, 16}, {4, 17}}}, {24, [2][2]int{{4, 12}, {4, 13}}}},
+	{{18, [2][2]int{{2, 68}, {2, 69}}}, {26, [2][2]int{{4, 43}, {1, 44}}}, {24, [2][2]int{{6, 19}, {2, 20}}}, {28, [2][2]int{{6, 15}, {2, 16}}}},
+}
+
+func (b qrBlocks) dataLen() int {
+	return b.groups[0][0]*b.groups[0][1] + b.groups[1][0]*b.groups[1][1]
+}
+
+// qrFormatLevel — биты уровня в служебной информации о формате.
+var qrFormatLevel = [4]int{LevelL: 1, LevelM: 0, LevelQ: 3, LevelH: 2}
+
+// QR кодирует data минимальной подходящей версией. Возвращает квадратную
+// матрицу модулей [строка][столбец]: true — тёмный.
+func QR(data []byte, level Level) ([][]bool, error) {
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	if level < LevelL || level > LevelH {
+		level = LevelM
+	}
+
+	version := 0
+	for v := 1; v <= qrMaxVersion; v++ {
+		// Режим (4 бита), длина (8 бит до версии 9 включительно, дальше 16) и данные.
+		countBits := 8
+		if v > 9 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*qrBlockTable[v-1][level].dataLen() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	q := newQRMatrix(version)
+	q.drawFunctionPatterns()
+	q.drawCodewords(qrCodewords(data, version, level))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(level, mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(level, best)
+	return q.modules, nil
+}
+
+// qrCodewords собирает поток данных с дополнением и перемежает блоки данных
+// и коррекции.
+func qrCodewords(data []byte, version int, level Level) []byte {
+	blocks := qrBlockTable[version-1][level]
+	capacity := blocks.dataLen()
+
+	var bits qrBits
+	bits.append(0b0100, 4)
+	if version > 9 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	terminator := min(4, 8*capacity-bits.n)
+	bits.append(0, terminator)
+	bits.append(0, (8-bits.n%8)%8)
+	for pad := 0; len(bits.buf) < capacity; pad++ {
+		bits.append([2]int{0xEC, 0x11}[pad%2], 8)
+	}
+
+	generator := rsGenerator(blocks.ecLen)
+	var dataBlocks, ecBlocks [][]byte
+	stream := bits.buf
+	for _, g := range blocks.groups {
+		for i := 0; i < g[0]; i++ {
+			block := stream[:g[1]]
+			stream = stream[g[1]:]
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, generator))
+		}
+	}
+
+	var out []byte
+	for i := 0; ; i++ {
+		written := false
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				out = append(out, block[i])
+				written = true
+			}
+		}
+		if !written {
+			break
+		}
+	}
+	for i := 0; i < blocks.ecLen; i++ {
+		for _, block := range ecBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+type qrBits struct {
+	buf []byte
+	n   int
+}
+
+func (b *qrBits) append(v, count int) {
+	for i := count - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.buf = append(b.buf, 0)
+		}
+		if v>>i&1 == 1 {
+			b.buf[len(b.buf)-1] |= 0x80 >> (b.n % 8)
+		}
+		b.n++
+	}
+}
+
+// gfMul умножает в поле GF(256) с порождающим многочленом x⁸+x⁴+x³+x²+1.
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		hi := z & 0x80
+		z <<= 1
+		if hi != 0 {
+			z ^= 0x1D
+		}
+		if y>>i&1 == 1 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// rsGenerator возвращает коэффициенты порождающего многочлена степени
+// degree без старшего: (x − α⁰)(x − α¹)…(x − α^(degree−1)).
+func rsGenerator(degree int) []byte {
+	g := make([]byte, degree)
+	g[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range g {
+			g[j] = gfMul(g[j], root)
+			if j+1 < len(g) {
+				g[j] ^= g[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return g
+}
+
+func rsRemainder(data, generator []byte) []byte {
+	r := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i, c := range generator {
+			r[i] ^= gfMul(c, factor)
+		}
+	}
+	return r
+}
+
+type qrMatrix struct {
+	size     int
+	version  int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newQRMatrix(version int) *qrMatrix {
+	size := 17 + 4*version
+	q := &qrMatrix{size: size, version: version}
+	q.modules = make([][]bool, size)
+	q.function = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+	return q
+}
+
+func (q *qrMatrix) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *qrMatrix) drawFunctionPatterns() {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+
+	pos := q.alignmentPositions()
+	for i, x := range pos {
+		for j, y := range pos {
+			// Углы с поисковыми узорами пропускаются.
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Место под формат резервируется сейчас, биты пишет drawFormat.
+	q.drawFormat(LevelL, 0)
+
+	if q.version >= 7 {
+		rem := q.version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := q.version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := q.size-11+i%3, i/3
+			q.set(a, b, dark)
+			q.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder рисует поисковый узор с центром (x, y) вместе с разделителем.
+func (q *qrMatrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= q.size || yy < 0 || yy >= q.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (q *qrMatrix) alignmentPositions() []int {
+	if q.version == 1 {
+		return nil
+	}
+	count := q.version/7 + 2
+	step := (q.version*8 + count*3 + 5) / (count*4 - 4) * 2
+	pos := make([]int, count)
+	pos[0] = 6
+	for i, p := count-1, q.size-7; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+func (q *qrMatrix) drawFormat(level Level, mask int) {
+	data := qrFormatLevel[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// drawCodewords раскладывает биты парами столбцов справа налево, змейкой
+// вверх и вниз; столбец вертикального синхронизирующего узора пропускается.
+func (q *qrMatrix) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if q.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				q.modules[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask инвертирует модули данных по маске; повторный вызов снимает её.
+func (q *qrMatrix) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty оценивает матрицу по четырём правилам стандарта: длинные серии
+// одного цвета, квадраты 2×2, узоры, похожие на поисковые, и доля тёмных.
+func (q *qrMatrix) penalty() int {
+	at := func(x, y int, transposed bool) bool {
+		if transposed {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+	finderLike := [2][11]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+
+	result := 0
+	for _, transposed := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			run := 0
+			for x := 0; x < q.size; x++ {
+				if x > 0 && at(x, y, transposed) == at(x-1, y, transposed) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+			}
+			for x := 0; x+11 <= q.size; x++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(x+k, y, transposed) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size && c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				result += 3
+			}
+		}
+	}
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return result
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
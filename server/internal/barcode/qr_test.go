@@ -0,0 +1,422 @@
+package barcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestGFMul(t *testing.T) {
+	tests := []struct{ x, y, want byte }{
+		{0x00, 0x53, 0x00},
+		{0x01, 0x53, 0x53},
+		{0x02, 0x80, 0x1D}, // α⁸ = x⁴+x³+x²+1
+		{0x02, 0x02, 0x04},
+		{0x8E, 0x02, 0x01}, // α²⁵⁴·α = α²⁵⁵ = 1
+		{0x1D, 0x1D, 0x4C}, // α¹⁶
+	}
+	for _, tt := range tests {
+		if got := gfMul(tt.x, tt.y); got != tt.want {
+			t.Errorf("gfMul(%#x, %#x) = %#x, want %#x", tt.x, tt.y, got, tt.want)
+		}
+		if got := gfMul(tt.y, tt.x); got != tt.want {
+			t.Errorf("gfMul(%#x, %#x) = %#x, want %#x", tt.y, tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestRSRemainder(t *testing.T) {
+	// «HELLO WORLD» в версии 1-M: эталон из ISO/IEC 18004 и thonky.com.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsGenerator(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		degree int
+		want   []byte // коэффициенты без старшего, от x^(degree−1) к x⁰
+	}{
+		{2, []byte{3, 2}},
+		{7, []byte{127, 122, 154, 164, 11, 68, 117}},
+		{10, []byte{216, 194, 159, 111, 199, 94, 95, 113, 157, 193}},
+	}
+	for _, tt := range tests {
+		if got := rsGenerator(tt.degree); !bytes.Equal(got, tt.want) {
+			t.Errorf("rsGenerator(%d) = %v, want %v", tt.degree, got, tt.want)
+		}
+	}
+}
+
+// Ёмкость версий 1–10 по таблицам 7 и 9 ISO/IEC 18004, столбцы L, M, Q, H.
+var (
+	qrTotalCodewords = [qrMaxVersion]int{26, 44, 70, 100, 134, 172, 196, 242, 292, 346}
+	qrDataCodewords  = [qrMaxVersion][4]int{
+		{19, 16, 13, 9}, {34, 28, 22, 16}, {55, 44, 34, 26}, {80, 64, 48, 36}, {108, 86, 62, 46},
+		{136, 108, 76, 60}, {156, 124, 88, 66}, {194, 154, 110, 86}, {232, 182, 132, 100}, {274, 216, 154, 122},
+	}
+	qrBlockCounts = [qrMaxVersion][4]int{
+		{1, 1, 1, 1}, {1, 1, 1, 1}, {1, 1, 2, 2}, {1, 2, 2, 4}, {1, 2, 4, 4},
+		{2, 4, 4, 4}, {2, 4, 6, 5}, {2, 4, 6, 6}, {2, 5, 8, 8}, {4, 5, 8, 8},
+	}
+	qrByteCapacity = [qrMaxVersion][4]int{
+		{17, 14, 11, 7}, {32, 26, 20, 14}, {53, 42, 32, 24}, {78, 62, 46, 34}, {106, 84, 60, 44},
+		{134, 106, 74, 58}, {154, 122, 86, 64}, {192, 152, 108, 84}, {230, 180, 130, 98}, {271, 213, 151, 119},
+	}
+)
+
+func TestQRBlockTable(t *testing.T) {
+	for v := 1; v <= qrMaxVersion; v++ {
+		for level := LevelL; level <= LevelH; level++ {
+			b := qrBlockTable[v-1][level]
+			name := fmt.Sprintf("%d-%c", v, "LMQH"[level])
+			if got, want := b.dataLen(), qrDataCodewords[v-1][level]; got != want {
+				t.Errorf("%s: %d data codewords, want %d", name, got, want)
+			}
+			blocks := b.groups[0][0] + b.groups[1][0]
+			if want := qrBlockCounts[v-1][level]; blocks != want {
+				t.Errorf("%s: %d blocks, want %d", name, blocks, want)
+			}
+			if got, want := b.dataLen()+blocks*b.ecLen, qrTotalCodewords[v-1]; got != want {
+				t.Errorf("%s: %d codewords in total, want %d", name, got, want)
+			}
+			if b.groups[1][0] > 0 && b.groups[1][1] != b.groups[0][1]+1 {
+				t.Errorf("%s: second group blocks are %d long, first %d", name, b.groups[1][1], b.groups[0][1])
+			}
+		}
+	}
+}
+
+// Строки формата (уровень и маска с BCH-кодом и маской 101010000010010),
+// старший бит первым; по уровням L, M, Q, H и маскам 0–7.
+var qrFormatStrings = [4][8]string{
+	{"111011111000100", "111001011110011", "111110110101010", "111100010011101",
+		"110011000101111", "110001100011000", "110110001000001", "110100101110110"},
+	{"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000"},
+	{"011010101011111", "011000001101000", "011111100110001", "011101000000110",
+		"010010010110100", "010000110000011", "010111011011010", "010101111101101"},
+	{"001011010001001", "001001110111110", "001110011100111", "001100111010000",
+		"000011101100010", "000001001010101", "000110100001100", "000100000111011"},
+}
+
+// readFormat читает обе копии информации о формате, старший бит первым.
+func readFormat(m [][]bool) (string, string) {
+	size := len(m)
+	bit := func(dark bool) byte {
+		if dark {
+			return '1'
+		}
+		return '0'
+	}
+	var a, b []byte
+	// Первая копия: строка 8 слева направо (в обход столбца 6), затем
+	// столбец 8 снизу вверх (в обход строки 6).
+	for _, x := range []int{0, 1, 2, 3, 4, 5, 7, 8} {
+		a = append(a, bit(m[8][x]))
+	}
+	for _, y := range []int{7, 5, 4, 3, 2, 1, 0} {
+		a = append(a, bit(m[y][8]))
+	}
+	// Вторая копия: столбец 8 снизу вверх, затем строка 8 справа.
+	for y := size - 1; y >= size-7; y-- {
+		b = append(b, bit(m[y][8]))
+	}
+	for x := size - 8; x < size; x++ {
+		b = append(b, bit(m[8][x]))
+	}
+	return string(a), string(b)
+}
+
+func TestQRFormat(t *testing.T) {
+	for level := LevelL; level <= LevelH; level++ {
+		for mask := 0; mask < 8; mask++ {
+			q := newQRMatrix(1)
+			q.drawFormat(level, mask)
+			a, b := readFormat(q.modules)
+			if want := qrFormatStrings[level][mask]; a != want || b != want {
+				t.Errorf("level %d mask %d: format %s / %s, want %s", level, mask, a, b, want)
+			}
+		}
+	}
+}
+
+func TestQRVersionInfo(t *testing.T) {
+	tests := []struct {
+		version int
+		bits    string
+	}{
+		{7, "000111110010010100"},
+		{8, "001000010110111100"},
+		{9, "001001101010011001"},
+		{10, "001010010011010011"},
+	}
+	for _, tt := range tests {
+		want, _ := strconv.ParseUint(tt.bits, 2, 32)
+		q := newQRMatrix(tt.version)
+		q.drawFunctionPatterns()
+		size := q.size
+		var lower, upper uint64
+		// Бит i: блок слева внизу — столбец i/3, строка size−11+i%3;
+		// блок справа вверху — транспонированный.
+		for i := 0; i < 18; i++ {
+			if q.modules[size-11+i%3][i/3] {
+				lower |= 1 << i
+			}
+			if q.modules[i/3][size-11+i%3] {
+				upper |= 1 << i
+			}
+		}
+		if lower != want || upper != want {
+			t.Errorf("version %d: info %018b / %018b, want %s", tt.version, lower, upper, tt.bits)
+		}
+	}
+}
+
+func TestQR(t *testing.T) {
+	type testCase struct {
+		name    string
+		data    []byte
+		level   Level
+		version int
+	}
+	tests := []testCase{
+		{"ascii", []byte("HELLO WORLD"), LevelM, 1},
+		{"url", []byte("https://inventory.example/d/000123"), LevelM, 3},
+		{"utf-8", []byte("Коммутатор ядра, стойка 4"), LevelQ, 4},
+	}
+	// На границе ёмкости каждая версия заполнена полностью.
+	for v := 1; v <= qrMaxVersion; v++ {
+		for level := LevelL; level <= LevelH; level++ {
+			n := qrByteCapacity[v-1][level]
+			data := make([]byte, n)
+			for i := range data {
+				data[i] = byte(i*37 + v*11 + int(level))
+			}
+			tests = append(tests, testCase{fmt.Sprintf("full %d-%c", v, "LMQH"[level]), data, level, v})
+			if v < qrMaxVersion {
+				tests = append(tests, testCase{fmt.Sprintf("overflow %d-%c", v, "LMQH"[level]), append(data, 0xFF), level, v + 1})
+			}
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := QR(tt.data, tt.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 17 + 4*tt.version; len(m) != want {
+				t.Fatalf("size %d, want %d (version %d)", len(m), want, tt.version)
+			}
+			checkQRPatterns(t, m)
+			got, level := decodeQR(t, m)
+			if level != tt.level {
+				t.Errorf("level %d, want %d", level, tt.level)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("decoded %q, want %q", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestQRErrors(t *testing.T) {
+	if _, err := QR(nil, LevelM); !errors.Is(err, ErrEmpty) {
+		t.Errorf("empty: error = %v, want %v", err, ErrEmpty)
+	}
+	for level := LevelL; level <= LevelH; level++ {
+		data := make([]byte, qrByteCapacity[qrMaxVersion-1][level]+1)
+		if _, err := QR(data, level); !errors.Is(err, ErrTooLong) {
+			t.Errorf("level %d, %d bytes: error = %v, want %v", level, len(data), err, ErrTooLong)
+		}
+	}
+}
+
+// checkQRPatterns проверяет поисковые узоры с разделителями, синхронизирующие
+// линии и всегда тёмный модуль.
+func checkQRPatterns(t *testing.T, m [][]bool) {
+	t.Helper()
+	size := len(m)
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || y < 0 || x >= size || y >= size {
+					continue
+				}
+				ring := max(abs(dx-3), abs(dy-3))
+				want := ring != 2 && ring != 4
+				if m[y][x] != want {
+					t.Fatalf("finder at %v: module (%d, %d) = %v", corner, x, y, m[y][x])
+				}
+			}
+		}
+	}
+	for i := 8; i < size-8; i++ {
+		if m[6][i] != (i%2 == 0) || m[i][6] != (i%2 == 0) {
+			t.Fatalf("timing pattern broken at %d", i)
+		}
+	}
+	if !m[size-8][8] {
+		t.Fatal("dark module is missing")
+	}
+}
+
+// decodeQR читает символ так же, как сканер: формат, снятие маски, обход
+// змейкой, разбор блоков с проверкой синдромов Рида — Соломона и байтовый режим.
+func decodeQR(t *testing.T, m [][]bool) ([]byte, Level) {
+	t.Helper()
+	size := len(m)
+	version := (size - 17) / 4
+
+	a, b := readFormat(m)
+	if a != b {
+		t.Fatalf("format copies differ: %s / %s", a, b)
+	}
+	level, mask := Level(-1), -1
+	for l := range qrFormatStrings {
+		for k, s := range qrFormatStrings[l] {
+			if s == a {
+				level, mask = Level(l), k
+			}
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("invalid format %s", a)
+	}
+
+	// Условия масок из таблицы 10 стандарта: i — строка, j — столбец.
+	masks := [8]func(i, j int) bool{
+		func(i, j int) bool { return (i+j)%2 == 0 },
+		func(i, j int) bool { return i%2 == 0 },
+		func(i, j int) bool { return j%3 == 0 },
+		func(i, j int) bool { return (i+j)%3 == 0 },
+		func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+		func(i, j int) bool { return i*j%2+i*j%3 == 0 },
+		func(i, j int) bool { return (i*j%2+i*j%3)%2 == 0 },
+		func(i, j int) bool { return ((i+j)%2+i*j%3)%2 == 0 },
+	}
+
+	fn := newQRMatrix(version)
+	fn.drawFunctionPatterns()
+	var bits []bool
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for k := 0; k < size; k++ {
+			y := k
+			if upward {
+				y = size - 1 - k
+			}
+			for _, x := range []int{right, right - 1} {
+				if !fn.function[y][x] {
+					bits = append(bits, m[y][x] != masks[mask](y, x))
+				}
+			}
+		}
+		upward = !upward
+	}
+	total := qrTotalCodewords[version-1]
+	if len(bits) < 8*total {
+		t.Fatalf("%d data modules, want at least %d", len(bits), 8*total)
+	}
+	codewords := make([]byte, total)
+	for i := range codewords {
+		for k := 0; k < 8; k++ {
+			if bits[8*i+k] {
+				codewords[i] |= 0x80 >> k
+			}
+		}
+	}
+
+	// Разбор перемежения: сначала по байту данных из каждого блока, затем
+	// байты коррекции.
+	table := qrBlockTable[version-1][level]
+	var lens []int
+	for _, g := range table.groups {
+		for i := 0; i < g[0]; i++ {
+			lens = append(lens, g[1])
+		}
+	}
+	blocks := make([][]byte, len(lens))
+	pos := 0
+	for i := 0; i < lens[len(lens)-1]; i++ {
+		for k := range blocks {
+			if i < lens[k] {
+				blocks[k] = append(blocks[k], codewords[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < table.ecLen; i++ {
+		for k := range blocks {
+			blocks[k] = append(blocks[k], codewords[pos])
+			pos++
+		}
+	}
+	var stream []byte
+	for k, block := range blocks {
+		// Кодовое слово без ошибок даёт нулевые синдромы S_i = c(αⁱ).
+		alpha := byte(1)
+		for i := 0; i < table.ecLen; i++ {
+			var s byte
+			for _, c := range block {
+				s = gfMul(s, alpha) ^ c
+			}
+			if s != 0 {
+				t.Fatalf("block %d: syndrome %d = %#x", k, i, s)
+			}
+			alpha = gfMul(alpha, 2)
+		}
+		stream = append(stream, block[:lens[k]]...)
+	}
+
+	r := bitReader{buf: stream}
+	if mode := r.read(4); mode != 0b0100 {
+		t.Fatalf("mode %04b, want byte mode", mode)
+	}
+	countBits := 8
+	if version > 9 {
+		countBits = 16
+	}
+	n := r.read(countBits)
+	if 4+countBits+8*n > 8*len(stream) {
+		t.Fatalf("length %d exceeds capacity", n)
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(r.read(8))
+	}
+	if rest := 8*len(stream) - r.n; rest > 0 {
+		if term := r.read(min(4, rest)); term != 0 {
+			t.Errorf("terminator %b, want zeros", term)
+		}
+		if pad := r.read((8 - r.n%8) % 8); pad != 0 {
+			t.Errorf("bit padding %b, want zeros", pad)
+		}
+		for i := 0; r.n < 8*len(stream); i++ {
+			if got, want := r.read(8), [2]int{0xEC, 0x11}[i%2]; got != want {
+				t.Errorf("pad codeword %d = %#x, want %#x", i, got, want)
+			}
+		}
+	}
+	return data, level
+}
+
+type bitReader struct {
+	buf []byte
+	n   int
+}
+
+func (r *bitReader) read(count int) int {
+	v := 0
+	for i := 0; i < count; i++ {
+		v = v<<1 | int(r.buf[r.n/8]>>(7-r.n%8)&1)
+		r.n++
+	}
+	return v
+}
@@ -0,0 +1,276 @@
+// Package label рисует наклейки с кодом инвентарного номера: штрихкодом
+// Code 128 или QR-кодом и строками текста. Раскладка считается в
+// миллиметрах один раз и выводится в PNG, SVG или на страницу PDF.
+package label
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+
+	"telecombase/server/internal/barcode"
+	"telecombase/server/internal/pdf"
+	"telecombase/server/internal/ttf"
+)
+
+type Symbology string
+
+const (
+	Code128 Symbology = "code128"
+	QR      Symbology = "qr"
+)
+
+// ErrTooSmall — код не помещается на наклейку заданного размера.
+var ErrTooSmall = errors.New("label: code does not fit the label")
+
+// Label — содержимое наклейки. Code кодируется и печатается текстом; Title
+// (модель) выводится жирным, Lines — строками под ним.
+type Label struct {
+	Symbology Symbology
+	Code      string
+	Title     string
+	Lines     []string
+}
+
+const (
+	padding = 2.0
+	// minModule — самый узкий модуль кода, который уверенно читают сканеры.
+	minModule = 0.19
+	minBarH   = 4.0
+)
+
+type box struct {
+	x, y, w, h float64
+}
+
+// textItem — строка с базовой линией y; center — x задаёт середину строки.
+type textItem struct {
+	x, y, size, width float64
+	bold, center      bool
+	s                 string
+}
+
+type layout struct {
+	boxes []box
+	texts []textItem
+}
+
+// layout раскладывает наклейку w×h мм. unit — размер пикселя в мм для
+// растрового вывода (0 — векторный): модули кода выравниваются по нему.
+func (l Label) layout(w, h, unit float64) (layout, error) {
+	fontSize := math.Min(math.Max(h/10, 2.2), 4.5)
+	if l.Symbology == QR {
+		return l.layoutQR(w, h, unit, fontSize)
+	}
+	return l.layoutCode128(w, h, unit, fontSize)
+}
+
+func snap(v, unit float64) float64 {
+	if unit <= 0 {
+		return v
+	}
+	return math.Floor(v/unit) * unit
+}
+
+func (l Label) layoutCode128(w, h, unit, fontSize float64) (layout, error) {
+	modules, err := barcode.Code128(l.Code)
+	if err != nil {
+		return layout{}, err
+	}
+	var out layout
+	textW := w - 2*padding
+	y := padding
+	if l.Title != "" {
+		y += fontSize
+		out.texts = append(out.texts, textItem{x: padding, y: y, size: fontSize, width: textW, bold: true, s: l.Title})
+		y += fontSize * 0.3
+	}
+	for _, line := range l.Lines {
+		y += fontSize
+		out.texts = append(out.texts, textItem{x: padding, y: y, size: fontSize, width: textW, s: line})
+		y += fontSize * 0.3
+	}
+	top := y + 1
+	bottom := h - padding - fontSize*1.2
+	if bottom-top < minBarH {
+		return layout{}, ErrTooSmall
+	}
+
+	module := snap((w-2*padding)/float64(len(modules)+2*barcode.Code128QuietZone), unit)
+	if module < minModule || module <= 0 {
+		return layout{}, ErrTooSmall
+	}
+	x0 := snap((w-module*float64(len(modules)))/2, unit)
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(modules) && modules[i] {
+			i++
+		}
+		out.boxes = append(out.boxes, box{x: x0 + module*float64(start), y: top, w: module * float64(i-start), h: bottom - top})
+	}
+	out.texts = append(out.texts, textItem{x: w / 2, y: h - padding - fontSize*0.15, size: fontSize, width: textW, center: true, s: l.Code})
+	return out, nil
+}
+
+func (l Label) layoutQR(w, h, unit, fontSize float64) (layout, error) {
+	matrix, err := barcode.QR([]byte(l.Code), barcode.LevelM)
+	if err != nil {
+		return layout{}, err
+	}
+	n := float64(len(matrix))
+	// Свободная зона входит в квадрат от края наклейки; справа — текст.
+	side := math.Min(h, w*0.45)
+	module := snap(side/(n+2*barcode.QRQuietZone), unit)
+	if module < minModule || module <= 0 {
+		return layout{}, ErrTooSmall
+	}
+	x0 := snap(module*barcode.QRQuietZone, unit)
+	y0 := snap((h-module*n)/2, unit)
+
+	var out layout
+	for r, row := range matrix {
+		for c := 0; c < len(row); {
+			if !row[c] {
+				c++
+				continue
+			}
+			start := c
+			for c < len(row) && row[c] {
+				c++
+			}
+			out.boxes = append(out.boxes, box{x: x0 + module*float64(start), y: y0 + module*float64(r), w: module * float64(c-start), h: module})
+		}
+	}
+
+	tx := x0 + module*(n+barcode.QRQuietZone)
+	textW := w - padding - tx
+	if textW < 8 {
+		return out, nil
+	}
+	items := []textItem{{size: fontSize * 1.1, bold: true, s: l.Code}}
+	if l.Title != "" {
+		items = append(items, textItem{size: fontSize, bold: true, s: l.Title})
+	}
+	for _, line := range l.Lines {
+		items = append(items, textItem{size: fontSize, s: line})
+	}
+	total := 0.0
+	for _, it := range items {
+		total += it.size * 1.3
+	}
+	y := (h-total)/2 + fontSize*0.3
+	for _, it := range items {
+		y += it.size
+		it.x, it.y, it.width = tx, y, textW
+		out.texts = append(out.texts, it)
+		y += it.size * 0.3
+	}
+	return out, nil
+}
+
+// PNG рисует наклейку w×h мм с разрешением dpi; текст закрашивается по
+// контурам встроенного шрифта.
+func (l Label) PNG(out io.Writer, w, h float64, dpi int) error {
+	unit := 25.4 / float64(dpi)
+	lay, err := l.layout(w, h, unit)
+	if err != nil {
+		return err
+	}
+	px := func(v float64) int { return int(math.Round(v / unit)) }
+
+	img := image.NewPaletted(image.Rect(0, 0, px(w), px(h)), color.Palette{color.White, color.Black})
+	for _, b := range lay.boxes {
+		fillRect(img, px(b.x), px(b.y), px(b.x+b.w), px(b.y+b.h))
+	}
+	toPx := func(p ttf.Point) ttf.Point { return ttf.Point{X: p.X / unit, Y: p.Y / unit} }
+	for _, t := range lay.texts {
+		contours := t.outline()
+		for i := range contours {
+			c := &contours[i]
+			c.Start = toPx(c.Start)
+			for j := range c.Segments {
+				c.Segments[j].C = toPx(c.Segments[j].C)
+				c.Segments[j].P = toPx(c.Segments[j].P)
+			}
+		}
+		fillContours(img, contours)
+	}
+	bw := bufio.NewWriter(out)
+	if err := png.Encode(bw, img); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func fillRect(img *image.Paletted, x0, y0, x1, y1 int) {
+	r := image.Rect(x0, y0, x1, y1).Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetColorIndex(x, y, 1)
+		}
+	}
+}
+
+// SVG рисует наклейку w×h мм; координаты — в миллиметрах.
+func (l Label) SVG(out io.Writer, w, h float64) error {
+	lay, err := l.layout(w, h, 0)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(out)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%smm" height="%smm" viewBox="0 0 %s %s">`+"\n",
+		svgNum(w), svgNum(h), svgNum(w), svgNum(h))
+	fmt.Fprintf(bw, `<rect width="%s" height="%s" fill="#fff"/>`+"\n", svgNum(w), svgNum(h))
+	if len(lay.boxes) > 0 {
+		bw.WriteString(`<path fill="#000" shape-rendering="crispEdges" d="`)
+		for _, b := range lay.boxes {
+			fmt.Fprintf(bw, "M%s %sh%sv%sh-%sz", svgNum(b.x), svgNum(b.y), svgNum(b.w), svgNum(b.h), svgNum(b.w))
+		}
+		bw.WriteString("\"/>\n")
+	}
+	// Текст выводится контурами встроенного шрифта: у просмотрщика может не
+	// оказаться нужной гарнитуры, а ширина строки должна совпадать с раскладкой.
+	for _, t := range lay.texts {
+		if d := svgPath(t.outline()); d != "" {
+			fmt.Fprintf(bw, `<path fill="#000" d="%s"/>`+"\n", d)
+		}
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// Draw рисует наклейку w×h мм на странице PDF с левым верхним углом (x, y) в
+// пунктах.
+func (l Label) Draw(p *pdf.Page, x, y, w, h float64) error {
+	lay, err := l.layout(w, h, 0)
+	if err != nil {
+		return err
+	}
+	for _, b := range lay.boxes {
+		p.Rect(x+b.x*pdf.MM, y+b.y*pdf.MM, b.w*pdf.MM, b.h*pdf.MM)
+	}
+	for _, t := range lay.texts {
+		size := t.size * pdf.MM
+		s := pdf.Fit(t.s, size, t.bold, t.width*pdf.MM)
+		tx := x + t.x*pdf.MM
+		if t.center {
+			tx -= pdf.TextWidth(s, size, t.bold) / 2
+		}
+		p.Text(tx, y+t.y*pdf.MM, size, t.bold, s)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package label
+
+import (
+	"errors"
+
+	"telecombase/server/internal/pdf"
+)
+
+// SheetLayout — раскладка наклеек на листе A4. Размеры — в миллиметрах;
+// наклейки идут по строкам слева направо с зазорами GapX и GapY.
+type SheetLayout struct {
+	Name        string
+	Columns     int
+	Rows        int
+	LabelWidth  float64
+	LabelHeight float64
+	MarginTop   float64
+	MarginLeft  float64
+	GapX        float64
+	GapY        float64
+}
+
+// Layouts — распространённые листы самоклеящихся этикеток A4.
+var Layouts = []SheetLayout{
+	{Name: "a4-3x8", Columns: 3, Rows: 8, LabelWidth: 70, LabelHeight: 37, MarginTop: 0.5},
+	{Name: "a4-3x7", Columns: 3, Rows: 7, LabelWidth: 63.5, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 7.2, GapX: 2.5},
+	{Name: "a4-2x7", Columns: 2, Rows: 7, LabelWidth: 99.1, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 4.65, GapX: 2.5},
+	{Name: "a4-2x4", Columns: 2, Rows: 4, LabelWidth: 105, LabelHeight: 74, MarginTop: 0.5},
+	{Name: "a4-5x13", Columns: 5, Rows: 13, LabelWidth: 38.1, LabelHeight: 21.2, MarginTop: 10.7, MarginLeft: 4.75, GapX: 2.5},
+}
+
+// LookupLayout ищет раскладку из Layouts по имени.
+func LookupLayout(name string) (SheetLayout, bool) {
+	for _, l := range Layouts {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return SheetLayout{}, false
+}
+
+const (
+	a4Width  = 210.0
+	a4Height = 297.0
+	// sheetTolerance — допуск на округление размеров в паспортах листов.
+	sheetTolerance = 0.05
+
+	MaxColumns = 10
+	MaxRows    = 30
+	MinWidth   = 15.0
+	MinHeight  = 10.0
+)
+
+var ErrInvalidLayout = errors.New("label: invalid sheet layout")
+
+// Validate проверяет, что наклейки помещаются на лист A4.
+func (s SheetLayout) Validate() error {
+	if s.Columns < 1 || s.Columns > MaxColumns || s.Rows < 1 || s.Rows > MaxRows ||
+		s.LabelWidth < MinWidth || s.LabelHeight < MinHeight ||
+		s.MarginTop < 0 || s.MarginLeft < 0 || s.GapX < 0 || s.GapY < 0 {
+		return ErrInvalidLayout
+	}
+	width := s.MarginLeft + float64(s.Columns)*s.LabelWidth + float64(s.Columns-1)*s.GapX
+	height := s.MarginTop + float64(s.Rows)*s.LabelHeight + float64(s.Rows-1)*s.GapY
+	if width > a4Width+sheetTolerance || height > a4Height+sheetTolerance {
+		return ErrInvalidLayout
+	}
+	return nil
+}
+
+func (s SheetLayout) PerPage() int {
+	return s.Columns * s.Rows
+}
+
+// Sheet раскладывает наклейки по листам. skip — сколько мест на первом листе
+// уже использовано (начатый лист); outline обводит наклейки для печати на
+// обычной бумаге. Ошибка раскладки возвращается с номером наклейки.
+func Sheet(title string, labels []Label, s SheetLayout, skip int, outline bool) (*pdf.Document, error) {
+	doc := pdf.New(title)
+	var page *pdf.Page
+	for i, l := range labels {
+		pos := (skip + i) % s.PerPage()
+		if page == nil || pos == 0 {
+			page = doc.AddPage()
+		}
+		col, row := pos%s.Columns, pos/s.Columns
+		x := (s.MarginLeft + float64(col)*(s.LabelWidth+s.GapX)) * pdf.MM
+		y := (s.MarginTop + float64(row)*(s.LabelHeight+s.GapY)) * pdf.MM
+		if outline {
+			page.StrokeRect(x, y, s.LabelWidth*pdf.MM, s.LabelHeight*pdf.MM, 0.3)
+		}
+		if err := l.Draw(page, x, y, s.LabelWidth, s.LabelHeight); err != nil {
+			return nil, &SheetError{Index: i, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+// SheetError — наклейка Index не нарисована из-за Err.
+type SheetError struct {
+	Index int
+	Err   error
+}
+
+func (e *SheetError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SheetError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,161 @@
+package label
+
+import (
+	"image"
+	"math"
+	"slices"
+
+	"telecombase/server/internal/pdf"
+	"telecombase/server/internal/ttf"
+)
+
+// outline возвращает контуры строки t в миллиметрах наклейки (y вниз).
+// Строка обрезается по ширине так же, как в PDF, поэтому все три формата
+// выглядят одинаково и не зависят от шрифтов системы.
+func (t textItem) outline() []ttf.Contour {
+	s := pdf.Fit(t.s, t.size, t.bold, t.width)
+	if s == "" {
+		return nil
+	}
+	f := ttf.Regular
+	if t.bold {
+		f = ttf.Bold
+	}
+	scale := t.size / float64(f.UnitsPerEm)
+	x := t.x
+	if t.center {
+		x -= pdf.TextWidth(s, t.size, t.bold) / 2
+	}
+	var out []ttf.Contour
+	for _, g := range f.Layout(s) {
+		contours, err := f.Outline(g.ID)
+		if err != nil {
+			continue
+		}
+		tr := func(p ttf.Point) ttf.Point {
+			return ttf.Point{X: x + p.X*scale, Y: t.y - p.Y*scale}
+		}
+		for _, c := range contours {
+			c.Start = tr(c.Start)
+			for i := range c.Segments {
+				c.Segments[i].C = tr(c.Segments[i].C)
+				c.Segments[i].P = tr(c.Segments[i].P)
+			}
+			out = append(out, c)
+		}
+		x += float64(f.Advance(g.ID)) * scale
+	}
+	return out
+}
+
+type edge struct {
+	x0, y0, x1, y1 float64
+}
+
+// curveSteps — на сколько отрезков делится кривая при растеризации; для
+// кегля наклеек этого хватает с запасом.
+const curveSteps = 8
+
+// fillContours закрашивает контуры (в пикселях) по правилу ненулевой
+// обмотки; пиксель закрашивается, если внутри его центр.
+func fillContours(img *image.Paletted, contours []ttf.Contour) {
+	var edges []edge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	add := func(a, b ttf.Point) {
+		if a.Y != b.Y {
+			edges = append(edges, edge{a.X, a.Y, b.X, b.Y})
+		}
+		minY, maxY = math.Min(minY, math.Min(a.Y, b.Y)), math.Max(maxY, math.Max(a.Y, b.Y))
+	}
+	for _, c := range contours {
+		prev := c.Start
+		for _, s := range c.Segments {
+			if !s.Curve {
+				add(prev, s.P)
+				prev = s.P
+				continue
+			}
+			from := prev
+			for k := 1; k <= curveSteps; k++ {
+				t := float64(k) / curveSteps
+				u := 1 - t
+				p := ttf.Point{
+					X: u*u*from.X + 2*u*t*s.C.X + t*t*s.P.X,
+					Y: u*u*from.Y + 2*u*t*s.C.Y + t*t*s.P.Y,
+				}
+				add(prev, p)
+				prev = p
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var xs []crossing
+	y0 := max(int(math.Floor(minY)), img.Rect.Min.Y)
+	y1 := min(int(math.Ceil(maxY)), img.Rect.Max.Y)
+	for py := y0; py < y1; py++ {
+		cy := float64(py) + 0.5
+		xs = xs[:0]
+		for _, e := range edges {
+			if (e.y0 <= cy) == (e.y1 <= cy) {
+				continue
+			}
+			dir := 1
+			if e.y1 < e.y0 {
+				dir = -1
+			}
+			xs = append(xs, crossing{x: e.x0 + (cy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), dir: dir})
+		}
+		slices.SortFunc(xs, func(a, b crossing) int {
+			switch {
+			case a.x < b.x:
+				return -1
+			case a.x > b.x:
+				return 1
+			}
+			return 0
+		})
+		winding := 0
+		for i, c := range xs {
+			winding += c.dir
+			if winding != 0 && i+1 < len(xs) {
+				x0 := int(math.Ceil(c.x - 0.5))
+				x1 := int(math.Ceil(xs[i+1].x - 0.5))
+				fillRect(img, x0, py, x1, py+1)
+			}
+		}
+	}
+}
+
+// svgPath записывает контуры в синтаксисе атрибута d.
+func svgPath(contours []ttf.Contour) string {
+	var b []byte
+	for _, c := range contours {
+		b = append(b, 'M')
+		b = append(b, svgNum(c.Start.X)...)
+		b = append(b, ' ')
+		b = append(b, svgNum(c.Start.Y)...)
+		for _, s := range c.Segments {
+			if s.Curve {
+				b = append(b, 'Q')
+				b = append(b, svgNum(s.C.X)...)
+				b = append(b, ' ')
+				b = append(b, svgNum(s.C.Y)...)
+				b = append(b, ' ')
+			} else {
+				b = append(b, 'L')
+			}
+			b = append(b, svgNum(s.P.X)...)
+			b = append(b, ' ')
+			b = append(b, svgNum(s.P.Y)...)
+		}
+		b = append(b, 'Z')
+	}
+	return string(b)
+}
@@ -0,0 +1,310 @@
+// Package pdf собирает простые PDF-документы из страниц A4: текст, линии и
+// закрашенные прямоугольники. Текст набирается встроенной гарнитурой из
+// пакета ttf (обычное и жирное начертание); в документ попадают только
+// использованные глифы, а таблица ToUnicode сохраняет поиск и копирование.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"telecombase/server/internal/ttf"
+)
+
+// Размер страницы A4 в пунктах и пунктов в миллиметре.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+	MM         = 72 / 25.4
+)
+
+// Document — набор страниц. Страницы рисуются в памяти и пишутся целиком в WriteTo.
+type Document struct {
+	Title string
+	pages []*Page
+	// used — глифы, выведенные обычным [0] и жирным [1] начертанием, с
+	// символами для ToUnicode.
+	used [2]map[uint16]rune
+}
+
+// Page — содержимое одной страницы. Координаты — в пунктах от левого
+// верхнего угла; у текста y — базовая линия.
+type Page struct {
+	doc *Document
+	buf bytes.Buffer
+}
+
+func New(title string) *Document {
+	return &Document{Title: title, used: [2]map[uint16]rune{{}, {}}}
+}
+
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func fontFor(bold bool) *ttf.Font {
+	if bold {
+		return ttf.Bold
+	}
+	return ttf.Regular
+}
+
+func fontIndex(bold bool) int {
+	if bold {
+		return 1
+	}
+	return 0
+}
+
+// Text пишет строку s кеглем size от точки (x, y).
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	fi := fontIndex(bold)
+	used := p.doc.used[fi]
+	fmt.Fprintf(&p.buf, "BT /F%d %s Tf %s %s Td <", fi+1, num(size), num(x), num(PageHeight-y))
+	for _, g := range fontFor(bold).Layout(s) {
+		if _, ok := used[g.ID]; !ok {
+			used[g.ID] = g.Rune
+		}
+		fmt.Fprintf(&p.buf, "%04X", g.ID)
+	}
+	p.buf.WriteString("> Tj ET\n")
+}
+
+// Line рисует отрезок толщиной width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.buf, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Rect закрашивает прямоугольник с левым верхним углом (x, y).
+func (p *Page) Rect(x, y, w, h float64) {
+	fmt.Fprintf(&p.buf, "%s %s %s %s re f\n", num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// StrokeRect обводит прямоугольник с левым верхним углом (x, y) линией толщиной width.
+func (p *Page) StrokeRect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.buf, "%s w %s %s %s %s re S\n", num(width), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// TextWidth возвращает ширину строки s кеглем size в пунктах.
+func TextWidth(s string, size float64, bold bool) float64 {
+	f := fontFor(bold)
+	return float64(f.Width(s)) * size / float64(f.UnitsPerEm)
+}
+
+// Fit обрезает s с многоточием так, чтобы строка помещалась в ширину width.
+func Fit(s string, size float64, bold bool, width float64) string {
+	if TextWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		cut := strings.TrimRight(string(runes), " ") + "..."
+		if TextWidth(cut, size, bold) <= width {
+			return cut
+		}
+	}
+	return ""
+}
+
+// Wrap разбивает s на строки не шире width по пробелам; слишком длинные слова
+// обрезаются через Fit.
+func Wrap(s string, size float64, bold bool, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(candidate, size, bold) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = Fit(word, size, bold, width)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// WriteTo пишет документ в w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 — каталог, 2 — дерево страниц, 3 — сведения о документе; с 4 — по
+	// fontObjCount объектов на шрифт; дальше по паре объектов на страницу:
+	// сама страница и её поток.
+	const firstFontObj = 4
+	firstPageObj := firstFontObj + 2*fontObjCount
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObj+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj(fmt.Sprintf("<< /Title %s /Producer (TelecomBase) /CreationDate (D:%s) >>",
+		textString(d.Title), time.Now().UTC().Format("20060102150405Z")))
+	for i := range d.used {
+		for _, body := range fontObjects(fontFor(i == 1), d.used[i], firstFontObj+fontObjCount*i) {
+			obj(body)
+		}
+	}
+
+	for i, p := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), firstFontObj, firstFontObj+fontObjCount, firstPageObj+2*i+1))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.buf.Len(), p.buf.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.WriteTo(w)
+}
+
+// fontObjCount — объектов на встроенный шрифт: составной шрифт Type0,
+// CIDFontType2, описание шрифта, файл шрифта и таблица ToUnicode.
+const fontObjCount = 5
+
+// fontObjects возвращает объекты шрифта f, урезанного до глифов used, с
+// номерами начиная с first. Коды в строках — номера глифов (Identity-H).
+func fontObjects(f *ttf.Font, used map[uint16]rune, first int) []string {
+	gids := make([]uint16, 0, len(used))
+	for gid := range used {
+		gids = append(gids, gid)
+	}
+	slices.Sort(gids)
+	name := subsetTag(gids) + "+" + f.PostScriptName
+	scale := func(v int) int { return v * 1000 / f.UnitsPerEm }
+
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, " %d [%d]", gid, scale(f.Advance(gid)))
+	}
+
+	var file bytes.Buffer
+	raw := f.Subset(gids)
+	zw := zlib.NewWriter(&file)
+	zw.Write(raw)
+	zw.Close()
+
+	stemV := 80
+	if f == ttf.Bold {
+		stemV = 140
+	}
+	toUnicode := toUnicodeCMap(gids, used)
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s ] >>",
+			name, first+2, widths.String()),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV %d /FontFile2 %d 0 R >>",
+			name, scale(f.BBox[0]), scale(f.BBox[1]), scale(f.BBox[2]), scale(f.BBox[3]), num(f.ItalicAngle),
+			scale(f.Ascent), scale(f.Descent), scale(f.CapHeight), stemV, first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", file.Len(), len(raw), file.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(toUnicode), toUnicode),
+	}
+}
+
+// subsetTag — метка урезанного шрифта из шести заглавных букв; у разных
+// наборов глифов метки разные, как требует спецификация.
+func subsetTag(gids []uint16) string {
+	h := fnv.New32a()
+	for _, gid := range gids {
+		h.Write([]byte{byte(gid >> 8), byte(gid)})
+	}
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag)
+}
+
+// toUnicodeCMap сопоставляет глифы символам, чтобы текст документа можно было
+// искать и копировать.
+func toUnicodeCMap(gids []uint16, used map[uint16]rune) string {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// В одном блоке bfchar допускается не больше 100 записей.
+	for len(gids) > 0 {
+		n := min(len(gids), 100)
+		fmt.Fprintf(&b, "%d beginbfchar\n", n)
+		for _, gid := range gids[:n] {
+			fmt.Fprintf(&b, "<%04X> <", gid)
+			for _, u := range utf16.Encode([]rune{used[gid]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+		gids = gids[n:]
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.String()
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// textString записывает строку метаданных: ASCII — как есть, остальное — в
+// UTF-16BE с меткой порядка байтов.
+func textString(s string) string {
+	var b strings.Builder
+	ascii := true
+	for _, r := range s {
+		if r < 32 || r > 126 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		b.WriteByte('(')
+		for _, c := range []byte(s) {
+			if c == '(' || c == ')' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte(')')
+		return b.String()
+	}
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Вложения устройств
+
+type DeviceAttachmentRow struct {
+	ID         int64
+	DeviceID   int64
+	Filename   string
+	MimeType   string
+	SizeBytes  int64
+	SHA256     string
+	StorageKey string
+	UploadedBy string
+	CreatedAt  time.Time
+}
+
+// AttachmentBlob — содержимое файла; StorageKey — ключ, под которым файл
+// запишется в хранилище, если содержимое новое.
+type AttachmentBlob struct {
+	SHA256     string
+	SizeBytes  int64
+	MimeType   string
+	StorageKey string
+}
+
+// UpsertAttachmentBlob заводит содержимое или находит уже сохранённое.
+// Возвращает ключ файла в хранилище и признак, что содержимое новое.
+func (q *Queries) UpsertAttachmentBlob(ctx context.Context, b AttachmentBlob) (string, bool, error) {
+	row := q.db.QueryRow(ctx, sql("UpsertAttachmentBlob"), b.SHA256, b.SizeBytes, b.MimeType, b.StorageKey)
+	var key string
+	var inserted bool
+	err := row.Scan(&key, &inserted)
+	return key, inserted, err
+}
+
+// CreateDeviceAttachment прикладывает содержимое sha к устройству. Если оно
+// уже приложено, возвращает id существующего вложения и created == false.
+func (q *Queries) CreateDeviceAttachment(ctx context.Context, deviceID int64, sha, filename, uploadedBy string) (int64, bool, error) {
+	var id int64
+	err := q.db.QueryRow(ctx, sql("CreateDeviceAttachment"), deviceID, sha, filename, uploadedBy).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, err
+	}
+	err = q.db.QueryRow(ctx, sql("FindDeviceAttachmentBySHA"), deviceID, sha).Scan(&id)
+	return id, false, err
+}
+
+func scanDeviceAttachment(row pgx.Row) (DeviceAttachmentRow, error) {
+	var it DeviceAttachmentRow
+	err := row.Scan(&it.ID, &it.DeviceID, &it.Filename, &it.MimeType, &it.SizeBytes, &it.SHA256, &it.StorageKey, &it.UploadedBy, &it.CreatedAt)
+	return it, err
+}
+
+func (q *Queries) ListDeviceAttachments(ctx context.Context, deviceID int64) ([]DeviceAttachmentRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceAttachments"), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DeviceAttachmentRow
+	for rows.Next() {
+		it, err := scanDeviceAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) GetDeviceAttachment(ctx context.Context, deviceID, id int64) (DeviceAttachmentRow, error) {
+	return scanDeviceAttachment(q.db.QueryRow(ctx, sql("GetDeviceAttachment"), id, deviceID))
+}
+
+func (q *Queries) DeleteDeviceAttachment(ctx context.Context, deviceID, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteDeviceAttachment"), id, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// DeleteOrphanAttachmentBlobs удаляет содержимое без вложений и возвращает
+// ключи файлов, которые нужно убрать из хранилища после фиксации транзакции.
+func (q *Queries) DeleteOrphanAttachmentBlobs(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, sql("DeleteOrphanAttachmentBlobs"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return keys, nil
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Гарантийные и сервисные контракты
+
+type ContractRow struct {
+	ID             int64
+	ContractNumber string
+	Supplier       string
+	Kind           string
+	CoverageLevel  string
+	StartsOn       string
+	EndsOn         string
+	// State — future, active или expired на текущую дату.
+	State       string
+	Note        string
+	CreatedBy   string
+	CreatedAt   time.Time
+	DeviceCount int64
+}
+
+// ContractParams — поля контракта для записи; CoverageLevel и Note
+// передаются как NULL, если пустые.
+type ContractParams struct {
+	ContractNumber string
+	Supplier       string
+	Kind           string
+	CoverageLevel  any
+	StartsOn       time.Time
+	EndsOn         time.Time
+	Note           any
+}
+
+type ContractFilter struct {
+	Query    string
+	DeviceID *int64
+	// State — future, active или expired на текущую дату.
+	State *string
+}
+
+type ContractDeviceRow struct {
+	ID              int64
+	Hostname        string
+	VendorName      string
+	ModelName       string
+	SerialNumber    string
+	InventoryNumber string
+	LocationName    string
+	Status          string
+}
+
+// DeviceCoverage — действующий контракт устройства.
+type DeviceCoverage struct {
+	ContractID     int64
+	ContractNumber string
+	Supplier       string
+	Kind           string
+	CoverageLevel  string
+	EndsOn         string
+	DaysLeft       int32
+}
+
+type ExpiringCoverageRow struct {
+	ContractID     int64
+	ContractNumber string
+	Supplier       string
+	Kind           string
+	CoverageLevel  string
+	EndsOn         string
+	DaysLeft       int32
+	Device         ContractDeviceRow
+}
+
+func scanContract(row pgx.Row) (ContractRow, error) {
+	var it ContractRow
+	err := row.Scan(&it.ID, &it.ContractNumber, &it.Supplier, &it.Kind, &it.CoverageLevel, &it.StartsOn, &it.EndsOn, &it.State, &it.Note, &it.CreatedBy, &it.CreatedAt, &it.DeviceCount)
+	return it, err
+}
+
+func (q *Queries) listContracts(ctx context.Context, name string, args ...any) ([]ContractRow, error) {
+	rows, err := q.db.Query(ctx, sql(name), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ContractRow
+	for rows.Next() {
+		it, err := scanContract(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) ListContracts(ctx context.Context, f ContractFilter) ([]ContractRow, error) {
+	return q.listContracts(ctx, "ListContracts", f.Query, f.DeviceID, f.State)
+}
+
+// ListDeviceContracts возвращает все контракты устройства, включая истёкшие;
+// DeviceCount в строках не заполняется.
+func (q *Queries) ListDeviceContracts(ctx context.Context, deviceID int64) ([]ContractRow, error) {
+	return q.listContracts(ctx, "ListDeviceContracts", deviceID)
+}
+
+func (q *Queries) GetContract(ctx context.Context, id int64) (ContractRow, error) {
+	return scanContract(q.db.QueryRow(ctx, sql("GetContract"), id))
+}
+
+func (q *Queries) CreateContract(ctx context.Context, p ContractParams, createdBy string) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateContract"), p.ContractNumber, p.Supplier, p.Kind, p.CoverageLevel, p.StartsOn, p.EndsOn, p.Note, createdBy)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateContract(ctx context.Context, id int64, p ContractParams) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateContract"), id, p.ContractNumber, p.Supplier, p.Kind, p.CoverageLevel, p.StartsOn, p.EndsOn, p.Note)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) DeleteContract(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteContract"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func scanContractDevice(row pgx.Row, it *ContractDeviceRow) error {
+	return row.Scan(&it.ID, &it.Hostname, &it.VendorName, &it.ModelName, &it.SerialNumber, &it.InventoryNumber, &it.LocationName, &it.Status)
+}
+
+func (q *Queries) ListContractDevices(ctx context.Context, contractID int64) ([]ContractDeviceRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListContractDevices"), contractID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ContractDeviceRow
+	for rows.Next() {
+		var it ContractDeviceRow
+		if err := scanContractDevice(rows, &it); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+// ListMissingDeviceIDs возвращает id из ids, которых нет среди устройств вне корзины.
+func (q *Queries) ListMissingDeviceIDs(ctx context.Context, ids []int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, sql("ListMissingDeviceIDs"), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+// ReplaceContractDevices заменяет набор покрываемых устройств. Привязки
+// устройств из корзины сохраняются.
+func (q *Queries) ReplaceContractDevices(ctx context.Context, contractID int64, deviceIDs []int64) error {
+	if _, err := q.db.Exec(ctx, sql("DeleteContractDevices"), contractID); err != nil {
+		return err
+	}
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	_, err := q.db.Exec(ctx, sql("AddContractDevices"), contractID, deviceIDs)
+	return err
+}
+
+// ListExpiringCoverage возвращает непродлённое покрытие, истекающее в
+// ближайшие days дней.
+func (q *Queries) ListExpiringCoverage(ctx context.Context, days int32) ([]ExpiringCoverageRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListExpiringCoverage"), days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ExpiringCoverageRow
+	for rows.Next() {
+		var it ExpiringCoverageRow
+		d := &it.Device
+		if err := rows.Scan(&it.ContractID, &it.ContractNumber, &it.Supplier, &it.Kind, &it.CoverageLevel, &it.EndsOn, &it.DaysLeft,
+			&d.ID, &d.Hostname, &d.VendorName, &d.ModelName, &d.SerialNumber, &d.InventoryNumber, &d.LocationName, &d.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
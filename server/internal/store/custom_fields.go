@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// Пользовательские поля
+
+type CustomFieldDefinition struct {
+	ID         int64
+	Scope      string
+	Key        string
+	Label      string
+	FieldType  string
+	Required   bool
+	EnumValues []string
+	DeviceType string
+	SortOrder  int32
+}
+
+// customFieldTables сопоставляет область поля с суффиксом имён запросов
+// Get<X>CustomFields, Set<X>CustomFields и Remove<X>CustomFieldKey.
+var customFieldTables = map[string]string{
+	"device":   "Device",
+	"model":    "Model",
+	"vendor":   "Vendor",
+	"location": "Location",
+}
+
+func customFieldQuery(format, scope string) string {
+	table, ok := customFieldTables[scope]
+	if !ok {
+		panic(fmt.Sprintf("unknown custom field scope %q", scope))
+	}
+	return sql(fmt.Sprintf(format, table))
+}
+
+func (q *Queries) ListCustomFieldDefinitions(ctx context.Context, scope string) ([]CustomFieldDefinition, error) {
+	rows, err := q.db.Query(ctx, sql("ListCustomFieldDefinitions"), scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []CustomFieldDefinition
+	for rows.Next() {
+		var it CustomFieldDefinition
+		if err := rows.Scan(&it.ID, &it.Scope, &it.Key, &it.Label, &it.FieldType, &it.Required, &it.EnumValues, &it.DeviceType, &it.SortOrder); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) GetCustomFieldDefinition(ctx context.Context, id int64) (CustomFieldDefinition, error) {
+	row := q.db.QueryRow(ctx, sql("GetCustomFieldDefinition"), id)
+	var out CustomFieldDefinition
+	err := row.Scan(&out.ID, &out.Scope, &out.Key, &out.Label, &out.FieldType, &out.Required, &out.EnumValues, &out.DeviceType, &out.SortOrder)
+	return out, err
+}
+
+func (q *Queries) CreateCustomFieldDefinition(ctx context.Context, d CustomFieldDefinition, deviceType any) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateCustomFieldDefinition"), d.Scope, d.Key, d.Label, d.FieldType, d.Required, d.EnumValues, deviceType, d.SortOrder)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateCustomFieldDefinition(ctx context.Context, id int64, label string, required bool, enumValues []string, deviceType any, sortOrder int32) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateCustomFieldDefinition"), id, label, required, enumValues, deviceType, sortOrder)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) DeleteCustomFieldDefinition(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteCustomFieldDefinition"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) GetModelDeviceType(ctx context.Context, modelID int64) (string, error) {
+	row := q.db.QueryRow(ctx, sql("GetModelDeviceType"), modelID)
+	var deviceType string
+	err := row.Scan(&deviceType)
+	return deviceType, err
+}
+
+// GetCustomFields читает значения пользовательских полей сущности и блокирует
+// строку до конца транзакции.
+func (q *Queries) GetCustomFields(ctx context.Context, scope string, id int64) ([]byte, error) {
+	row := q.db.QueryRow(ctx, customFieldQuery("Get%sCustomFields", scope), id)
+	var values []byte
+	err := row.Scan(&values)
+	return values, err
+}
+
+func (q *Queries) SetCustomFields(ctx context.Context, scope string, id int64, values []byte) error {
+	_, err := q.db.Exec(ctx, customFieldQuery("Set%sCustomFields", scope), id, values)
+	return err
+}
+
+// RemoveCustomFieldKey удаляет значения поля key у всех сущностей области.
+func (q *Queries) RemoveCustomFieldKey(ctx context.Context, scope string, key string) error {
+	_, err := q.db.Exec(ctx, customFieldQuery("Remove%sCustomFieldKey", scope), key)
+	return err
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// История устройств
+
+type GetDeviceSnapshotRow struct {
+	ID              int64
+	ModelID         int64
+	ModelName       string
+	LocationID      *int64
+	LocationName    string
+	SerialNumber    string
+	InventoryNumber string
+	Status          string
+	InstalledAt     string
+	Description     string
+	Version         int64
+	CustomFields    []byte
+	Hostname        string
+	IPAddresses     string
+	MACAddresses    string
+	RackPositionU   *int32
+	RackFace        string
+}
+
+type ListDeviceHistoryRow struct {
+	ID        int64
+	Action    string
+	Actor     string
+	Changes   []byte
+	CreatedAt time.Time
+}
+
+// GetDeviceSnapshot читает устройство с именами модели и локации и блокирует строку
+// до конца транзакции.
+func (q *Queries) GetDeviceSnapshot(ctx context.Context, id int64) (GetDeviceSnapshotRow, error) {
+	row := q.db.QueryRow(ctx, sql("GetDeviceSnapshot"), id)
+	var out GetDeviceSnapshotRow
+	err := row.Scan(&out.ID, &out.ModelID, &out.ModelName, &out.LocationID, &out.LocationName, &out.SerialNumber, &out.InventoryNumber, &out.Status, &out.InstalledAt, &out.Description, &out.Version, &out.CustomFields, &out.Hostname, &out.IPAddresses, &out.MACAddresses, &out.RackPositionU, &out.RackFace)
+	return out, err
+}
+
+func (q *Queries) CreateDeviceHistory(ctx context.Context, deviceID int64, action string, actor string, changes []byte) error {
+	_, err := q.db.Exec(ctx, sql("CreateDeviceHistory"), deviceID, action, actor, changes)
+	return err
+}
+
+func (q *Queries) ListDeviceHistory(ctx context.Context, deviceID int64) ([]ListDeviceHistoryRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceHistory"), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ListDeviceHistoryRow
+	for rows.Next() {
+		var it ListDeviceHistoryRow
+		if err := rows.Scan(&it.ID, &it.Action, &it.Actor, &it.Changes, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
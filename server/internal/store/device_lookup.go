@@ -0,0 +1,51 @@
+package store
+
+import "context"
+
+// Поиск устройства по коду со сканера
+
+type DeviceLookupRow struct {
+	ID              int64
+	VendorName      string
+	ModelName       string
+	LocationName    string
+	SerialNumber    string
+	InventoryNumber string
+	Hostname        string
+	Status          string
+	MatchedBy       []string
+}
+
+// LookupDevices ищет устройства вне корзины, у которых серийный или
+// инвентарный номер после нормализации равен code либо есть MAC-адрес mac.
+// mac — нормализованный MAC-адрес или пустая строка, если code на него не похож.
+func (q *Queries) LookupDevices(ctx context.Context, code, mac string, limit int32) ([]DeviceLookupRow, error) {
+	rows, err := q.db.Query(ctx, sql("LookupDevices"), code, optionalText(mac), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DeviceLookupRow
+	for rows.Next() {
+		var it DeviceLookupRow
+		if err := rows.Scan(
+			&it.ID,
+			&it.VendorName,
+			&it.ModelName,
+			&it.LocationName,
+			&it.SerialNumber,
+			&it.InventoryNumber,
+			&it.Hostname,
+			&it.Status,
+			&it.MatchedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
@@ -0,0 +1,30 @@
+package store
+
+import "context"
+
+// Сетевые адреса устройств
+
+// ReplaceDeviceIPAddresses заменяет IP-адреса устройства списком addresses.
+// management — адрес управления из того же списка или nil.
+func (q *Queries) ReplaceDeviceIPAddresses(ctx context.Context, deviceID int64, addresses []string, management *string) error {
+	if _, err := q.db.Exec(ctx, sql("DeleteDeviceIPAddresses"), deviceID); err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	_, err := q.db.Exec(ctx, sql("AddDeviceIPAddresses"), deviceID, addresses, management)
+	return err
+}
+
+// ReplaceDeviceMACAddresses заменяет MAC-адреса устройства списком addresses.
+func (q *Queries) ReplaceDeviceMACAddresses(ctx context.Context, deviceID int64, addresses []string) error {
+	if _, err := q.db.Exec(ctx, sql("DeleteDeviceMACAddresses"), deviceID); err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	_, err := q.db.Exec(ctx, sql("AddDeviceMACAddresses"), deviceID, addresses)
+	return err
+}
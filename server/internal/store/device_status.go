@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Жизненный цикл устройств
+
+type CreateDeviceTransitionRow struct {
+	ID        int64
+	CreatedAt time.Time
+}
+
+type ListDeviceTransitionsRow struct {
+	ID         int64
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	Actor      string
+	CreatedAt  time.Time
+}
+
+func (q *Queries) SetDeviceStatus(ctx context.Context, id int64, status string) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("SetDeviceStatus"), id, status)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) CreateDeviceTransition(ctx context.Context, deviceID int64, fromStatus any, toStatus string, reason any, actor string) (CreateDeviceTransitionRow, error) {
+	row := q.db.QueryRow(ctx, sql("CreateDeviceTransition"), deviceID, fromStatus, toStatus, reason, actor)
+	var out CreateDeviceTransitionRow
+	err := row.Scan(&out.ID, &out.CreatedAt)
+	return out, err
+}
+
+func (q *Queries) ListDeviceTransitions(ctx context.Context, deviceID int64) ([]ListDeviceTransitionsRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceTransitions"), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ListDeviceTransitionsRow
+	for rows.Next() {
+		var it ListDeviceTransitionsRow
+		if err := rows.Scan(&it.ID, &it.FromStatus, &it.ToStatus, &it.Reason, &it.Actor, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
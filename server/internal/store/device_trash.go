@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Корзина устройств
+
+type ListTrashedDevicesRow struct {
+	ID              int64
+	VendorName      string
+	ModelName       string
+	LocationName    string
+	SerialNumber    string
+	InventoryNumber string
+	Status          string
+	DeletedAt       time.Time
+	DeletedBy       string
+}
+
+func (q *Queries) TrashDevice(ctx context.Context, id int64, deletedBy string) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("TrashDevice"), id, deletedBy)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) RestoreDevice(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("RestoreDevice"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) PurgeDevice(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("PurgeDevice"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// PurgeTrashedDevices окончательно удаляет устройства, попавшие в корзину раньше
+// deletedBefore (nil — всю корзину), и возвращает их id.
+func (q *Queries) PurgeTrashedDevices(ctx context.Context, deletedBefore *time.Time) ([]int64, error) {
+	rows, err := q.db.Query(ctx, sql("PurgeTrashedDevices"), deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return ids, nil
+}
+
+func (q *Queries) ListTrashedDevices(ctx context.Context) ([]ListTrashedDevicesRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListTrashedDevices"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ListTrashedDevicesRow
+	for rows.Next() {
+		var it ListTrashedDevicesRow
+		if err := rows.Scan(&it.ID, &it.VendorName, &it.ModelName, &it.LocationName, &it.SerialNumber, &it.InventoryNumber, &it.Status, &it.DeletedAt, &it.DeletedBy); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
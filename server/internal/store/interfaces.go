@@ -0,0 +1,184 @@
+package store
+
+import "context"
+
+// Интерфейсы, линки и топология
+
+type DeviceInterfaceRow struct {
+	ID                 int64
+	Name               string
+	Type               string
+	SpeedMbps          *int64
+	AdminState         string
+	MACAddress         string
+	Description        string
+	LinkID             *int64
+	PeerInterfaceID    *int64
+	PeerInterfaceName  string
+	PeerDeviceID       *int64
+	PeerDeviceHostname string
+}
+
+// DeviceInterfaceParams — поля интерфейса для записи; MACAddress и Description
+// передаются как NULL, если пустые.
+type DeviceInterfaceParams struct {
+	Name        string
+	Type        string
+	SpeedMbps   *int64
+	AdminState  string
+	MACAddress  any
+	Description any
+}
+
+type LinkInterfaceRow struct {
+	ID            int64
+	DeviceInTrash bool
+	Linked        bool
+}
+
+type TopologyLinkRow struct {
+	ID             int64
+	ADeviceID      int64
+	AInterfaceName string
+	BDeviceID      int64
+	BInterfaceName string
+	SpeedMbps      *int64
+	CableType      string
+	Description    string
+}
+
+type TopologyDeviceRow struct {
+	ID           int64
+	Hostname     string
+	VendorName   string
+	ModelName    string
+	SerialNumber string
+	LocationID   *int64
+	LocationName string
+	Status       string
+	InScope      bool
+}
+
+func (q *Queries) ListDeviceInterfaces(ctx context.Context, deviceID int64) ([]DeviceInterfaceRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceInterfaces"), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DeviceInterfaceRow
+	for rows.Next() {
+		var it DeviceInterfaceRow
+		if err := rows.Scan(&it.ID, &it.Name, &it.Type, &it.SpeedMbps, &it.AdminState, &it.MACAddress, &it.Description, &it.LinkID, &it.PeerInterfaceID, &it.PeerInterfaceName, &it.PeerDeviceID, &it.PeerDeviceHostname); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) CreateDeviceInterface(ctx context.Context, deviceID int64, p DeviceInterfaceParams) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateDeviceInterface"), deviceID, p.Name, p.Type, p.SpeedMbps, p.AdminState, p.MACAddress, p.Description)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateDeviceInterface(ctx context.Context, id int64, deviceID int64, p DeviceInterfaceParams) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateDeviceInterface"), id, deviceID, p.Name, p.Type, p.SpeedMbps, p.AdminState, p.MACAddress, p.Description)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) DeleteDeviceInterface(ctx context.Context, id int64, deviceID int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteDeviceInterface"), id, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// LockLinkInterfaces блокирует строки интерфейсов до конца транзакции.
+func (q *Queries) LockLinkInterfaces(ctx context.Context, ids []int64) ([]LinkInterfaceRow, error) {
+	rows, err := q.db.Query(ctx, sql("LockLinkInterfaces"), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []LinkInterfaceRow
+	for rows.Next() {
+		var it LinkInterfaceRow
+		if err := rows.Scan(&it.ID, &it.DeviceInTrash, &it.Linked); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) CreateLink(ctx context.Context, aInterfaceID int64, bInterfaceID int64, cableType any, description any, createdBy string) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateLink"), aInterfaceID, bInterfaceID, cableType, description, createdBy)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) DeleteLink(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteLink"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) ListTopologyLinks(ctx context.Context, locationID *int64) ([]TopologyLinkRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListTopologyLinks"), locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TopologyLinkRow
+	for rows.Next() {
+		var it TopologyLinkRow
+		if err := rows.Scan(&it.ID, &it.ADeviceID, &it.AInterfaceName, &it.BDeviceID, &it.BInterfaceName, &it.SpeedMbps, &it.CableType, &it.Description); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+// ListTopologyDevices возвращает устройства локации (nil — все) и устройства extraIDs.
+func (q *Queries) ListTopologyDevices(ctx context.Context, locationID *int64, extraIDs []int64) ([]TopologyDeviceRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListTopologyDevices"), locationID, extraIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TopologyDeviceRow
+	for rows.Next() {
+		var it TopologyDeviceRow
+		if err := rows.Scan(&it.ID, &it.Hostname, &it.VendorName, &it.ModelName, &it.SerialNumber, &it.LocationID, &it.LocationName, &it.Status, &it.InScope); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
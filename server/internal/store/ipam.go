@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// IPAM
+
+type PrefixRow struct {
+	ID           int64
+	Prefix       string
+	VRF          string
+	VlanID       *int32
+	LocationID   *int64
+	LocationName string
+	Description  string
+	Used         int64
+}
+
+type PrefixFilter struct {
+	VRF        *string
+	LocationID *int64
+	VlanID     *int32
+	// Contains — адрес, который должен входить в префикс.
+	Contains *string
+}
+
+type IPAddressRow struct {
+	ID             int64
+	Address        string
+	VRF            string
+	DeviceID       *int64
+	DeviceHostname string
+	Status         string
+	Description    string
+	CreatedBy      string
+	CreatedAt      time.Time
+}
+
+type IPAddressFilter struct {
+	VRF      *string
+	DeviceID *int64
+	// Within — подсеть, в которую входит адрес.
+	Within *string
+	Status *string
+}
+
+func scanPrefix(row pgx.Row) (PrefixRow, error) {
+	var it PrefixRow
+	err := row.Scan(&it.ID, &it.Prefix, &it.VRF, &it.VlanID, &it.LocationID, &it.LocationName, &it.Description, &it.Used)
+	return it, err
+}
+
+func scanIPAddress(row pgx.Row) (IPAddressRow, error) {
+	var it IPAddressRow
+	err := row.Scan(&it.ID, &it.Address, &it.VRF, &it.DeviceID, &it.DeviceHostname, &it.Status, &it.Description, &it.CreatedBy, &it.CreatedAt)
+	return it, err
+}
+
+func (q *Queries) ListPrefixes(ctx context.Context, f PrefixFilter) ([]PrefixRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListPrefixes"), f.VRF, f.LocationID, f.VlanID, f.Contains)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []PrefixRow
+	for rows.Next() {
+		it, err := scanPrefix(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) GetPrefix(ctx context.Context, id int64) (PrefixRow, error) {
+	return scanPrefix(q.db.QueryRow(ctx, sql("GetPrefix"), id))
+}
+
+func (q *Queries) CreatePrefix(ctx context.Context, prefix string, vrf string, vlanID *int32, locationID *int64, description any) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreatePrefix"), prefix, vrf, vlanID, locationID, description)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdatePrefix(ctx context.Context, id int64, prefix string, vrf string, vlanID *int32, locationID *int64, description any) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdatePrefix"), id, prefix, vrf, vlanID, locationID, description)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) DeletePrefix(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeletePrefix"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// LockIPAMVRF сериализует изменения адресов VRF до конца транзакции.
+func (q *Queries) LockIPAMVRF(ctx context.Context, vrf string) error {
+	_, err := q.db.Exec(ctx, sql("LockIPAMVRF"), vrf)
+	return err
+}
+
+// LockIPAddressDevice проверяет, что устройство существует и не в корзине, и
+// удерживает его строку от удаления до конца транзакции.
+func (q *Queries) LockIPAddressDevice(ctx context.Context, id int64) (bool, error) {
+	row := q.db.QueryRow(ctx, sql("LockIPAddressDevice"), id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+// NextFreeIPAddress возвращает наименьший свободный адрес префикса в диапазоне
+// first..last или pgx.ErrNoRows, если свободных адресов нет.
+func (q *Queries) NextFreeIPAddress(ctx context.Context, prefix string, vrf string, first string, last string) (string, error) {
+	row := q.db.QueryRow(ctx, sql("NextFreeIPAddress"), prefix, vrf, first, last)
+	var addr string
+	err := row.Scan(&addr)
+	return addr, err
+}
+
+// FindPrefixMaskLen возвращает длину маски самого узкого префикса, содержащего
+// адрес, или pgx.ErrNoRows.
+func (q *Queries) FindPrefixMaskLen(ctx context.Context, address string, vrf string) (int, error) {
+	row := q.db.QueryRow(ctx, sql("FindPrefixMaskLen"), address, vrf)
+	var bits int32
+	err := row.Scan(&bits)
+	return int(bits), err
+}
+
+func (q *Queries) ListIPAddresses(ctx context.Context, f IPAddressFilter) ([]IPAddressRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListIPAddresses"), f.VRF, f.DeviceID, f.Within, f.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []IPAddressRow
+	for rows.Next() {
+		it, err := scanIPAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) GetIPAddress(ctx context.Context, id int64) (IPAddressRow, error) {
+	return scanIPAddress(q.db.QueryRow(ctx, sql("GetIPAddress"), id))
+}
+
+func (q *Queries) CreateIPAddress(ctx context.Context, address string, vrf string, deviceID *int64, status string, description any, createdBy string) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateIPAddress"), address, vrf, deviceID, status, description, createdBy)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateIPAddress(ctx context.Context, id int64, deviceID *int64, status string, description any) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateIPAddress"), id, deviceID, status, description)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) DeleteIPAddress(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteIPAddress"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
@@ -0,0 +1,36 @@
+package store
+
+import "context"
+
+// Данные для наклеек устройств
+
+type DeviceLabelRow struct {
+	ID              int64
+	InventoryNumber string
+	SerialNumber    string
+	VendorName      string
+	ModelName       string
+}
+
+// ListDeviceLabels возвращает найденные устройства из ids в порядке id;
+// отсутствующие и удалённые в корзину пропускаются.
+func (q *Queries) ListDeviceLabels(ctx context.Context, ids []int64) ([]DeviceLabelRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceLabels"), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DeviceLabelRow
+	for rows.Next() {
+		var it DeviceLabelRow
+		if err := rows.Scan(&it.ID, &it.InventoryNumber, &it.SerialNumber, &it.VendorName, &it.ModelName); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Жизненный цикл моделей и отчёт об устаревающем парке
+
+// ModelLifecycle — даты окончания продаж, поддержки и жизни модели по данным
+// вендора и рекомендуемая замена; nil — не указано.
+type ModelLifecycle struct {
+	EndOfSale          *time.Time
+	EndOfSupport       *time.Time
+	EndOfLife          *time.Time
+	ReplacementModelID *int64
+}
+
+type EolExposureRow struct {
+	LocationID           *int64
+	LocationPath         string
+	VendorID             int64
+	VendorName           string
+	ModelID              int64
+	ModelName            string
+	Lifecycle            ModelLifecycle
+	Milestone            time.Time
+	DaysLeft             int32
+	ReplacementModelName string
+	DeviceID             int64
+	Hostname             string
+	SerialNumber         string
+	InventoryNumber      string
+	Status               string
+}
+
+type EolExposureFilter struct {
+	// Milestone — sale, support или life.
+	Milestone  string
+	Days       int32
+	LocationID *int64
+	VendorID   *int64
+}
+
+func (q *Queries) GetModelLifecycle(ctx context.Context, id int64) (ModelLifecycle, error) {
+	row := q.db.QueryRow(ctx, sql("GetModelLifecycle"), id)
+	var out ModelLifecycle
+	err := row.Scan(&out.EndOfSale, &out.EndOfSupport, &out.EndOfLife, &out.ReplacementModelID)
+	return out, err
+}
+
+func (q *Queries) ListEolExposure(ctx context.Context, f EolExposureFilter) ([]EolExposureRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListEolExposure"), f.Milestone, f.Days, f.LocationID, f.VendorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []EolExposureRow
+	for rows.Next() {
+		var it EolExposureRow
+		if err := rows.Scan(&it.LocationID, &it.LocationPath, &it.VendorID, &it.VendorName, &it.ModelID, &it.ModelName,
+			&it.Lifecycle.EndOfSale, &it.Lifecycle.EndOfSupport, &it.Lifecycle.EndOfLife, &it.Milestone, &it.DaysLeft,
+			&it.Lifecycle.ReplacementModelID, &it.ReplacementModelName,
+			&it.DeviceID, &it.Hostname, &it.SerialNumber, &it.InventoryNumber, &it.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
@@ -2,6 +2,10 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -151,9 +155,10 @@ func (q *Queries) DeleteUserByID(ctx context.Context, id int64) (int64, error) {
 // Производители
 
 type ListVendorsRow struct {
-	ID      int64
-	Name    string
-	Country string
+	ID           int64
+	Name         string
+	Country      string
+	CustomFields []byte
 }
 
 func (q *Queries) ListVendors(ctx context.Context) ([]ListVendorsRow, error) {
@@ -166,7 +171,7 @@ func (q *Queries) ListVendors(ctx context.Context) ([]ListVendorsRow, error) {
 	var items []ListVendorsRow
 	for rows.Next() {
 		var it ListVendorsRow
-		if err := rows.Scan(&it.ID, &it.Name, &it.Country); err != nil {
+		if err := rows.Scan(&it.ID, &it.Name, &it.Country, &it.CustomFields); err != nil {
 			return nil, err
 		}
 		items = append(items, it)
@@ -217,11 +222,17 @@ func (q *Queries) GetFirstVendorID(ctx context.Context) (int64, error) {
 // Модели
 
 type ListModelsRow struct {
-	ID         int64
-	VendorID   int64
-	VendorName string
-	Name       string
-	DeviceType string
+	ID           int64
+	VendorID     int64
+	VendorName   string
+	Name         string
+	DeviceType   string
+	CustomFields []byte
+	HeightU      int32
+	FullDepth    bool
+	Lifecycle    ModelLifecycle
+	// ReplacementModelName — «вендор модель» рекомендуемой замены.
+	ReplacementModelName string
 }
 
 func (q *Queries) ListModels(ctx context.Context) ([]ListModelsRow, error) {
@@ -234,7 +245,8 @@ func (q *Queries) ListModels(ctx context.Context) ([]ListModelsRow, error) {
 	var items []ListModelsRow
 	for rows.Next() {
 		var it ListModelsRow
-		if err := rows.Scan(&it.ID, &it.VendorID, &it.VendorName, &it.Name, &it.DeviceType); err != nil {
+		if err := rows.Scan(&it.ID, &it.VendorID, &it.VendorName, &it.Name, &it.DeviceType, &it.CustomFields, &it.HeightU, &it.FullDepth,
+			&it.Lifecycle.EndOfSale, &it.Lifecycle.EndOfSupport, &it.Lifecycle.EndOfLife, &it.Lifecycle.ReplacementModelID, &it.ReplacementModelName); err != nil {
 			return nil, err
 		}
 		items = append(items, it)
@@ -245,15 +257,15 @@ func (q *Queries) ListModels(ctx context.Context) ([]ListModelsRow, error) {
 	return items, nil
 }
 
-func (q *Queries) CreateModel(ctx context.Context, vendorID int64, name string, deviceType any) (int64, error) {
-	row := q.db.QueryRow(ctx, sql("CreateModel"), vendorID, name, deviceType)
+func (q *Queries) CreateModel(ctx context.Context, vendorID int64, name string, deviceType any, size ModelRackSize, lc ModelLifecycle) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateModel"), vendorID, name, deviceType, size.HeightU, size.FullDepth, lc.EndOfSale, lc.EndOfSupport, lc.EndOfLife, lc.ReplacementModelID)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
-func (q *Queries) UpdateModel(ctx context.Context, id int64, vendorID int64, name string, deviceType any) (int64, error) {
-	cmd, err := q.db.Exec(ctx, sql("UpdateModel"), vendorID, name, deviceType, id)
+func (q *Queries) UpdateModel(ctx context.Context, id int64, vendorID int64, name string, deviceType any, size ModelRackSize, lc ModelLifecycle) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateModel"), vendorID, name, deviceType, size.HeightU, size.FullDepth, lc.EndOfSale, lc.EndOfSupport, lc.EndOfLife, lc.ReplacementModelID, id)
 	if err != nil {
 		return 0, err
 	}
@@ -278,9 +290,57 @@ func (q *Queries) CountModels(ctx context.Context) (int64, error) {
 // Локации
 
 type ListLocationsRow struct {
-	ID   int64
-	Name string
-	Note string
+	ID           int64
+	Name         string
+	Note         string
+	CustomFields []byte
+	ParentID     *int64
+	Type         string
+	Path         string
+	RackHeightU  *int32
+	Geo          LocationGeo
+}
+
+type LocationTreeRow struct {
+	ID          int64
+	ParentID    *int64
+	Name        string
+	Type        string
+	Note        string
+	Depth       int32
+	RackHeightU *int32
+	DeviceCount int64
+}
+
+type LocationNode struct {
+	ID          int64
+	ParentID    *int64
+	Type        string
+	RackHeightU *int32
+	Geo         LocationGeo
+}
+
+// LocationGeo — координаты WGS 84 и адрес локации; пустой адрес хранится как NULL.
+type LocationGeo struct {
+	Latitude  *float64
+	Longitude *float64
+	Address   string
+}
+
+type LocationFeatureRow struct {
+	ID           int64
+	Name         string
+	Type         string
+	ParentID     *int64
+	Address      string
+	Latitude     float64
+	Longitude    float64
+	StatusCounts map[string]int64
+}
+
+// LocationBBox — окно карты в градусах; MinLon > MaxLon — окно через 180-й меридиан.
+type LocationBBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
 }
 
 func (q *Queries) ListLocations(ctx context.Context) ([]ListLocationsRow, error) {
@@ -293,7 +353,28 @@ func (q *Queries) ListLocations(ctx context.Context) ([]ListLocationsRow, error)
 	var items []ListLocationsRow
 	for rows.Next() {
 		var it ListLocationsRow
-		if err := rows.Scan(&it.ID, &it.Name, &it.Note); err != nil {
+		if err := rows.Scan(&it.ID, &it.Name, &it.Note, &it.CustomFields, &it.ParentID, &it.Type, &it.Path, &it.RackHeightU, &it.Geo.Latitude, &it.Geo.Longitude, &it.Geo.Address); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) ListLocationTree(ctx context.Context) ([]LocationTreeRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListLocationTree"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []LocationTreeRow
+	for rows.Next() {
+		var it LocationTreeRow
+		if err := rows.Scan(&it.ID, &it.ParentID, &it.Name, &it.Type, &it.Note, &it.Depth, &it.RackHeightU, &it.DeviceCount); err != nil {
 			return nil, err
 		}
 		items = append(items, it)
@@ -304,15 +385,15 @@ func (q *Queries) ListLocations(ctx context.Context) ([]ListLocationsRow, error)
 	return items, nil
 }
 
-func (q *Queries) CreateLocation(ctx context.Context, name string, note any) (int64, error) {
-	row := q.db.QueryRow(ctx, sql("CreateLocation"), name, note)
+func (q *Queries) CreateLocation(ctx context.Context, name string, note any, parentID *int64, locationType string, rackHeightU *int32, geo LocationGeo) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateLocation"), name, note, parentID, locationType, rackHeightU, geo.Latitude, geo.Longitude, optionalText(geo.Address))
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
-func (q *Queries) UpdateLocation(ctx context.Context, id int64, name string, note any) (int64, error) {
-	cmd, err := q.db.Exec(ctx, sql("UpdateLocation"), name, note, id)
+func (q *Queries) UpdateLocation(ctx context.Context, id int64, name string, note any, parentID *int64, locationType string, rackHeightU *int32, geo LocationGeo) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateLocation"), name, note, parentID, locationType, rackHeightU, geo.Latitude, geo.Longitude, optionalText(geo.Address), id)
 	if err != nil {
 		return 0, err
 	}
@@ -334,6 +415,78 @@ func (q *Queries) CountLocations(ctx context.Context) (int64, error) {
 	return cnt, err
 }
 
+func (q *Queries) LocationExists(ctx context.Context, id int64) (bool, error) {
+	row := q.db.QueryRow(ctx, sql("LocationExists"), id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+func (q *Queries) GetLocationNode(ctx context.Context, id int64) (LocationNode, error) {
+	row := q.db.QueryRow(ctx, sql("GetLocationNode"), id)
+	var out LocationNode
+	err := row.Scan(&out.ID, &out.ParentID, &out.Type, &out.RackHeightU, &out.Geo.Latitude, &out.Geo.Longitude, &out.Geo.Address)
+	return out, err
+}
+
+// LockLocationTree сериализует изменения структуры дерева локаций до конца
+// транзакции.
+func (q *Queries) LockLocationTree(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, sql("LockLocationTree"))
+	return err
+}
+
+// LocationInSubtree сообщает, входит ли id в поддерево root (включая root).
+func (q *Queries) LocationInSubtree(ctx context.Context, root int64, id int64) (bool, error) {
+	row := q.db.QueryRow(ctx, sql("LocationInSubtree"), root, id)
+	var in bool
+	err := row.Scan(&in)
+	return in, err
+}
+
+func (q *Queries) ListChildLocationTypes(ctx context.Context, id int64) ([]string, error) {
+	return q.listStrings(ctx, "ListChildLocationTypes", id)
+}
+
+// ReparentChildLocations переносит дочерние локации id под parentID (nil — в корень).
+func (q *Queries) ReparentChildLocations(ctx context.Context, id int64, parentID *int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("ReparentChildLocations"), id, parentID)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// ListLocationFeatures возвращает локации с координатами; bbox == nil — все.
+func (q *Queries) ListLocationFeatures(ctx context.Context, bbox *LocationBBox) ([]LocationFeatureRow, error) {
+	var minLon, minLat, maxLon, maxLat *float64
+	if bbox != nil {
+		minLon, minLat, maxLon, maxLat = &bbox.MinLon, &bbox.MinLat, &bbox.MaxLon, &bbox.MaxLat
+	}
+	rows, err := q.db.Query(ctx, sql("ListLocationFeatures"), minLon, minLat, maxLon, maxLat)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []LocationFeatureRow
+	for rows.Next() {
+		var it LocationFeatureRow
+		var counts []byte
+		if err := rows.Scan(&it.ID, &it.Name, &it.Type, &it.ParentID, &it.Address, &it.Latitude, &it.Longitude, &counts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(counts, &it.StatusCounts); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
 // Устройства
 
 type ListDevicesRow struct {
@@ -345,6 +498,10 @@ type ListDevicesRow struct {
 	InventoryNumber string
 	Status          string
 	InstalledAt     string
+	Description     string
+	Hostname        string
+	ManagementIP    string
+	Rank            float64
 }
 
 type GetDeviceByIDRow struct {
@@ -356,31 +513,191 @@ type GetDeviceByIDRow struct {
 	Status          string
 	InstalledAt     string
 	Description     string
+	Version         int64
+	UpdatedAt       time.Time
+	CustomFields    []byte
+	Hostname        string
+	ManagementIP    string
+	IPAddresses     []string
+	MACAddresses    []string
+	RackPositionU   *int32
+	RackFace        string
+	// Coverage — действующий контракт; nil, если сейчас устройство не покрыто.
+	Coverage            *DeviceCoverage
+	HasExpiredContracts bool
+}
+
+// DeviceFilter — структурированные фильтры списка устройств. nil/пустые значения
+// означают «без фильтра».
+type DeviceFilter struct {
+	Query         string
+	VendorID      *int64
+	ModelID       *int64
+	LocationID    *int64
+	Statuses      []string
+	InstalledFrom *time.Time
+	InstalledTo   *time.Time
+	// CustomFields — точные совпадения пользовательских полей: ключ → значение
+	// в хранимом виде (строка, int64 или bool), проверяется оператором @>.
+	CustomFields map[string]any
+	// IP — адрес устройства (маска не учитывается), CIDR — подсеть, в которую
+	// входит адрес, MAC — MAC-адрес; все в нормализованной записи.
+	IP   string
+	CIDR string
+	MAC  string
+}
+
+func (f DeviceFilter) args() []any {
+	var statuses any
+	if len(f.Statuses) > 0 {
+		statuses = f.Statuses
+	}
+	var customFields any
+	if len(f.CustomFields) > 0 {
+		// Ошибка невозможна: строки, числа и bool всегда сериализуются.
+		customFields, _ = json.Marshal(f.CustomFields)
+	}
+	return []any{f.Query, f.VendorID, f.ModelID, f.LocationID, statuses, f.InstalledFrom, f.InstalledTo, customFields, optionalText(f.IP), optionalText(f.CIDR), optionalText(f.MAC)}
+}
+
+// optionalText превращает пустую строку в NULL-параметр.
+func optionalText(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// DevicePage задаёт сортировку и keyset-позицию страницы. AfterKey/AfterID —
+// значение сортировки и id последней строки предыдущей страницы.
+type DevicePage struct {
+	Sort     string
+	Desc     bool
+	Limit    int32
+	After    bool
+	AfterKey string
+	AfterID  int64
+}
+
+type deviceSortColumn struct {
+	column string
+	cast   string
+	key    func(ListDevicesRow) string
+}
+
+// Колонки — выходные колонки ListDevices, поэтому keyset-сравнение идёт по тем же
+// значениям, что и ORDER BY.
+var deviceSortColumns = map[string]deviceSortColumn{
+	"id":              {column: "", key: func(ListDevicesRow) string { return "" }},
+	"vendorName":      {column: "vendor_name", cast: "text", key: func(r ListDevicesRow) string { return r.VendorName }},
+	"modelName":       {column: "model_name", cast: "text", key: func(r ListDevicesRow) string { return r.ModelName }},
+	"locationName":    {column: "location_name", cast: "text", key: func(r ListDevicesRow) string { return r.LocationName }},
+	"serialNumber":    {column: "serial_number", cast: "text", key: func(r ListDevicesRow) string { return r.SerialNumber }},
+	"inventoryNumber": {column: "inventory_number", cast: "text", key: func(r ListDevicesRow) string { return r.InventoryNumber }},
+	"status":          {column: "status", cast: "text", key: func(r ListDevicesRow) string { return r.Status }},
+	"installedAt":     {column: "installed_at", cast: "text", key: func(r ListDevicesRow) string { return r.InstalledAt }},
+	"relevance":       {column: "rank", cast: "float8", key: func(r ListDevicesRow) string { return strconv.FormatFloat(r.Rank, 'g', -1, 64) }},
+}
+
+// DeviceSortValid сообщает, поддерживается ли сортировка по колонке sort.
+func DeviceSortValid(sort string) bool {
+	_, ok := deviceSortColumns[sort]
+	return ok
+}
+
+// DeviceSortKey возвращает значение колонки сортировки для строки (для page token).
+func DeviceSortKey(sort string, row ListDevicesRow) string {
+	col, ok := deviceSortColumns[sort]
+	if !ok {
+		return ""
+	}
+	return col.key(row)
+}
+
+// listDevicesSQL оборачивает ListDevices в keyset-условие, ORDER BY и, если
+// p.Limit > 0, LIMIT.
+func listDevicesSQL(f DeviceFilter, p DevicePage) (string, []any, error) {
+	col, ok := deviceSortColumns[p.Sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported device sort: %q", p.Sort)
+	}
+
+	args := f.args()
+	dir, cmp := "ASC", ">"
+	if p.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT * FROM (\n")
+	b.WriteString(strings.TrimSuffix(sql("ListDevices"), ";"))
+	b.WriteString("\n) t")
+	if p.After {
+		if col.column == "" {
+			args = append(args, p.AfterID)
+			fmt.Fprintf(&b, "\nWHERE t.id %s $%d", cmp, len(args))
+		} else {
+			args = append(args, p.AfterKey, p.AfterID)
+			fmt.Fprintf(&b, "\nWHERE (t.%s, t.id) %s ($%d::%s, $%d::bigint)", col.column, cmp, len(args)-1, col.cast, len(args))
+		}
+	}
+	if col.column == "" {
+		fmt.Fprintf(&b, "\nORDER BY t.id %s", dir)
+	} else {
+		fmt.Fprintf(&b, "\nORDER BY t.%s %s, t.id %s", col.column, dir, dir)
+	}
+	if p.Limit > 0 {
+		args = append(args, p.Limit)
+		fmt.Fprintf(&b, "\nLIMIT $%d", len(args))
+	}
+	return b.String(), args, nil
+}
+
+func (q *Queries) ListDevices(ctx context.Context, f DeviceFilter, p DevicePage) ([]ListDevicesRow, error) {
+	var items []ListDevicesRow
+	err := q.EachDevice(ctx, f, p, func(it ListDevicesRow) error {
+		items = append(items, it)
+		return nil
+	})
+	return items, err
 }
 
-func (q *Queries) ListDevices(ctx context.Context, query string) ([]ListDevicesRow, error) {
-	rows, err := q.db.Query(ctx, sql("ListDevices"), query)
+// EachDevice вызывает fn для каждой строки ListDevices по мере чтения из БД,
+// не накапливая результат в памяти (для выгрузок).
+func (q *Queries) EachDevice(ctx context.Context, f DeviceFilter, p DevicePage, fn func(ListDevicesRow) error) error {
+	query, args, err := listDevicesSQL(f, p)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	rows, err := q.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
 	}
 	defer rows.Close()
 
-	var items []ListDevicesRow
 	for rows.Next() {
 		var it ListDevicesRow
-		if err := rows.Scan(&it.ID, &it.VendorName, &it.ModelName, &it.LocationName, &it.SerialNumber, &it.InventoryNumber, &it.Status, &it.InstalledAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&it.ID, &it.VendorName, &it.ModelName, &it.LocationName, &it.SerialNumber, &it.InventoryNumber, &it.Status, &it.InstalledAt, &it.Description, &it.Hostname, &it.ManagementIP, &it.Rank); err != nil {
+			return err
+		}
+		if err := fn(it); err != nil {
+			return err
 		}
-		items = append(items, it)
-	}
-	if rows.Err() != nil {
-		return nil, rows.Err()
 	}
-	return items, nil
+	return rows.Err()
+}
+
+func (q *Queries) CountDevices(ctx context.Context, f DeviceFilter) (int64, error) {
+	query := "SELECT COUNT(*) FROM (" + strings.TrimSuffix(sql("ListDevices"), ";") + ") t"
+	row := q.db.QueryRow(ctx, query, f.args()...)
+	var cnt int64
+	err := row.Scan(&cnt)
+	return cnt, err
 }
 
-func (q *Queries) CreateDevice(ctx context.Context, modelID int64, locationID *int64, serialNumber any, inventoryNumber any, status string, installedAt any, description any) (int64, error) {
-	row := q.db.QueryRow(ctx, sql("CreateDevice"), modelID, locationID, serialNumber, inventoryNumber, status, installedAt, description)
+func (q *Queries) CreateDevice(ctx context.Context, modelID int64, locationID *int64, serialNumber any, inventoryNumber any, status string, installedAt any, description any, hostname any, rackPositionU *int32, rackFace any) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateDevice"), modelID, locationID, serialNumber, inventoryNumber, status, installedAt, description, hostname, rackPositionU, rackFace)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
@@ -389,20 +706,104 @@ func (q *Queries) CreateDevice(ctx context.Context, modelID int64, locationID *i
 func (q *Queries) GetDeviceByID(ctx context.Context, id int64) (GetDeviceByIDRow, error) {
 	row := q.db.QueryRow(ctx, sql("GetDeviceByID"), id)
 	var out GetDeviceByIDRow
-	err := row.Scan(&out.ID, &out.ModelID, &out.LocationID, &out.SerialNumber, &out.InventoryNumber, &out.Status, &out.InstalledAt, &out.Description)
+	// Колонки покрытия все NULL, если действующего контракта нет.
+	var covID *int64
+	var covNumber, covSupplier, covKind, covLevel, covEndsOn *string
+	var covDaysLeft *int32
+	err := row.Scan(&out.ID, &out.ModelID, &out.LocationID, &out.SerialNumber, &out.InventoryNumber, &out.Status, &out.InstalledAt, &out.Description, &out.Version, &out.UpdatedAt, &out.CustomFields, &out.Hostname, &out.ManagementIP, &out.IPAddresses, &out.MACAddresses, &out.RackPositionU, &out.RackFace,
+		&covID, &covNumber, &covSupplier, &covKind, &covLevel, &covEndsOn, &covDaysLeft, &out.HasExpiredContracts)
+	if err == nil && covID != nil {
+		out.Coverage = &DeviceCoverage{
+			ContractID:     *covID,
+			ContractNumber: *covNumber,
+			Supplier:       *covSupplier,
+			Kind:           *covKind,
+			CoverageLevel:  *covLevel,
+			EndsOn:         *covEndsOn,
+			DaysLeft:       *covDaysLeft,
+		}
+	}
 	return out, err
 }
 
-func (q *Queries) UpdateDevice(ctx context.Context, id int64, modelID int64, locationID *int64, serialNumber any, inventoryNumber any, status string, installedAt any, description any) (int64, error) {
-	cmd, err := q.db.Exec(ctx, sql("UpdateDevice"), modelID, locationID, serialNumber, inventoryNumber, status, installedAt, description, id)
+func (q *Queries) UpdateDevice(ctx context.Context, id int64, modelID int64, locationID *int64, serialNumber any, inventoryNumber any, status string, installedAt any, description any, hostname any, rackPositionU *int32, rackFace any) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("UpdateDevice"), modelID, locationID, serialNumber, inventoryNumber, status, installedAt, description, hostname, rackPositionU, rackFace, id)
 	if err != nil {
 		return 0, err
 	}
 	return cmd.RowsAffected(), nil
 }
 
-func (q *Queries) DeleteDevice(ctx context.Context, id int64) (int64, error) {
-	cmd, err := q.db.Exec(ctx, sql("DeleteDevice"), id)
+// LockDevices блокирует строки устройств ids до конца транзакции.
+func (q *Queries) LockDevices(ctx context.Context, ids []int64) error {
+	_, err := q.db.Exec(ctx, sql("LockDevices"), ids)
+	return err
+}
+
+func (q *Queries) DeviceExists(ctx context.Context, id int64) (bool, error) {
+	row := q.db.QueryRow(ctx, sql("DeviceExists"), id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+func (q *Queries) ListExistingDeviceSerialKeys(ctx context.Context, keys []string) ([]string, error) {
+	return q.listStrings(ctx, "ListExistingDeviceSerialKeys", keys)
+}
+
+func (q *Queries) ListExistingDeviceInventoryKeys(ctx context.Context, keys []string) ([]string, error) {
+	return q.listStrings(ctx, "ListExistingDeviceInventoryKeys", keys)
+}
+
+func (q *Queries) listStrings(ctx context.Context, name string, args ...any) ([]string, error) {
+	rows, err := q.db.Query(ctx, sql(name), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []string
+	for rows.Next() {
+		var it string
+		if err := rows.Scan(&it); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+type FindDeviceRow struct {
+	ID      int64
+	InTrash bool
+}
+
+// FindDeviceBySerial ищет другое устройство (id <> excludeID) с тем же
+// нормализованным серийным номером, включая устройства в корзине.
+func (q *Queries) FindDeviceBySerial(ctx context.Context, serial string, excludeID int64) (FindDeviceRow, error) {
+	row := q.db.QueryRow(ctx, sql("FindDeviceBySerial"), serial, excludeID)
+	var out FindDeviceRow
+	err := row.Scan(&out.ID, &out.InTrash)
+	return out, err
+}
+
+func (q *Queries) FindDeviceByInventoryNumber(ctx context.Context, inventoryNumber string, excludeID int64) (FindDeviceRow, error) {
+	row := q.db.QueryRow(ctx, sql("FindDeviceByInventoryNumber"), inventoryNumber, excludeID)
+	var out FindDeviceRow
+	err := row.Scan(&out.ID, &out.InTrash)
+	return out, err
+}
+
+func (q *Queries) LockDeviceInventoryNumber(ctx context.Context, inventoryNumber string) error {
+	_, err := q.db.Exec(ctx, sql("LockDeviceInventoryNumber"), inventoryNumber)
+	return err
+}
+
+func (q *Queries) PatchDevice(ctx context.Context, id int64, locationID *int64, status string, description any) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("PatchDevice"), id, locationID, status, description)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Стойки и размещение устройств по юнитам
+
+type RackRow struct {
+	ID      int64
+	Name    string
+	Type    string
+	HeightU *int32
+}
+
+type RackLock struct {
+	Type    string
+	HeightU *int32
+}
+
+type ModelRackSize struct {
+	HeightU   int32
+	FullDepth bool
+}
+
+// RackPlacement — занимаемые устройством юниты FirstU..LastU на стороне Face.
+type RackPlacement struct {
+	RackID    int64
+	DeviceID  int64
+	FirstU    int32
+	LastU     int32
+	Face      string
+	FullDepth bool
+}
+
+type RackDeviceRow struct {
+	ID           int64
+	Hostname     string
+	VendorName   string
+	ModelName    string
+	SerialNumber string
+	Status       string
+	PositionU    *int32
+	Face         string
+	HeightU      int32
+	FullDepth    bool
+}
+
+// LockRack блокирует строку локации-стойки до конца транзакции.
+func (q *Queries) LockRack(ctx context.Context, id int64) (RackLock, error) {
+	row := q.db.QueryRow(ctx, sql("LockRack"), id)
+	var out RackLock
+	err := row.Scan(&out.Type, &out.HeightU)
+	return out, err
+}
+
+func (q *Queries) GetRack(ctx context.Context, id int64) (RackRow, error) {
+	row := q.db.QueryRow(ctx, sql("GetRack"), id)
+	var out RackRow
+	err := row.Scan(&out.ID, &out.Name, &out.Type, &out.HeightU)
+	return out, err
+}
+
+func (q *Queries) GetModelRackSize(ctx context.Context, modelID int64) (ModelRackSize, error) {
+	row := q.db.QueryRow(ctx, sql("GetModelRackSize"), modelID)
+	var out ModelRackSize
+	err := row.Scan(&out.HeightU, &out.FullDepth)
+	return out, err
+}
+
+// scanOptionalID читает id из запроса «первый подходящий»; nil — таких нет.
+func scanOptionalID(row pgx.Row) (*int64, error) {
+	var id int64
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &id, nil
+}
+
+// FindRackOverlap возвращает id устройства, уже занимающего часть юнитов p.
+func (q *Queries) FindRackOverlap(ctx context.Context, p RackPlacement) (*int64, error) {
+	return scanOptionalID(q.db.QueryRow(ctx, sql("FindRackOverlap"), p.RackID, p.DeviceID, p.FirstU, p.LastU, p.Face, p.FullDepth))
+}
+
+// FindRackDeviceAbove возвращает id устройства стойки, выходящего за высоту heightU.
+func (q *Queries) FindRackDeviceAbove(ctx context.Context, rackID int64, heightU int32) (*int64, error) {
+	return scanOptionalID(q.db.QueryRow(ctx, sql("FindRackDeviceAbove"), rackID, heightU))
+}
+
+func (q *Queries) LockModelRacks(ctx context.Context, modelID int64) error {
+	_, err := q.db.Exec(ctx, sql("LockModelRacks"), modelID)
+	return err
+}
+
+// FindModelRackConflict возвращает id устройства модели, которое перестанет
+// помещаться в стойку при новых размерах модели.
+func (q *Queries) FindModelRackConflict(ctx context.Context, modelID int64, size ModelRackSize) (*int64, error) {
+	return scanOptionalID(q.db.QueryRow(ctx, sql("FindModelRackConflict"), modelID, size.HeightU, size.FullDepth))
+}
+
+func (q *Queries) ListRackDevices(ctx context.Context, rackID int64) ([]RackDeviceRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListRackDevices"), rackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []RackDeviceRow
+	for rows.Next() {
+		var it RackDeviceRow
+		if err := rows.Scan(&it.ID, &it.Hostname, &it.VendorName, &it.ModelName, &it.SerialNumber, &it.Status, &it.PositionU, &it.Face, &it.HeightU, &it.FullDepth); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) ClearDeviceRackPlacement(ctx context.Context, deviceID int64) error {
+	_, err := q.db.Exec(ctx, sql("ClearDeviceRackPlacement"), deviceID)
+	return err
+}
+
+// ListRackPlacedDevices возвращает id устройств, занимающих места в стойке, и
+// блокирует их строки.
+func (q *Queries) ListRackPlacedDevices(ctx context.Context, rackID int64) ([]int64, error) {
+	rows, err := q.db.Query(ctx, sql("ListRackPlacedDevices"), rackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return ids, nil
+}
+
+// ClearRackPlacements снимает с мест все устройства стойки, включая корзину.
+func (q *Queries) ClearRackPlacements(ctx context.Context, rackID int64) error {
+	_, err := q.db.Exec(ctx, sql("ClearRackPlacements"), rackID)
+	return err
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Складской учёт несерийных позиций
+
+type StockItemRow struct {
+	ID           int64
+	ModelID      int64
+	VendorName   string
+	ModelName    string
+	DeviceType   string
+	LocationID   int64
+	LocationPath string
+	Quantity     int32
+	MinQuantity  int32
+	Note         string
+	UpdatedAt    time.Time
+}
+
+type StockItem struct {
+	ID          int64
+	ModelID     int64
+	LocationID  int64
+	Quantity    int32
+	MinQuantity int32
+	Note        string
+}
+
+type StockItemFilter struct {
+	ModelID    *int64
+	LocationID *int64
+	Query      string
+	// LowOnly — только позиции, где остаток ниже минимального.
+	LowOnly bool
+}
+
+// StockLock — заблокированный остаток модели в локации.
+type StockLock struct {
+	ID         int64
+	LocationID int64
+	Quantity   int32
+}
+
+// StockMovementParams — запись журнала; FromLocationID и ToLocationID
+// задаются в зависимости от вида движения, Reason и DocumentNumber
+// передаются как NULL, если пустые.
+type StockMovementParams struct {
+	Kind           string
+	ModelID        int64
+	FromLocationID *int64
+	ToLocationID   *int64
+	Quantity       int32
+	Reason         any
+	DocumentNumber any
+	Actor          string
+}
+
+type StockMovementRow struct {
+	ID               int64
+	Kind             string
+	ModelID          int64
+	VendorName       string
+	ModelName        string
+	FromLocationID   *int64
+	FromLocationPath string
+	ToLocationID     *int64
+	ToLocationPath   string
+	Quantity         int32
+	Reason           string
+	DocumentNumber   string
+	Actor            string
+	CreatedAt        time.Time
+}
+
+type StockMovementFilter struct {
+	ModelID    *int64
+	LocationID *int64
+	Kind       *string
+	Limit      int32
+	Offset     int32
+}
+
+func (q *Queries) ListStockItems(ctx context.Context, f StockItemFilter) ([]StockItemRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListStockItems"), f.ModelID, f.LocationID, f.Query, f.LowOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []StockItemRow
+	for rows.Next() {
+		var it StockItemRow
+		if err := rows.Scan(&it.ID, &it.ModelID, &it.VendorName, &it.ModelName, &it.DeviceType, &it.LocationID, &it.LocationPath,
+			&it.Quantity, &it.MinQuantity, &it.Note, &it.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) GetStockItem(ctx context.Context, id int64) (StockItem, error) {
+	row := q.db.QueryRow(ctx, sql("GetStockItem"), id)
+	var it StockItem
+	err := row.Scan(&it.ID, &it.ModelID, &it.LocationID, &it.Quantity, &it.MinQuantity, &it.Note)
+	return it, err
+}
+
+func (q *Queries) CreateStockItem(ctx context.Context, modelID, locationID int64, minQuantity int32, note any) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateStockItem"), modelID, locationID, minQuantity, note)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateStockItem(ctx context.Context, id int64, minQuantity int32, note any) error {
+	_, err := q.db.Exec(ctx, sql("UpdateStockItem"), id, minQuantity, note)
+	return err
+}
+
+// DeleteStockItem удаляет позицию с нулевым остатком; 0 — позиции нет или она не пуста.
+func (q *Queries) DeleteStockItem(ctx context.Context, id int64) (int64, error) {
+	cmd, err := q.db.Exec(ctx, sql("DeleteStockItem"), id)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (q *Queries) EnsureStockItem(ctx context.Context, modelID, locationID int64) error {
+	_, err := q.db.Exec(ctx, sql("EnsureStockItem"), modelID, locationID)
+	return err
+}
+
+// LockStockItems блокирует остатки модели в локациях до конца транзакции.
+// Локации без позиции в результат не попадают.
+func (q *Queries) LockStockItems(ctx context.Context, modelID int64, locationIDs []int64) ([]StockLock, error) {
+	rows, err := q.db.Query(ctx, sql("LockStockItems"), modelID, locationIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []StockLock
+	for rows.Next() {
+		var it StockLock
+		if err := rows.Scan(&it.ID, &it.LocationID, &it.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) AddStockQuantity(ctx context.Context, id int64, delta int32) error {
+	_, err := q.db.Exec(ctx, sql("AddStockQuantity"), id, delta)
+	return err
+}
+
+func (q *Queries) CreateStockMovement(ctx context.Context, p StockMovementParams) (int64, time.Time, error) {
+	row := q.db.QueryRow(ctx, sql("CreateStockMovement"), p.Kind, p.ModelID, p.FromLocationID, p.ToLocationID, p.Quantity, p.Reason, p.DocumentNumber, p.Actor)
+	var id int64
+	var createdAt time.Time
+	err := row.Scan(&id, &createdAt)
+	return id, createdAt, err
+}
+
+func (q *Queries) ListStockMovements(ctx context.Context, f StockMovementFilter) ([]StockMovementRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListStockMovements"), f.ModelID, f.LocationID, f.Kind, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []StockMovementRow
+	for rows.Next() {
+		var it StockMovementRow
+		if err := rows.Scan(&it.ID, &it.Kind, &it.ModelID, &it.VendorName, &it.ModelName, &it.FromLocationID, &it.FromLocationPath,
+			&it.ToLocationID, &it.ToLocationPath, &it.Quantity, &it.Reason, &it.DocumentNumber, &it.Actor, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
@@ -51,6 +51,11 @@ func NewDB(db DBTX) *Queries {
 	return &Queries{db: db}
 }
 
+// WithTx возвращает Queries, выполняющие запросы в рамках транзакции tx.
+func (q *Queries) WithTx(tx pgx.Tx) *Queries {
+	return &Queries{db: tx}
+}
+
 func resolveQueriesDir() (string, error) {
 	if v := strings.TrimSpace(os.Getenv("TELECOMBASE_SQL_DIR")); v != "" {
 		return v, nil
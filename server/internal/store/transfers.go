@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Перемещения устройств между локациями
+
+type DeviceTransferRow struct {
+	ID             int64
+	ToLocationID   *int64
+	ToLocationName string
+	Reason         string
+	DocumentNumber string
+	Actor          string
+	CreatedAt      time.Time
+	DeviceCount    int64
+}
+
+type DeviceTransferItemRow struct {
+	DeviceID         *int64
+	Hostname         string
+	VendorName       string
+	ModelName        string
+	SerialNumber     string
+	InventoryNumber  string
+	FromLocationID   *int64
+	FromLocationName string
+}
+
+type DeviceTransferFilter struct {
+	DeviceID   *int64
+	LocationID *int64
+	Limit      int32
+	Offset     int32
+}
+
+// CreateDeviceTransfer заводит документ перемещения; Reason и DocumentNumber
+// передаются как NULL, если пустые.
+func (q *Queries) CreateDeviceTransfer(ctx context.Context, toLocationID *int64, reason, documentNumber any, actor string) (int64, time.Time, error) {
+	row := q.db.QueryRow(ctx, sql("CreateDeviceTransfer"), toLocationID, reason, documentNumber, actor)
+	var id int64
+	var createdAt time.Time
+	err := row.Scan(&id, &createdAt)
+	return id, createdAt, err
+}
+
+func (q *Queries) AddDeviceTransferItem(ctx context.Context, transferID, deviceID int64, fromLocationID *int64) error {
+	_, err := q.db.Exec(ctx, sql("AddDeviceTransferItem"), transferID, deviceID, fromLocationID)
+	return err
+}
+
+func scanDeviceTransfer(row pgx.Row) (DeviceTransferRow, error) {
+	var it DeviceTransferRow
+	err := row.Scan(&it.ID, &it.ToLocationID, &it.ToLocationName, &it.Reason, &it.DocumentNumber, &it.Actor, &it.CreatedAt, &it.DeviceCount)
+	return it, err
+}
+
+func (q *Queries) ListDeviceTransfers(ctx context.Context, f DeviceTransferFilter) ([]DeviceTransferRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceTransfers"), f.DeviceID, f.LocationID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DeviceTransferRow
+	for rows.Next() {
+		it, err := scanDeviceTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+func (q *Queries) GetDeviceTransfer(ctx context.Context, id int64) (DeviceTransferRow, error) {
+	return scanDeviceTransfer(q.db.QueryRow(ctx, sql("GetDeviceTransfer"), id))
+}
+
+func (q *Queries) ListDeviceTransferItems(ctx context.Context, transferID int64) ([]DeviceTransferItemRow, error) {
+	rows, err := q.db.Query(ctx, sql("ListDeviceTransferItems"), transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DeviceTransferItemRow
+	for rows.Next() {
+		var it DeviceTransferItemRow
+		if err := rows.Scan(&it.DeviceID, &it.Hostname, &it.VendorName, &it.ModelName, &it.SerialNumber, &it.InventoryNumber,
+			&it.FromLocationID, &it.FromLocationName); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Заявки на обслуживание
+
+// WorkOrderRow — заявка со снимком устройства на момент открытия; DeviceID
+// пустой, если устройство окончательно удалено.
+type WorkOrderRow struct {
+	ID              int64
+	DeviceID        *int64
+	DeviceHostname  string
+	VendorName      string
+	ModelName       string
+	SerialNumber    string
+	InventoryNumber string
+	Type            string
+	State           string
+	Title           string
+	Notes           string
+	Resolution      string
+	AssigneeID      *int64
+	Assignee        string
+	ScheduledAt     *time.Time
+	ReturnStatus    string
+	CreatedBy       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	StartedAt       *time.Time
+	ClosedAt        *time.Time
+}
+
+// WorkOrderParams — изменяемые поля заявки; Notes передаётся как NULL, если пустое.
+type WorkOrderParams struct {
+	Type        string
+	Title       string
+	Notes       any
+	AssigneeID  *int64
+	ScheduledAt *time.Time
+}
+
+type WorkOrderFilter struct {
+	DeviceID   *int64
+	States     []string
+	Type       *string
+	AssigneeID *int64
+	LocationID *int64
+	Limit      int32
+	Offset     int32
+}
+
+func scanWorkOrder(row pgx.Row) (WorkOrderRow, error) {
+	var it WorkOrderRow
+	err := row.Scan(&it.ID, &it.DeviceID, &it.DeviceHostname, &it.VendorName, &it.ModelName, &it.SerialNumber, &it.InventoryNumber, &it.Type, &it.State, &it.Title, &it.Notes, &it.Resolution,
+		&it.AssigneeID, &it.Assignee, &it.ScheduledAt, &it.ReturnStatus, &it.CreatedBy, &it.CreatedAt, &it.UpdatedAt, &it.StartedAt, &it.ClosedAt)
+	return it, err
+}
+
+func (q *Queries) ListWorkOrders(ctx context.Context, f WorkOrderFilter) ([]WorkOrderRow, error) {
+	var states any
+	if len(f.States) > 0 {
+		states = f.States
+	}
+	rows, err := q.db.Query(ctx, sql("ListWorkOrders"), f.DeviceID, states, f.Type, f.AssigneeID, f.LocationID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []WorkOrderRow
+	for rows.Next() {
+		it, err := scanWorkOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return items, nil
+}
+
+// GetWorkOrder читает заявку и блокирует её строку до конца транзакции.
+func (q *Queries) GetWorkOrder(ctx context.Context, id int64) (WorkOrderRow, error) {
+	return scanWorkOrder(q.db.QueryRow(ctx, sql("GetWorkOrder"), id))
+}
+
+func (q *Queries) CreateWorkOrder(ctx context.Context, deviceID int64, p WorkOrderParams, returnStatus any, createdBy string) (int64, error) {
+	row := q.db.QueryRow(ctx, sql("CreateWorkOrder"), deviceID, p.Type, p.Title, p.Notes, p.AssigneeID, p.ScheduledAt, returnStatus, createdBy)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateWorkOrder(ctx context.Context, id int64, p WorkOrderParams) error {
+	_, err := q.db.Exec(ctx, sql("UpdateWorkOrder"), id, p.Type, p.Title, p.Notes, p.AssigneeID, p.ScheduledAt)
+	return err
+}
+
+// SetWorkOrderState меняет состояние заявки; resolution == nil оставляет итог как есть.
+func (q *Queries) SetWorkOrderState(ctx context.Context, id int64, state string, resolution any) error {
+	_, err := q.db.Exec(ctx, sql("SetWorkOrderState"), id, state, resolution)
+	return err
+}
+
+func (q *Queries) UserExists(ctx context.Context, id int64) (bool, error) {
+	row := q.db.QueryRow(ctx, sql("UserExists"), id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+// SetWorkOrderReturnStatus запоминает статус, в который устройство вернётся при закрытии.
+func (q *Queries) SetWorkOrderReturnStatus(ctx context.Context, id int64, status string) error {
+	_, err := q.db.Exec(ctx, sql("SetWorkOrderReturnStatus"), id, status)
+	return err
+}
+
+func (q *Queries) CountOtherOpenWorkOrders(ctx context.Context, deviceID, excludeID int64) (int64, error) {
+	var n int64
+	err := q.db.QueryRow(ctx, sql("CountOtherOpenWorkOrders"), deviceID, excludeID).Scan(&n)
+	return n, err
+}
+
+// HandOverWorkOrderReturnStatus передаёт статус возврата закрываемой заявки
+// другой незакрытой заявке того же устройства.
+func (q *Queries) HandOverWorkOrderReturnStatus(ctx context.Context, deviceID, closingID int64, status string) error {
+	_, err := q.db.Exec(ctx, sql("HandOverWorkOrderReturnStatus"), deviceID, closingID, status)
+	return err
+}
@@ -0,0 +1,25 @@
+package ttf
+
+import (
+	_ "embed"
+)
+
+// Встроенная гарнитура Open Sans (Apache License 2.0, см. fonts/LICENSE.txt)
+// с латиницей и кириллицей: документы и наклейки не зависят от шрифтов системы.
+var (
+	//go:embed fonts/OpenSans-Regular.ttf
+	regularData []byte
+	//go:embed fonts/OpenSans-Bold.ttf
+	boldData []byte
+
+	Regular = mustParse(regularData)
+	Bold    = mustParse(boldData)
+)
+
+func mustParse(data []byte) *Font {
+	f, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
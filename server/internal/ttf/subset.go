@@ -0,0 +1,125 @@
+package ttf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// subsetTables — таблицы, которые остаются во встроенном в PDF шрифте.
+// Таблицы OpenType-раскладки (GPOS, GSUB) не нужны: PDF хранит уже
+// расставленные глифы; cmap, name и OS/2 оставлены для строгих просмотрщиков.
+var subsetTables = []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cvt ", "fpgm", "prep", "cmap", "name", "OS/2"}
+
+// Subset собирает шрифт, в котором остаются контуры только глифов gids (и
+// глифов, на которые они ссылаются). Номера глифов не меняются: у остальных
+// контуры пустые, поэтому PDF может ссылаться на глифы исходными номерами.
+func (f *Font) Subset(gids []uint16) []byte {
+	keep := make(map[uint16]bool, len(gids)+1)
+	var visit func(gid uint16, depth int)
+	visit = func(gid uint16, depth int) {
+		if keep[gid] || int(gid) >= f.NumGlyphs() || depth > maxComponentDepth {
+			return
+		}
+		keep[gid] = true
+		g := f.glyphData(gid)
+		if g == nil || int16(binary.BigEndian.Uint16(g)) >= 0 {
+			return
+		}
+		comps, err := parseComponents(g)
+		if err != nil {
+			return
+		}
+		for _, c := range comps {
+			visit(c.gid, depth+1)
+		}
+	}
+	visit(0, 0)
+	for _, gid := range gids {
+		visit(gid, 0)
+	}
+
+	be := binary.BigEndian
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(f.NumGlyphs()+1))
+	for gid := 0; gid < f.NumGlyphs(); gid++ {
+		be.PutUint32(loca[4*gid:], uint32(glyf.Len()))
+		if keep[uint16(gid)] {
+			glyf.Write(f.glyf[f.loca[gid]:f.loca[gid+1]])
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	be.PutUint32(loca[4*f.NumGlyphs():], uint32(glyf.Len()))
+
+	head := bytes.Clone(f.tables["head"])
+	be.PutUint32(head[8:], 0)
+	be.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{"head": head, "loca": loca, "glyf": glyf.Bytes()}
+	for _, tag := range subsetTables {
+		if tables[tag] == nil && f.tables[tag] != nil {
+			tables[tag] = f.tables[tag]
+		}
+	}
+	out := writeSfnt(tables)
+
+	// Контрольная сумма всего файла записывается в head, как того требует формат.
+	headOff := bytes.Index(out[:12+16*len(tables)], []byte("head"))
+	at := be.Uint32(out[headOff+8:])
+	be.PutUint32(out[at+8:], 0xB1B0AFBA-checksum(out))
+	return out
+}
+
+func writeSfnt(tables map[string][]byte) []byte {
+	be := binary.BigEndian
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	var out bytes.Buffer
+	header := make([]byte, 12+16*n)
+	be.PutUint32(header, 0x00010000)
+	be.PutUint16(header[4:], uint16(n))
+	be.PutUint16(header[6:], uint16(searchRange))
+	be.PutUint16(header[8:], uint16(entrySelector))
+	be.PutUint16(header[10:], uint16(16*n-searchRange))
+	offset := len(header)
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := header[12+16*i:]
+		copy(rec, tag)
+		be.PutUint32(rec[4:], checksum(data))
+		be.PutUint32(rec[8:], uint32(offset))
+		be.PutUint32(rec[12:], uint32(len(data)))
+		offset += (len(data) + 3) &^ 3
+	}
+	out.Write(header)
+	for _, tag := range tags {
+		out.Write(tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	return out.Bytes()
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,594 @@
+// Package ttf читает шрифты TrueType: метрики, таблицу символов и контуры
+// глифов. Этого хватает, чтобы встраивать шрифт в PDF (с урезанием до
+// использованных глифов) и рисовать текст в PNG и SVG без системных шрифтов.
+package ttf
+
+import (
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+var ErrInvalidFont = errors.New("ttf: invalid or unsupported font")
+
+// Font — разобранный шрифт. Размеры — в единицах шрифта (UnitsPerEm на кегль).
+type Font struct {
+	// PostScriptName — имя из таблицы name; под ним шрифт встраивается в PDF.
+	PostScriptName string
+	UnitsPerEm     int
+	Ascent         int
+	Descent        int
+	CapHeight      int
+	// BBox — общая рамка глифов: xMin, yMin, xMax, yMax.
+	BBox        [4]int
+	ItalicAngle float64
+
+	tables   map[string][]byte
+	cmap     map[rune]uint16
+	advances []uint16
+	loca     []uint32
+	glyf     []byte
+}
+
+// Point — точка контура; Y направлен вверх, как в шрифте.
+type Point struct {
+	X, Y float64
+}
+
+// Segment — участок контура от конца предыдущего до P: отрезок или
+// квадратичная кривая Безье с контрольной точкой C.
+type Segment struct {
+	Curve bool
+	C, P  Point
+}
+
+// Contour — замкнутый контур: начинается в Start, последний сегмент
+// возвращается в Start.
+type Contour struct {
+	Start    Point
+	Segments []Segment
+}
+
+// Parse разбирает шрифт TrueType (контуры glyf). data не копируется.
+func Parse(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, ErrInvalidFont
+	}
+	if v := binary.BigEndian.Uint32(data); v != 0x00010000 && v != 0x74727565 {
+		return nil, ErrInvalidFont
+	}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*n {
+		return nil, ErrInvalidFont
+	}
+	f := &Font{tables: make(map[string][]byte, n)}
+	for i := 0; i < n; i++ {
+		rec := data[12+16*i:]
+		off, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		if uint64(off)+uint64(length) > uint64(len(data)) {
+			return nil, ErrInvalidFont
+		}
+		f.tables[string(rec[:4])] = data[off : off+length]
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cmap"} {
+		if f.tables[tag] == nil {
+			return nil, ErrInvalidFont
+		}
+	}
+	if err := f.parseMetrics(); err != nil {
+		return nil, err
+	}
+	if err := f.parseCmap(); err != nil {
+		return nil, err
+	}
+	f.PostScriptName = parseName(f.tables["name"], 6)
+	if f.PostScriptName == "" {
+		f.PostScriptName = "Font"
+	}
+	return f, nil
+}
+
+func (f *Font) parseMetrics() error {
+	head, hhea, maxp := f.tables["head"], f.tables["hhea"], f.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return ErrInvalidFont
+	}
+	be := binary.BigEndian
+	f.UnitsPerEm = int(be.Uint16(head[18:]))
+	for i := range f.BBox {
+		f.BBox[i] = int(int16(be.Uint16(head[36+2*i:])))
+	}
+	f.Ascent = int(int16(be.Uint16(hhea[4:])))
+	f.Descent = int(int16(be.Uint16(hhea[6:])))
+	f.CapHeight = f.Ascent
+	if os2 := f.tables["OS/2"]; len(os2) >= 90 && be.Uint16(os2) >= 2 {
+		f.CapHeight = int(int16(be.Uint16(os2[88:])))
+	}
+	if post := f.tables["post"]; len(post) >= 8 {
+		f.ItalicAngle = float64(int32(be.Uint32(post[4:]))) / 65536
+	}
+	if f.UnitsPerEm == 0 {
+		return ErrInvalidFont
+	}
+
+	numGlyphs := int(be.Uint16(maxp[4:]))
+	numHMetrics := int(be.Uint16(hhea[34:]))
+	hmtx := f.tables["hmtx"]
+	if numHMetrics == 0 || numHMetrics > numGlyphs || len(hmtx) < 4*numHMetrics {
+		return ErrInvalidFont
+	}
+	f.advances = make([]uint16, numGlyphs)
+	for i := range f.advances {
+		// У глифов за numHMetrics ширина последней записи.
+		f.advances[i] = be.Uint16(hmtx[4*min(i, numHMetrics-1):])
+	}
+
+	loca := f.tables["loca"]
+	f.loca = make([]uint32, numGlyphs+1)
+	long := be.Uint16(head[50:]) == 1
+	for i := range f.loca {
+		switch {
+		case long && len(loca) >= 4*(i+1):
+			f.loca[i] = be.Uint32(loca[4*i:])
+		case !long && len(loca) >= 2*(i+1):
+			f.loca[i] = 2 * uint32(be.Uint16(loca[2*i:]))
+		default:
+			return ErrInvalidFont
+		}
+	}
+	f.glyf = f.tables["glyf"]
+	for i := 0; i < numGlyphs; i++ {
+		if f.loca[i] > f.loca[i+1] || int(f.loca[i+1]) > len(f.glyf) {
+			return ErrInvalidFont
+		}
+	}
+	return nil
+}
+
+// parseCmap читает юникодную подтаблицу символов формата 4 или 12.
+func (f *Font) parseCmap() error {
+	be := binary.BigEndian
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return ErrInvalidFont
+	}
+	var best []byte
+	bestRank := 0
+	for i := 0; i < int(be.Uint16(cmap[2:])); i++ {
+		rec := cmap[4+8*i:]
+		if len(rec) < 8 {
+			return ErrInvalidFont
+		}
+		platform, encoding, off := be.Uint16(rec), be.Uint16(rec[2:]), be.Uint32(rec[4:])
+		if int(off)+4 > len(cmap) {
+			continue
+		}
+		sub := cmap[off:]
+		format := be.Uint16(sub)
+		rank := 0
+		switch {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			rank = 2
+		case format == 4 && (platform == 3 && encoding == 1 || platform == 0):
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = sub, rank
+		}
+	}
+	f.cmap = make(map[rune]uint16)
+	switch bestRank {
+	case 2:
+		if len(best) < 16 {
+			return ErrInvalidFont
+		}
+		groups := int(be.Uint32(best[12:]))
+		if len(best) < 16+12*groups {
+			return ErrInvalidFont
+		}
+		for g := 0; g < groups; g++ {
+			rec := best[16+12*g:]
+			start, end, gid := be.Uint32(rec), be.Uint32(rec[4:]), be.Uint32(rec[8:])
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				f.cmap[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case 1:
+		if len(best) < 14 {
+			return ErrInvalidFont
+		}
+		segs := int(be.Uint16(best[6:])) / 2
+		if len(best) < 16+8*segs {
+			return ErrInvalidFont
+		}
+		ends, starts := best[14:], best[16+2*segs:]
+		deltas, offsets := best[16+4*segs:], best[16+6*segs:]
+		for s := 0; s < segs; s++ {
+			start, end := be.Uint16(starts[2*s:]), be.Uint16(ends[2*s:])
+			delta, ro := be.Uint16(deltas[2*s:]), int(be.Uint16(offsets[2*s:]))
+			for c := uint32(start); c <= uint32(end) && c != 0xFFFF; c++ {
+				gid := uint16(c) + delta
+				if ro != 0 {
+					at := 16 + 6*segs + 2*s + ro + 2*int(c-uint32(start))
+					if at+2 > len(best) {
+						return ErrInvalidFont
+					}
+					if gid = be.Uint16(best[at:]); gid != 0 {
+						gid += delta
+					}
+				}
+				if gid != 0 {
+					f.cmap[rune(c)] = gid
+				}
+			}
+		}
+	default:
+		return ErrInvalidFont
+	}
+	return nil
+}
+
+// parseName возвращает запись id таблицы name (Windows Unicode или Mac Roman).
+func parseName(name []byte, id uint16) string {
+	be := binary.BigEndian
+	if len(name) < 6 {
+		return ""
+	}
+	count, storage := int(be.Uint16(name[2:])), int(be.Uint16(name[4:]))
+	for i := 0; i < count; i++ {
+		rec := name[6+12*i:]
+		if len(rec) < 12 || be.Uint16(rec[6:]) != id {
+			continue
+		}
+		platform := be.Uint16(rec)
+		length, off := int(be.Uint16(rec[8:])), storage+int(be.Uint16(rec[10:]))
+		if off+length > len(name) {
+			continue
+		}
+		s := name[off : off+length]
+		switch platform {
+		case 3, 0:
+			u := make([]uint16, len(s)/2)
+			for j := range u {
+				u[j] = be.Uint16(s[2*j:])
+			}
+			return string(utf16.Decode(u))
+		case 1:
+			return string(s)
+		}
+	}
+	return ""
+}
+
+// NumGlyphs возвращает число глифов шрифта.
+func (f *Font) NumGlyphs() int {
+	return len(f.advances)
+}
+
+// GlyphIndex возвращает глиф символа r или 0 (.notdef), если его нет.
+func (f *Font) GlyphIndex(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// HasGlyph сообщает, есть ли в шрифте глиф для r.
+func (f *Font) HasGlyph(r rune) bool {
+	_, ok := f.cmap[r]
+	return ok
+}
+
+// Advance возвращает ширину глифа в единицах шрифта.
+func (f *Font) Advance(gid uint16) int {
+	if int(gid) >= len(f.advances) {
+		return 0
+	}
+	return int(f.advances[gid])
+}
+
+// glyphData возвращает описание глифа; у пустых глифов (пробел) — nil.
+func (f *Font) glyphData(gid uint16) []byte {
+	if int(gid) >= len(f.advances) {
+		return nil
+	}
+	g := f.glyf[f.loca[gid]:f.loca[gid+1]]
+	if len(g) < 10 {
+		return nil
+	}
+	return g
+}
+
+// Флаги составного глифа.
+const (
+	argsAreWords   = 0x0001
+	argsAreXY      = 0x0002
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+)
+
+// component — ссылка составного глифа на другой глиф с преобразованием.
+type component struct {
+	gid        uint16
+	dx, dy     float64
+	a, b, c, d float64
+}
+
+func parseComponents(g []byte) ([]component, error) {
+	be := binary.BigEndian
+	var out []component
+	p := 10
+	for {
+		if p+4 > len(g) {
+			return nil, ErrInvalidFont
+		}
+		flags := be.Uint16(g[p:])
+		comp := component{gid: be.Uint16(g[p+2:]), a: 1, d: 1}
+		p += 4
+		var x, y int
+		if flags&argsAreWords != 0 {
+			if p+4 > len(g) {
+				return nil, ErrInvalidFont
+			}
+			x, y = int(int16(be.Uint16(g[p:]))), int(int16(be.Uint16(g[p+2:])))
+			p += 4
+		} else {
+			if p+2 > len(g) {
+				return nil, ErrInvalidFont
+			}
+			x, y = int(int8(g[p])), int(int8(g[p+1]))
+			p += 2
+		}
+		// Привязка по номерам точек встречается редко; такие компоненты
+		// рисуются без сдвига.
+		if flags&argsAreXY != 0 {
+			comp.dx, comp.dy = float64(x), float64(y)
+		}
+		f2dot14 := func() float64 {
+			v := float64(int16(be.Uint16(g[p:]))) / 16384
+			p += 2
+			return v
+		}
+		switch {
+		case flags&haveScale != 0 && p+2 <= len(g):
+			comp.a = f2dot14()
+			comp.d = comp.a
+		case flags&haveXYScale != 0 && p+4 <= len(g):
+			comp.a, comp.d = f2dot14(), f2dot14()
+		case flags&haveTwoByTwo != 0 && p+8 <= len(g):
+			comp.a, comp.b, comp.c, comp.d = f2dot14(), f2dot14(), f2dot14(), f2dot14()
+		case flags&(haveScale|haveXYScale|haveTwoByTwo) != 0:
+			return nil, ErrInvalidFont
+		}
+		out = append(out, comp)
+		if flags&moreComponents == 0 {
+			return out, nil
+		}
+	}
+}
+
+// maxComponentDepth ограничивает вложенность составных глифов, чтобы
+// испорченный шрифт не зациклил разбор.
+const maxComponentDepth = 8
+
+// Outline возвращает контуры глифа в единицах шрифта.
+func (f *Font) Outline(gid uint16) ([]Contour, error) {
+	return f.outline(gid, 0)
+}
+
+func (f *Font) outline(gid uint16, depth int) ([]Contour, error) {
+	if depth > maxComponentDepth {
+		return nil, ErrInvalidFont
+	}
+	g := f.glyphData(gid)
+	if g == nil {
+		return nil, nil
+	}
+	n := int(int16(binary.BigEndian.Uint16(g)))
+	if n >= 0 {
+		return simpleOutline(g, n)
+	}
+	comps, err := parseComponents(g)
+	if err != nil {
+		return nil, err
+	}
+	var out []Contour
+	for _, comp := range comps {
+		sub, err := f.outline(comp.gid, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		tr := func(p Point) Point {
+			return Point{X: comp.a*p.X + comp.c*p.Y + comp.dx, Y: comp.b*p.X + comp.d*p.Y + comp.dy}
+		}
+		for _, c := range sub {
+			c.Start = tr(c.Start)
+			for i := range c.Segments {
+				c.Segments[i].C = tr(c.Segments[i].C)
+				c.Segments[i].P = tr(c.Segments[i].P)
+			}
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// Флаги точек простого глифа.
+const (
+	onCurve    = 0x01
+	xShort     = 0x02
+	yShort     = 0x04
+	repeatFlag = 0x08
+	xSame      = 0x10
+	ySame      = 0x20
+)
+
+func simpleOutline(g []byte, nContours int) ([]Contour, error) {
+	be := binary.BigEndian
+	p := 10
+	if p+2*nContours+2 > len(g) {
+		return nil, ErrInvalidFont
+	}
+	ends := make([]int, nContours)
+	for i := range ends {
+		ends[i] = int(be.Uint16(g[p:]))
+		p += 2
+	}
+	if nContours == 0 {
+		return nil, nil
+	}
+	numPoints := ends[nContours-1] + 1
+	p += 2 + int(be.Uint16(g[p:]))
+
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		if p >= len(g) {
+			return nil, ErrInvalidFont
+		}
+		fl := g[p]
+		p++
+		flags = append(flags, fl)
+		if fl&repeatFlag != 0 {
+			if p >= len(g) {
+				return nil, ErrInvalidFont
+			}
+			for r := g[p]; r > 0 && len(flags) < numPoints; r-- {
+				flags = append(flags, fl)
+			}
+			p++
+		}
+	}
+
+	coords := func(short, same byte) ([]float64, error) {
+		out := make([]float64, numPoints)
+		v := 0
+		for i, fl := range flags {
+			switch {
+			case fl&short != 0:
+				if p >= len(g) {
+					return nil, ErrInvalidFont
+				}
+				d := int(g[p])
+				p++
+				if fl&same == 0 {
+					d = -d
+				}
+				v += d
+			case fl&same == 0:
+				if p+2 > len(g) {
+					return nil, ErrInvalidFont
+				}
+				v += int(int16(be.Uint16(g[p:])))
+				p += 2
+			}
+			out[i] = float64(v)
+		}
+		return out, nil
+	}
+	xs, err := coords(xShort, xSame)
+	if err != nil {
+		return nil, err
+	}
+	ys, err := coords(yShort, ySame)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Contour, 0, nContours)
+	start := 0
+	for _, end := range ends {
+		if end < start || end >= numPoints {
+			return nil, ErrInvalidFont
+		}
+		pts := make([]Point, 0, end-start+1)
+		on := make([]bool, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			pts = append(pts, Point{X: xs[i], Y: ys[i]})
+			on = append(on, flags[i]&onCurve != 0)
+		}
+		start = end + 1
+		if c, ok := buildContour(pts, on); ok {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// buildContour переводит точки TrueType в сегменты: между двумя
+// контрольными точками подряд лежит неявная точка на кривой посередине.
+func buildContour(pts []Point, on []bool) (Contour, bool) {
+	n := len(pts)
+	if n < 2 {
+		return Contour{}, false
+	}
+	mid := func(a, b Point) Point { return Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2} }
+	// Обход начинается с точки на кривой и заканчивается ею же; контур из
+	// одних контрольных точек начинается между последней и первой.
+	var c Contour
+	first, offset := -1, 0
+	for i := range on {
+		if on[i] {
+			first = i
+			break
+		}
+	}
+	if first >= 0 {
+		c.Start, offset = pts[first], first+1
+	} else {
+		c.Start = mid(pts[n-1], pts[0])
+	}
+	var ctrl *Point
+	for k := 0; k < n; k++ {
+		i := (offset + k) % n
+		p := pts[i]
+		switch {
+		case on[i] && ctrl != nil:
+			c.Segments = append(c.Segments, Segment{Curve: true, C: *ctrl, P: p})
+			ctrl = nil
+		case on[i]:
+			c.Segments = append(c.Segments, Segment{P: p})
+		default:
+			if ctrl != nil {
+				c.Segments = append(c.Segments, Segment{Curve: true, C: *ctrl, P: mid(*ctrl, p)})
+			}
+			ctrl = &p
+		}
+	}
+	if ctrl != nil {
+		c.Segments = append(c.Segments, Segment{Curve: true, C: *ctrl, P: c.Start})
+	}
+	return c, true
+}
+
+// Glyph — глиф строки и символ, который он изображает.
+type Glyph struct {
+	ID   uint16
+	Rune rune
+}
+
+// Layout переводит строку в глифы: табуляция и переводы строк становятся
+// пробелами, прочие управляющие символы пропускаются, символы без глифа
+// заменяются «?».
+func (f *Font) Layout(s string) []Glyph {
+	out := make([]Glyph, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			r = ' '
+		case r < 32 || r == 0x7F:
+			continue
+		case !f.HasGlyph(r):
+			r = '?'
+		}
+		out = append(out, Glyph{ID: f.cmap[r], Rune: r})
+	}
+	return out
+}
+
+// Width возвращает ширину строки s в единицах шрифта.
+func (f *Font) Width(s string) int {
+	total := 0
+	for _, g := range f.Layout(s) {
+		total += f.Advance(g.ID)
+	}
+	return total
+}